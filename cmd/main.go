@@ -1,17 +1,59 @@
 package main
 
 import (
+	"URLbot/pkg/api"
+	"URLbot/pkg/blob/filesystem"
 	"URLbot/pkg/clients/telegram"
 	eventconsumer "URLbot/pkg/consumer/event-consumer"
+	"URLbot/pkg/enrich"
 	tgEvents "URLbot/pkg/events/telegram"
+	"URLbot/pkg/feed"
+	"URLbot/pkg/feedpoll"
+	"URLbot/pkg/linkcheck"
+	"URLbot/pkg/scheduler"
+	"URLbot/pkg/service"
 	"URLbot/pkg/storage/memory"
 	"context"
+	"errors"
 	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
+	_ "time/tzdata" // Digest time zones must work on hosts without a zoneinfo database.
+)
+
+// maxReplyParts is the number of messages a long reply may be split into
+// before it is sent as a file instead.
+const maxReplyParts = 5
+
+// schedulerTick is how often scheduled jobs, such as digests, check for due work.
+const schedulerTick = 30 * time.Second
+
+// Limits for fetching saved pages to read their titles and descriptions.
+// Pages that did not fit in the queue are picked up by the backfill pass.
+const (
+	enrichTimeout  = 10 * time.Second
+	enrichMaxBytes = 2 << 20
+	enrichWorkers  = 4
+	enrichBackfill = time.Hour
+)
+
+// Limits for the dead-link checker. Requests to the same host are spaced
+// out by linkCheckHostDelay to stay polite.
+const (
+	linkCheckTimeout   = 15 * time.Second
+	linkCheckHostDelay = 2 * time.Second
+	linkCheckWorkers   = 4
+)
+
+// Limits for polling the feeds users subscribe to.
+const (
+	feedPollTimeout  = 15 * time.Second
+	feedPollMaxBytes = 5 << 20
 )
 
 func main() {
@@ -22,10 +64,97 @@ func main() {
 	defer stop()
 
 	tgClient := telegram.NewClient(mustParseFlags())
+	tgClient.SetFileFallback(maxReplyParts, tgClient.SendAsFile)
+
+	me, err := tgClient.GetMe()
+	if err != nil {
+		if errors.Is(err, telegram.ErrUnauthorized) {
+			slog.Error("Invalid Telegram bot token, check -tg-bot-token", "err", err)
+		} else {
+			slog.Error("Telegram self-check failed", "err", err)
+		}
+		os.Exit(1)
+	}
+	slog.Info("Authorized as Telegram bot", "username", me.Username, "id", me.ID)
 
 	storage := memory.New()
 
+	pipeline := enrich.NewPipeline(enrich.NewFetcher(enrichTimeout, enrichMaxBytes), storage, enrichWorkers)
+	pipeline.SetBackfill(storage, enrichBackfill)
+	go pipeline.Run(ctx)
+
+	checker := linkcheck.NewChecker(linkCheckTimeout, linkCheckHostDelay)
+	go linkcheck.NewJob(checker, storage, linkCheckWorkers, linkCheckInterval()).Run(ctx)
+
+	svc := service.New(storage)
+	svc.SetEnricher(pipeline)
+	svc.SetAvoidRecent(randomAvoidRecent())
+
 	eventProcessor := tgEvents.New(tgClient, storage)
+	eventProcessor.SetBotName(me.Username)
+	eventProcessor.SetService(svc)
+	eventProcessor.SetTrashRetention(trashRetention())
+
+	poller := feedpoll.NewPoller(feedpoll.NewFetcher(feedPollTimeout, feedPollMaxBytes), storage, subscriptionInterval())
+	poller.SetNotifier(eventProcessor)
+	poller.SetEnricher(pipeline)
+	eventProcessor.SetSubscriber(poller)
+
+	if dir := os.Getenv("SNAPSHOT_DIR"); dir != "" {
+		blobs, err := filesystem.New(dir)
+		if err != nil {
+			slog.Error("Failed to open snapshot directory", "dir", dir, "err", err)
+			os.Exit(1)
+		}
+
+		pipeline.SetArchiver(enrich.NewArchiver(blobs))
+		eventProcessor.SetSnapshots(blobs)
+		slog.Info("Offline snapshots enabled", "dir", dir)
+	}
+
+	if addr := os.Getenv("FEED_ADDR"); addr != "" {
+		baseURL := publicURL(addr, "FEED_BASE_URL")
+
+		go func() {
+			err := feed.NewServer(storage, baseURL).Run(ctx, addr)
+			if err != nil {
+				slog.Error("Feed server stopped", "addr", addr, "err", err)
+			}
+		}()
+
+		eventProcessor.SetFeedURL(baseURL)
+		slog.Info("Feed server enabled", "addr", addr, "base_url", baseURL)
+	}
+
+	if addr := os.Getenv("API_ADDR"); addr != "" {
+		baseURL := publicURL(addr, "API_BASE_URL")
+
+		go func() {
+			err := api.NewServer(svc).Run(ctx, addr)
+			if err != nil {
+				slog.Error("API server stopped", "addr", addr, "err", err)
+			}
+		}()
+
+		eventProcessor.SetAPIURL(baseURL)
+		slog.Info("API server enabled", "addr", addr, "base_url", baseURL)
+	}
+	eventProcessor.Use(
+		tgEvents.AllowUsers(tgClient, allowedUsers()...),
+		tgEvents.RateLimit(tgClient, rateLimit(), time.Minute),
+	)
+
+	sched := scheduler.New(schedulerTick)
+	sched.Add("digest", eventProcessor.SendDueDigests)
+	sched.Add("snooze", eventProcessor.SendDueReminders)
+	sched.Add("trash", eventProcessor.PurgeTrash)
+	sched.Add("subscriptions", poller.PollDue)
+	go sched.Run(ctx)
+
+	err = tgEvents.SyncCommands(tgClient, eventProcessor.Commands(), "ru")
+	if err != nil {
+		slog.Warn("Failed to sync command menu", "err", err)
+	}
 
 	batchSize, err := strconv.Atoi(os.Getenv("BATCH_SIZE"))
 	if err != nil {
@@ -63,3 +192,92 @@ func mustParseFlags() (string, string, string) {
 
 	return *scheme, *host, *token
 }
+
+// allowedUsers returns the usernames from the comma-separated ALLOWED_USERS variable.
+// An empty result means the bot is open to everyone.
+func allowedUsers() []string {
+	var users []string
+	for _, u := range strings.Split(os.Getenv("ALLOWED_USERS"), ",") {
+		if u = strings.TrimPrefix(strings.TrimSpace(u), "@"); u != "" {
+			users = append(users, u)
+		}
+	}
+
+	return users
+}
+
+// rateLimit returns the number of commands a user may send per minute,
+// taken from RATE_LIMIT.
+func rateLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("RATE_LIMIT"))
+	if err != nil || limit <= 0 {
+		limit = 30
+		slog.Warn("Invalid or missing RATE_LIMIT, using default", "default", limit)
+	}
+
+	return limit
+}
+
+// linkCheckInterval returns how often all saved links are checked,
+// taken from LINK_CHECK_INTERVAL (e.g. "24h").
+func linkCheckInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("LINK_CHECK_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = 24 * time.Hour
+		slog.Warn("Invalid or missing LINK_CHECK_INTERVAL, using default", "default", interval)
+	}
+
+	return interval
+}
+
+// subscriptionInterval returns how often each subscribed feed is polled,
+// taken from SUBSCRIPTION_INTERVAL (e.g. "30m").
+func subscriptionInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("SUBSCRIPTION_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = time.Hour
+		slog.Warn("Invalid or missing SUBSCRIPTION_INTERVAL, using default", "default", interval)
+	}
+
+	return interval
+}
+
+// randomAvoidRecent returns how many recent /random picks are not repeated,
+// taken from RANDOM_AVOID_RECENT. Zero turns this off.
+func randomAvoidRecent() int {
+	k, err := strconv.Atoi(os.Getenv("RANDOM_AVOID_RECENT"))
+	if err != nil || k < 0 {
+		k = 5
+		slog.Warn("Invalid or missing RANDOM_AVOID_RECENT, using default", "default", k)
+	}
+
+	return k
+}
+
+// trashRetention returns how long removed pages are kept in the trash,
+// taken from TRASH_RETENTION_DAYS.
+func trashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+		slog.Warn("Invalid or missing TRASH_RETENTION_DAYS, using default", "default", days)
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// publicURL returns the public address of a server listening on addr, taken
+// from the env variable. Without it the server is assumed to be reachable at addr.
+func publicURL(addr, env string) string {
+	if base := os.Getenv(env); base != "" {
+		return base
+	}
+
+	host := addr
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
+	slog.Warn("Missing "+env+", links will only work locally", "default", "http://"+host)
+
+	return "http://" + host
+}
@@ -0,0 +1,411 @@
+// Package api serves a JSON API for managing reading lists outside Telegram,
+// for scripts and browser extensions. It goes through the same service as
+// the bot commands, so both behave the same way.
+package api
+
+import (
+	"URLbot/pkg/service"
+	"URLbot/pkg/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Paging limits of GET /api/v1/pages.
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+// maxBodyBytes is the largest request body accepted.
+const maxBodyBytes = 64 << 10
+
+// shutdownTimeout is how long Run waits for open requests when it stops.
+const shutdownTimeout = 5 * time.Second
+
+// Server serves the API. Every request must carry the API token of a user,
+// issued by the /token bot command:
+//
+//	Authorization: Bearer <token>
+//
+// and acts on that user's list:
+//
+//	POST   /api/v1/pages                 {"url": "...", "tags": ["..."]}
+//	GET    /api/v1/pages                 ?status=all|unread|read&tag=&offset=&limit=
+//	GET    /api/v1/pages/random          ?count=1..10&length=short|long
+//	GET    /api/v1/pages/{id}
+//	POST   /api/v1/pages/{id}/read
+//	POST   /api/v1/pages/{id}/tags       {"add": ["..."], "remove": ["..."]}
+//	DELETE /api/v1/pages/{id}
+//
+// Errors are returned as {"error": "..."} with a matching status code.
+type Server struct {
+	service *service.Service
+}
+
+// NewServer creates an API server on top of the given service.
+func NewServer(s *service.Service) *Server {
+	return &Server{service: s}
+}
+
+// handlerFunc handles a request authenticated as owner.
+type handlerFunc func(w http.ResponseWriter, r *http.Request, owner string)
+
+// Handler returns the HTTP handler of the server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/pages", s.auth(s.add))
+	mux.HandleFunc("GET /api/v1/pages", s.auth(s.list))
+	mux.HandleFunc("GET /api/v1/pages/random", s.auth(s.random))
+	mux.HandleFunc("GET /api/v1/pages/{id}", s.auth(s.get))
+	mux.HandleFunc("POST /api/v1/pages/{id}/read", s.auth(s.markRead))
+	mux.HandleFunc("POST /api/v1/pages/{id}/tags", s.auth(s.tag))
+	mux.HandleFunc("DELETE /api/v1/pages/{id}", s.auth(s.remove))
+
+	return mux
+}
+
+// Run listens on addr until ctx is cancelled, then shuts the server down gracefully.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(shutdownCtx)
+		if err != nil {
+			slog.Warn("API server shutdown failed", "err", err)
+		}
+	}()
+
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// auth resolves the bearer token of the request to its owner
+// and answers 401 Unauthorized if it is missing or unknown.
+func (s *Server) auth(next handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing API token")
+			return
+		}
+
+		owner, err := s.service.TokenOwner(strings.TrimSpace(token))
+		if err != nil {
+			if !errors.Is(err, storage.ErrNoAPIToken) {
+				s.fail(w, "Failed to look up API token", err)
+				return
+			}
+
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "invalid API token")
+			return
+		}
+
+		next(w, r, owner)
+	}
+}
+
+// addRequest is the body of POST /api/v1/pages.
+type addRequest struct {
+	URL  string   `json:"url"`
+	Tags []string `json:"tags"`
+}
+
+// add saves a page to the list.
+func (s *Server) add(w http.ResponseWriter, r *http.Request, owner string) {
+	var req addRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	page, err := s.service.Add(owner, owner, strings.TrimSpace(req.URL), req.Tags)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrInvalidTag):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, storage.ErrPageExists):
+			writeError(w, http.StatusConflict, err.Error())
+		default:
+			s.fail(w, "Failed to add page", err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newPageJSON(page))
+}
+
+// listResponse is the body of the GET /api/v1/pages response.
+type listResponse struct {
+	Pages  []pageJSON `json:"pages"`
+	Total  int        `json:"total"`
+	Offset int        `json:"offset"`
+	Limit  int        `json:"limit"`
+}
+
+// list returns a page of the list, filtered by read status and tag.
+func (s *Server) list(w http.ResponseWriter, r *http.Request, owner string) {
+	query := r.URL.Query()
+
+	status, err := service.ParseStatus(query.Get("status"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	offset, ok := intParam(w, query.Get("offset"), "offset", 0, 0, -1)
+	if !ok {
+		return
+	}
+
+	limit, ok := intParam(w, query.Get("limit"), "limit", defaultLimit, 1, maxLimit)
+	if !ok {
+		return
+	}
+
+	pages, total, err := s.service.List(owner, service.ListQuery{
+		Status: status,
+		Tag:    query.Get("tag"),
+		Offset: offset,
+		Limit:  limit,
+	})
+	if err != nil {
+		s.fail(w, "Failed to list pages", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listResponse{
+		Pages:  newPagesJSON(pages),
+		Total:  total,
+		Offset: offset,
+		Limit:  limit,
+	})
+}
+
+// pagesResponse is the body of responses with several pages.
+type pagesResponse struct {
+	Pages []pageJSON `json:"pages"`
+}
+
+// random picks unread pages like /random, marking them as served.
+func (s *Server) random(w http.ResponseWriter, r *http.Request, owner string) {
+	query := r.URL.Query()
+
+	count, ok := intParam(w, query.Get("count"), "count", 1, 1, service.MaxRandom)
+	if !ok {
+		return
+	}
+
+	var filter storage.Filter
+	if length := query.Get("length"); length != "" {
+		var err error
+		filter, err = service.ReadingFilter(length)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	pages, err := s.service.Random(owner, count, filter)
+	if err != nil {
+		s.fail(w, "Failed to pick random pages", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pagesResponse{Pages: newPagesJSON(pages)})
+}
+
+// get returns a single page.
+func (s *Server) get(w http.ResponseWriter, r *http.Request, owner string) {
+	page, ok := s.page(w, r, owner)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPageJSON(page))
+}
+
+// markRead marks a page as read and returns it.
+func (s *Server) markRead(w http.ResponseWriter, r *http.Request, owner string) {
+	page, ok := s.page(w, r, owner)
+	if !ok {
+		return
+	}
+
+	err := s.service.MarkRead(owner, page.URL)
+	if err != nil {
+		s.pageError(w, "Failed to mark page as read", err)
+		return
+	}
+
+	page, ok = s.page(w, r, owner)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPageJSON(page))
+}
+
+// tagRequest is the body of POST /api/v1/pages/{id}/tags.
+type tagRequest struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// tagsResponse is the body of the POST /api/v1/pages/{id}/tags response.
+type tagsResponse struct {
+	Tags []string `json:"tags"`
+}
+
+// tag adds and removes tags of a page.
+func (s *Server) tag(w http.ResponseWriter, r *http.Request, owner string) {
+	page, ok := s.page(w, r, owner)
+	if !ok {
+		return
+	}
+
+	var req tagRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	tags, err := s.service.Tag(owner, page.URL, req.Add, req.Remove)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTag) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		s.pageError(w, "Failed to tag page", err)
+		return
+	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, tagsResponse{Tags: tags})
+}
+
+// remove moves a page to the trash, where /trash can restore it.
+func (s *Server) remove(w http.ResponseWriter, r *http.Request, owner string) {
+	page, ok := s.page(w, r, owner)
+	if !ok {
+		return
+	}
+
+	err := s.service.Remove(owner, page.URL)
+	if err != nil {
+		s.pageError(w, "Failed to remove page", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// page returns the page given by the {id} path value. It answers the request
+// itself and returns false if the ID is invalid or there is no such page.
+func (s *Server) page(w http.ResponseWriter, r *http.Request, owner string) (*storage.Page, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid page ID")
+		return nil, false
+	}
+
+	page, err := s.service.Get(owner, id)
+	if err != nil {
+		s.pageError(w, "Failed to get page", err)
+		return nil, false
+	}
+
+	return page, true
+}
+
+// pageError answers 404 Not Found for missing pages and
+// 500 Internal Server Error for everything else.
+func (s *Server) pageError(w http.ResponseWriter, msg string, err error) {
+	if errors.Is(err, storage.ErrNoPagesFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.fail(w, msg, err)
+}
+
+// fail logs an internal error and answers with 500 Internal Server Error.
+func (s *Server) fail(w http.ResponseWriter, msg string, err error) {
+	slog.Error(msg, "err", err)
+	writeError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// intParam parses an integer query parameter, using def if it is empty.
+// A negative hi means no upper bound. It answers 400 Bad Request and
+// returns false if the value is not a number in [lo, hi].
+func intParam(w http.ResponseWriter, raw, name string, def, lo, hi int) (int, bool) {
+	if raw == "" {
+		return def, true
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < lo || (hi >= 0 && n > hi) {
+		writeError(w, http.StatusBadRequest, "invalid "+name)
+		return 0, false
+	}
+
+	return n, true
+}
+
+// readJSON decodes the JSON body of the request into v. It answers
+// 400 Bad Request and returns false if the body is not valid JSON.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+
+	return true
+}
+
+// errorResponse is the body of error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError answers with the status code and a JSON error message.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, errorResponse{Error: msg})
+}
+
+// writeJSON answers with the status code and v encoded as JSON.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.Warn("Failed to write API response", "err", err)
+	}
+}
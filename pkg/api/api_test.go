@@ -0,0 +1,102 @@
+package api_test
+
+import (
+	"URLbot/pkg/api"
+	"URLbot/pkg/service"
+	"URLbot/pkg/storage/memory"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s := memory.New()
+	svc := service.New(s)
+
+	token, err := svc.IssueToken("alex")
+	if err != nil {
+		t.Fatalf("IssueToken() failed: %v", err)
+	}
+	// Bob's page gets ID 1, alex's pages 2 and 3.
+	if _, err := svc.Add("bob", "bob", "https://bob.example", nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	srv := httptest.NewServer(api.NewServer(svc).Handler())
+	defer srv.Close()
+
+	// Steps run in order against the same list.
+	steps := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		token    string // Defaults to alex's token.
+		wantCode int
+		wantBody string // Expected substring of the response body.
+	}{
+		{name: "no token", method: "GET", path: "/api/v1/pages", token: "-", wantCode: http.StatusUnauthorized, wantBody: `"missing API token"`},
+		{name: "wrong token", method: "GET", path: "/api/v1/pages", token: "wrong", wantCode: http.StatusUnauthorized, wantBody: `"invalid API token"`},
+		{name: "empty list", method: "GET", path: "/api/v1/pages", wantCode: http.StatusOK, wantBody: `{"pages":[],"total":0,"offset":0,"limit":50}`},
+		{name: "add", method: "POST", path: "/api/v1/pages", body: `{"url": "https://go.dev/blog", "tags": ["#Go"]}`, wantCode: http.StatusCreated, wantBody: `"url":"https://go.dev/blog","tags":["go"],"read":false,"added_by":"alex"`},
+		{name: "add another", method: "POST", path: "/api/v1/pages", body: `{"url": "https://go.dev/doc"}`, wantCode: http.StatusCreated, wantBody: `"id":3`},
+		{name: "add duplicate", method: "POST", path: "/api/v1/pages", body: `{"url": "https://go.dev/blog"}`, wantCode: http.StatusConflict, wantBody: `"page already exists"`},
+		{name: "add invalid URL", method: "POST", path: "/api/v1/pages", body: `{"url": "go.dev"}`, wantCode: http.StatusBadRequest, wantBody: `"invalid URL"`},
+		{name: "add unknown field", method: "POST", path: "/api/v1/pages", body: `{"link": "https://go.dev"}`, wantCode: http.StatusBadRequest, wantBody: `invalid JSON body`},
+		{name: "get", method: "GET", path: "/api/v1/pages/2", wantCode: http.StatusOK, wantBody: `"id":2,"url":"https://go.dev/blog"`},
+		{name: "get other user's page", method: "GET", path: "/api/v1/pages/1", wantCode: http.StatusNotFound},
+		{name: "get invalid ID", method: "GET", path: "/api/v1/pages/abc", wantCode: http.StatusBadRequest, wantBody: `"invalid page ID"`},
+		{name: "read", method: "POST", path: "/api/v1/pages/2/read", wantCode: http.StatusOK, wantBody: `"read":true`},
+		{name: "list unread", method: "GET", path: "/api/v1/pages?status=unread", wantCode: http.StatusOK, wantBody: `"total":1`},
+		{name: "list by tag", method: "GET", path: "/api/v1/pages?tag=go", wantCode: http.StatusOK, wantBody: `"url":"https://go.dev/blog"`},
+		{name: "list paged", method: "GET", path: "/api/v1/pages?offset=1&limit=1", wantCode: http.StatusOK, wantBody: `"total":2,"offset":1,"limit":1}`},
+		{name: "list bad status", method: "GET", path: "/api/v1/pages?status=new", wantCode: http.StatusBadRequest},
+		{name: "list bad limit", method: "GET", path: "/api/v1/pages?limit=1000", wantCode: http.StatusBadRequest, wantBody: `"invalid limit"`},
+		{name: "random", method: "GET", path: "/api/v1/pages/random?count=5", wantCode: http.StatusOK, wantBody: `{"pages":[{"id":3,`},
+		{name: "random long", method: "GET", path: "/api/v1/pages/random?length=long", wantCode: http.StatusOK, wantBody: `{"pages":[]}`},
+		{name: "random bad count", method: "GET", path: "/api/v1/pages/random?count=11", wantCode: http.StatusBadRequest},
+		{name: "tag", method: "POST", path: "/api/v1/pages/3/tags", body: `{"add": ["later", "docs"], "remove": ["docs"]}`, wantCode: http.StatusOK, wantBody: `{"tags":["later"]}`},
+		{name: "tag invalid", method: "POST", path: "/api/v1/pages/3/tags", body: `{"add": [""]}`, wantCode: http.StatusBadRequest, wantBody: `"invalid tag"`},
+		{name: "remove", method: "DELETE", path: "/api/v1/pages/3", wantCode: http.StatusNoContent},
+		{name: "remove again", method: "DELETE", path: "/api/v1/pages/3", wantCode: http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		req, err := http.NewRequest(step.method, srv.URL+step.path, strings.NewReader(step.body))
+		if err != nil {
+			t.Fatalf("%s: NewRequest() failed: %v", step.name, err)
+		}
+
+		switch step.token {
+		case "":
+			req.Header.Set("Authorization", "Bearer "+token)
+		case "-":
+		default:
+			req.Header.Set("Authorization", "Bearer "+step.token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", step.name, err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != step.wantCode || !strings.Contains(string(body), step.wantBody) {
+			t.Errorf("%s: %s %s = %d %s, want %d with %s", step.name, step.method, step.path, resp.StatusCode, body, step.wantCode, step.wantBody)
+		}
+		if step.wantCode != http.StatusNoContent && !json.Valid(body) {
+			t.Errorf("%s: response %q is not valid JSON", step.name, body)
+		}
+	}
+
+	// The API and the bot share the undo log, so /undo reverses API changes.
+	a, err := s.PopUndo("alex")
+	if err != nil || a.URL != "https://go.dev/doc" {
+		t.Errorf("PopUndo() = %+v, %v; want the removal made through the API", a, err)
+	}
+}
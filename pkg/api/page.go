@@ -0,0 +1,57 @@
+package api
+
+import (
+	"URLbot/pkg/storage"
+	"time"
+)
+
+// pageJSON is the API representation of a saved page.
+type pageJSON struct {
+	ID                 int        `json:"id"`
+	URL                string     `json:"url"`
+	Title              string     `json:"title,omitempty"`
+	Description        string     `json:"description,omitempty"`
+	SiteName           string     `json:"site_name,omitempty"`
+	Tags               []string   `json:"tags"`
+	Read               bool       `json:"read"`
+	ReadingTimeMinutes int        `json:"reading_time_minutes,omitempty"`
+	AddedBy            string     `json:"added_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ReadAt             *time.Time `json:"read_at,omitempty"`
+}
+
+// newPageJSON converts a stored page into its API representation.
+func newPageJSON(p *storage.Page) pageJSON {
+	res := pageJSON{
+		ID:                 p.ID,
+		URL:                p.URL,
+		Title:              p.Title,
+		Description:        p.Description,
+		SiteName:           p.SiteName,
+		Tags:               p.Tags,
+		Read:               p.Read,
+		ReadingTimeMinutes: int(p.ReadingTime.Minutes()),
+		AddedBy:            p.AddedBy,
+		CreatedAt:          p.CreatedAt,
+	}
+	if res.Tags == nil {
+		res.Tags = []string{}
+	}
+	if p.Read && !p.ReadAt.IsZero() {
+		readAt := p.ReadAt
+		res.ReadAt = &readAt
+	}
+
+	return res
+}
+
+// newPagesJSON converts stored pages into their API representation.
+// The result is never nil, so empty lists are encoded as [].
+func newPagesJSON(pages []*storage.Page) []pageJSON {
+	res := make([]pageJSON, 0, len(pages))
+	for _, p := range pages {
+		res = append(res, newPageJSON(p))
+	}
+
+	return res
+}
@@ -0,0 +1,36 @@
+// Package blob defines a store for binary objects, such as page snapshots,
+// that are too large to keep in the main storage.
+package blob
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store keeps binary objects under slash-separated keys such as "snapshots/42.html".
+type Store interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// ValidKey reports whether key is a relative slash-separated path
+// without empty, "." or ".." elements.
+func ValidKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for _, elem := range strings.Split(key, "/") {
+		if elem == "" || elem == "." || elem == ".." || strings.ContainsRune(elem, '\\') {
+			return false
+		}
+	}
+
+	return true
+}
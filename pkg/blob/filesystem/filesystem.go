@@ -0,0 +1,103 @@
+package filesystem
+
+import (
+	"URLbot/pkg/blob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Store is a blob.Store that keeps every object in a file under a root directory.
+type Store struct {
+	root string
+}
+
+// New creates a Store rooted at dir, creating the directory if needed.
+func New(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("create blob directory failed: %v", err)
+	}
+
+	return &Store{root: dir}, nil
+}
+
+// Put writes data under key, replacing any previous object. The file is written
+// to a temporary name first, so readers never see a partially written object.
+func (s *Store) Put(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("create blob directory failed: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create blob file failed: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write blob failed: %v", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("rename blob file failed: %v", err)
+	}
+
+	return nil
+}
+
+// Get reads the object stored under key.
+func (s *Store) Get(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, blob.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("read blob failed: %v", err)
+	}
+
+	return data, nil
+}
+
+// Delete removes the object stored under key. Deleting a missing object is not an error.
+func (s *Store) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete blob failed: %v", err)
+	}
+
+	return nil
+}
+
+// path returns the file path for a key.
+func (s *Store) path(key string) (string, error) {
+	if !blob.ValidKey(key) {
+		return "", blob.ErrInvalidKey
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,64 @@
+package filesystem_test
+
+import (
+	"URLbot/pkg/blob"
+	"URLbot/pkg/blob/filesystem"
+	"errors"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	s, err := filesystem.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	err = s.Put("snapshots/1.html", []byte("first"))
+	if err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	err = s.Put("snapshots/1.html", []byte("second"))
+	if err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	got, err := s.Get("snapshots/1.html")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if string(got) != "second" {
+		t.Errorf("Get() = %q, want %q", got, "second")
+	}
+
+	err = s.Delete("snapshots/1.html")
+	if err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+
+	_, err = s.Get("snapshots/1.html")
+	if !errors.Is(err, blob.ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want %v", err, blob.ErrNotFound)
+	}
+
+	err = s.Delete("snapshots/1.html")
+	if err != nil {
+		t.Errorf("Delete() of a missing blob failed: %v", err)
+	}
+}
+
+func TestStore_invalidKey(t *testing.T) {
+	s, err := filesystem.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	for _, key := range []string{"", "../escape", "a/../../b", "/abs", "a//b", `a\b`} {
+		t.Run(key, func(t *testing.T) {
+			err := s.Put(key, []byte("data"))
+			if !errors.Is(err, blob.ErrInvalidKey) {
+				t.Errorf("Put(%q) error = %v, want %v", key, err, blob.ErrInvalidKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package telegram
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxMessageLength is the maximum length of a message text accepted by Telegram,
+// measured in UTF-16 code units.
+const MaxMessageLength = 4096
+
+// SplitMessage splits text into parts of at most limit UTF-16 code units.
+// Parts are cut at line boundaries when possible, then at spaces, and only
+// as a last resort in the middle of a word.
+//
+// With the HTML parse mode the text is never cut inside a tag or an entity
+// such as "&amp;". If a part has to end inside a formatting span, the open
+// tags are closed at the end of the part and reopened at the start of the next one.
+func SplitMessage(text string, limit int, parseMode string) []string {
+	if textLen(text) <= limit {
+		return []string{text}
+	}
+
+	atoms := splitAtoms(text, parseMode == ParseModeHTML)
+
+	var parts []string
+	var open []atom
+
+	for start := 0; start < len(atoms); {
+		cut := findCut(atoms, start, open, limit)
+
+		part, next := render(atoms[start:cut], open)
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+
+		start, open = cut, next
+	}
+
+	return parts
+}
+
+// atom is an indivisible piece of message text: a character, an HTML tag or an HTML entity.
+type atom struct {
+	text    string
+	tag     string // Tag name for HTML tags, empty otherwise.
+	closing bool   // Set for closing tags such as "</b>".
+}
+
+// splitAtoms breaks text into atoms. HTML tags and entities are recognized only if html is set.
+func splitAtoms(text string, html bool) []atom {
+	var atoms []atom
+
+	for i := 0; i < len(text); {
+		if html {
+			if a, n, ok := parseTag(text[i:]); ok {
+				atoms = append(atoms, a)
+				i += n
+				continue
+			}
+
+			if n := entityLen(text[i:]); n > 0 {
+				atoms = append(atoms, atom{text: text[i : i+n]})
+				i += n
+				continue
+			}
+		}
+
+		_, n := utf8.DecodeRuneInString(text[i:])
+		atoms = append(atoms, atom{text: text[i : i+n]})
+		i += n
+	}
+
+	return atoms
+}
+
+// parseTag recognizes an HTML tag at the start of s and returns it with its length.
+func parseTag(s string) (atom, int, bool) {
+	if !strings.HasPrefix(s, "<") {
+		return atom{}, 0, false
+	}
+
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		return atom{}, 0, false
+	}
+
+	inner := s[1:end]
+	closing := strings.HasPrefix(inner, "/")
+	inner = strings.TrimPrefix(inner, "/")
+
+	name := inner
+	if i := strings.IndexFunc(inner, unicode.IsSpace); i >= 0 {
+		name = inner[:i]
+	}
+
+	if name == "" {
+		return atom{}, 0, false
+	}
+
+	return atom{text: s[:end+1], tag: strings.ToLower(name), closing: closing}, end + 1, true
+}
+
+// entityLen returns the length of an HTML entity such as "&amp;" or "&#34;"
+// at the start of s, or 0 if there is none.
+func entityLen(s string) int {
+	if !strings.HasPrefix(s, "&") {
+		return 0
+	}
+
+	for i := 1; i < len(s) && i <= 10; i++ {
+		c := s[i]
+		switch {
+		case c == ';':
+			if i == 1 {
+				return 0
+			}
+			return i + 1
+		case c == '#' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		default:
+			return 0
+		}
+	}
+
+	return 0
+}
+
+// findCut returns the index of the first atom that does not fit into the part
+// starting at start. It prefers cutting after a newline, then after a space.
+func findCut(atoms []atom, start int, open []atom, limit int) int {
+	stack := append([]atom(nil), open...)
+	length := atomsLen(stack)
+
+	newline, space := -1, -1
+
+	i := start
+	for ; i < len(atoms); i++ {
+		a := atoms[i]
+		next := push(stack, a)
+
+		if length+textLen(a.text)+closingLen(next) > limit {
+			break
+		}
+
+		stack = next
+		length += textLen(a.text)
+
+		switch {
+		case a.text == "\n":
+			newline = i + 1
+		case a.tag == "" && strings.TrimSpace(a.text) == "":
+			space = i + 1
+		}
+	}
+
+	switch {
+	case i == len(atoms):
+		return i
+	case newline > start:
+		return newline
+	case space > start:
+		return space
+	case i > start:
+		return i
+	default:
+		return start + 1
+	}
+}
+
+// render joins atoms into a part that starts by reopening the open tags and
+// ends by closing the tags still open. It returns the tags open at the end.
+func render(atoms []atom, open []atom) (string, []atom) {
+	var builder strings.Builder
+
+	stack := append([]atom(nil), open...)
+	for _, a := range stack {
+		builder.WriteString(a.text)
+	}
+
+	for _, a := range atoms {
+		builder.WriteString(a.text)
+		stack = push(stack, a)
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		builder.WriteString("</" + stack[i].tag + ">")
+	}
+
+	return builder.String(), stack
+}
+
+// push applies a tag atom to the stack of open tags and returns the new stack.
+func push(stack []atom, a atom) []atom {
+	switch {
+	case a.tag == "":
+		return stack
+	case !a.closing:
+		return append(stack[:len(stack):len(stack)], a)
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].tag == a.tag {
+			return stack[:i:i]
+		}
+	}
+
+	return stack
+}
+
+// closingLen returns the length of the closing tags for the stack.
+func closingLen(stack []atom) int {
+	n := 0
+	for _, a := range stack {
+		n += len(a.tag) + 3
+	}
+
+	return n
+}
+
+// atomsLen returns the total length of the atoms.
+func atomsLen(atoms []atom) int {
+	n := 0
+	for _, a := range atoms {
+		n += textLen(a.text)
+	}
+
+	return n
+}
+
+// textLen returns the length of s in UTF-16 code units, as Telegram counts it.
+func textLen(s string) int {
+	n := 0
+	for _, r := range s {
+		if r > 0xFFFF {
+			n += 2
+		} else {
+			n++
+		}
+	}
+
+	return n
+}
+
+// chatForgetIntervals is how many intervals a chat is remembered after its
+// last message. It only has to outlast the slots reserved by queued messages.
+const chatForgetIntervals = 100
+
+// chatLimiter spaces out messages sent to the same chat, so that long replies
+// split into several parts do not hit Telegram's flood limits.
+//
+// Slots are kept in two generations that are swapped every forget period,
+// so idle chats are dropped without sweeping the map on every message.
+type chatLimiter struct {
+	interval time.Duration
+	forget   time.Duration
+
+	mu      sync.Mutex
+	next    map[int]time.Time // Current generation.
+	prev    map[int]time.Time // Previous generation, dropped at the next swap.
+	swapped time.Time
+}
+
+// newChatLimiter creates a limiter that allows one message per interval in each chat.
+func newChatLimiter(interval time.Duration) *chatLimiter {
+	return &chatLimiter{
+		interval: interval,
+		forget:   chatForgetIntervals * interval,
+		next:     make(map[int]time.Time),
+		swapped:  time.Now(),
+	}
+}
+
+// wait blocks until a message may be sent to the chat and reserves that slot.
+// Chats not messaged for one to two forget periods are forgotten.
+func (l *chatLimiter) wait(chatID int) {
+	if l.interval <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if now.Sub(l.swapped) >= l.forget {
+		l.prev, l.next = l.next, make(map[int]time.Time)
+		l.swapped = now
+	}
+
+	at, ok := l.next[chatID]
+	if !ok {
+		at = l.prev[chatID]
+	}
+	if at.Before(now) {
+		at = now
+	}
+	l.next[chatID] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(at))
+}
@@ -0,0 +1,205 @@
+package telegram
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		limit     int
+		parseMode string
+		want      []string
+	}{
+		{
+			name:  "short text",
+			text:  "hello",
+			limit: 10,
+			want:  []string{"hello"},
+		},
+		{
+			name:  "split on lines",
+			text:  "line one\nline two\nline three",
+			limit: 18,
+			want:  []string{"line one\nline two", "line three"},
+		},
+		{
+			name:  "split long line on spaces",
+			text:  "aaaa bbbb cccc",
+			limit: 10,
+			want:  []string{"aaaa bbbb", "cccc"},
+		},
+		{
+			name:  "hard split",
+			text:  "abcdefghij",
+			limit: 4,
+			want:  []string{"abcd", "efgh", "ij"},
+		},
+		{
+			name:      "entity is not cut",
+			text:      "aa &amp; bb",
+			limit:     6,
+			parseMode: ParseModeHTML,
+			want:      []string{"aa", "&amp;", "bb"},
+		},
+		{
+			name:      "formatting span is reopened",
+			text:      "<b>one two three</b>",
+			limit:     16,
+			parseMode: ParseModeHTML,
+			want:      []string{"<b>one two </b>", "<b>three</b>"},
+		},
+		{
+			name:      "link tag is kept whole",
+			text:      `1. <a href="https://a.com">a</a>` + "\n" + `2. <a href="https://b.com">b</a>`,
+			limit:     40,
+			parseMode: ParseModeHTML,
+			want:      []string{`1. <a href="https://a.com">a</a>`, `2. <a href="https://b.com">b</a>`},
+		},
+		{
+			name:  "plain text tags are not special",
+			text:  "a<b>c",
+			limit: 3,
+			want:  []string{"a<b", ">c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitMessage(tt.text, tt.limit, tt.parseMode)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitMessage() = %q, want %q", got, tt.want)
+			}
+
+			for _, part := range got {
+				if textLen(part) > tt.limit {
+					t.Errorf("part %q is longer than %d", part, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_SendMessage_long(t *testing.T) {
+	var texts []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		texts = append(texts, r.URL.Query().Get("text"))
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse failed: %v", err)
+	}
+
+	client := NewClient(u.Scheme, u.Host, "test-token")
+	client.limiter = newChatLimiter(0)
+
+	line := strings.Repeat("x", 99) + "\n"
+	text := strings.Repeat(line, 100)
+
+	err = client.SendMessage(1, text)
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	if len(texts) != 3 || strings.Join(texts, "\n") != strings.TrimSpace(text) {
+		t.Errorf("unexpected parts: %d", len(texts))
+	}
+
+	var fallback string
+	client.SetFileFallback(2, func(chatID int, text, parseMode string) error {
+		fallback = text
+		return nil
+	})
+
+	texts = nil
+	err = client.SendMessage(1, text)
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	if len(texts) != 0 || fallback != text {
+		t.Errorf("fallback was not used: %d parts sent", len(texts))
+	}
+}
+
+func TestClient_SendMessage_longReplyAndKeyboard(t *testing.T) {
+	var parts []url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts = append(parts, r.URL.Query())
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse failed: %v", err)
+	}
+
+	client := NewClient(u.Scheme, u.Host, "test-token")
+	client.limiter = newChatLimiter(0)
+
+	text := strings.Repeat(strings.Repeat("x", 99)+"\n", 100)
+	keyboard := []InlineKeyboardButton{{Text: "Next", CallbackData: "next"}}
+
+	err = client.SendMessage(1, text, WithReplyTo(42), WithInlineKeyboard(keyboard), WithoutPreview())
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("sent %d parts, want 3", len(parts))
+	}
+
+	for i, q := range parts {
+		wantReply := ""
+		if i == 0 {
+			wantReply = "42"
+		}
+		if got := q.Get("reply_to_message_id"); got != wantReply {
+			t.Errorf("part %d reply_to_message_id = %q, want %q", i+1, got, wantReply)
+		}
+
+		hasMarkup := q.Has("reply_markup")
+		if want := i == len(parts)-1; hasMarkup != want {
+			t.Errorf("part %d has reply_markup = %v, want %v", i+1, hasMarkup, want)
+		}
+
+		if got := q.Get("disable_web_page_preview"); got != "true" {
+			t.Errorf("part %d disable_web_page_preview = %q, want true", i+1, got)
+		}
+	}
+}
+
+func TestChatLimiter_forgetsIdleChats(t *testing.T) {
+	l := newChatLimiter(time.Millisecond)
+
+	l.wait(1)
+	l.wait(2)
+
+	// Idle chats are dropped after one to two forget periods.
+	for i := 0; i < 2; i++ {
+		time.Sleep(l.forget + time.Millisecond)
+		l.wait(3)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.next)+len(l.prev) != 2 {
+		t.Errorf("limiter keeps %v and %v, want only the last chat", l.next, l.prev)
+	}
+	if _, ok := l.next[3]; !ok {
+		t.Error("limiter forgot the chat just messaged")
+	}
+}
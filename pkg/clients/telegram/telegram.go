@@ -1,26 +1,52 @@
 package telegram
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
+	"time"
+)
+
+var (
+	// ErrUnauthorized is returned when Telegram rejects the bot token.
+	ErrUnauthorized = errors.New("telegram rejected the bot token")
+	// ErrFileTooLarge is returned by DownloadFile for files over the size limit.
+	ErrFileTooLarge = errors.New("file is too large")
 )
 
 const (
-	getUpdates  = "getUpdates"
-	sendMessage = "sendMessage"
+	getMe         = "getMe"
+	getUpdates    = "getUpdates"
+	sendMessage   = "sendMessage"
+	setMyCommands = "setMyCommands"
+	getMyCommands = "getMyCommands"
+	sendDocument  = "sendDocument"
+	getFile       = "getFile"
+
+	answerCallbackQuery = "answerCallbackQuery"
+	editMessageText     = "editMessageText"
+	getChatMember       = "getChatMember"
 )
 
+// chatInterval is the default minimal delay between two messages sent to the same chat.
+const chatInterval = time.Second
+
 // Client represents a Telegram Bot API client.
 type Client struct {
 	scheme   string
 	host     string
 	basePath string
 	client   http.Client
+	limiter  *chatLimiter
+	maxParts int
+	fallback func(chatID int, text, parseMode string) error
 }
 
 // NewClient creates a new Telegram Bot API client with the given host and token.
@@ -30,14 +56,45 @@ func NewClient(scheme, host, token string) *Client {
 		host:     host,
 		basePath: newBasePath(token),
 		client:   http.Client{},
+		limiter:  newChatLimiter(chatInterval),
 	}
 }
 
+// SetFileFallback makes SendMessage call fn instead of sending the text
+// when it would have to be split into more than maxParts messages.
+// It is typically used with SendAsFile to deliver very long replies as a file.
+func (c *Client) SetFileFallback(maxParts int, fn func(chatID int, text, parseMode string) error) {
+	c.maxParts = maxParts
+	c.fallback = fn
+}
+
 // newBasePath returns the base API path using the provided bot token.
 func newBasePath(token string) string {
 	return "bot" + token
 }
 
+// GetMe returns the bot's own account. It is a cheap way to check the token:
+// an invalid token results in an error wrapping ErrUnauthorized.
+func (c *Client) GetMe() (*User, error) {
+	data, err := c.doRequest(getMe, url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	var res UserResponse
+
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	if !res.Ok {
+		return nil, fmt.Errorf("telegram API returned ok=false")
+	}
+
+	return &res.Result, nil
+}
+
 // GetUpdates retrieves new updates (messages, commands, etc.) from Telegram.
 func (c *Client) GetUpdates(offset, limit int) ([]Update, error) {
 	q := url.Values{}
@@ -63,41 +120,425 @@ func (c *Client) GetUpdates(offset, limit int) ([]Update, error) {
 	return res.Result, nil
 }
 
+// Supported values for WithParseMode.
+const (
+	ParseModeHTML       = "HTML"
+	ParseModeMarkdownV2 = "MarkdownV2"
+)
+
+// SendOption customizes a sendMessage request.
+type SendOption func(q url.Values)
+
+// WithParseMode sets how Telegram parses entities in the message text.
+func WithParseMode(mode string) SendOption {
+	return func(q url.Values) {
+		q.Set("parse_mode", mode)
+	}
+}
+
+// WithoutPreview disables the link preview for the message.
+func WithoutPreview() SendOption {
+	return func(q url.Values) {
+		q.Set("disable_web_page_preview", "true")
+	}
+}
+
+// WithReplyTo sends the message as a reply to the given message.
+func WithReplyTo(messageID int) SendOption {
+	return func(q url.Values) {
+		if messageID != 0 {
+			q.Set("reply_to_message_id", strconv.Itoa(messageID))
+		}
+	}
+}
+
+// WithInlineKeyboard attaches an inline keyboard with the given rows of buttons.
+// Pressed buttons arrive as updates with a CallbackQuery.
+func WithInlineKeyboard(rows ...[]InlineKeyboardButton) SendOption {
+	return func(q url.Values) {
+		data, err := json.Marshal(map[string][][]InlineKeyboardButton{"inline_keyboard": rows})
+		if err != nil {
+			return
+		}
+		q.Set("reply_markup", string(data))
+	}
+}
+
 // SendMessage sends a text message to the specified chat ID.
-func (c *Client) SendMessage(chatID int, text string) error {
+// Texts longer than MaxMessageLength are split with SplitMessage and sent in order,
+// or passed to the file fallback if they need too many parts. Only the first
+// part is sent as a reply and only the last part carries the inline keyboard.
+func (c *Client) SendMessage(chatID int, text string, opts ...SendOption) error {
+	q := url.Values{}
+	q.Add("chat_id", strconv.Itoa(chatID))
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	parts := SplitMessage(text, MaxMessageLength, q.Get("parse_mode"))
+
+	if c.fallback != nil && len(parts) > c.maxParts {
+		return c.fallback(chatID, text, q.Get("parse_mode"))
+	}
+
+	replyTo, markup := q.Get("reply_to_message_id"), q.Get("reply_markup")
+
+	for i, part := range parts {
+		c.limiter.wait(chatID)
+
+		q.Set("text", part)
+		setOrDel(q, "reply_to_message_id", replyTo, i == 0)
+		setOrDel(q, "reply_markup", markup, i == len(parts)-1)
+
+		_, err := c.doRequest(sendMessage, q)
+		if err != nil {
+			return fmt.Errorf("request failed on part %d of %d: %v", i+1, len(parts), err)
+		}
+	}
+
+	return nil
+}
+
+// setOrDel sets key to value if set is true and value is not empty,
+// and deletes it otherwise.
+func setOrDel(q url.Values, key, value string, set bool) {
+	if set && value != "" {
+		q.Set(key, value)
+		return
+	}
+
+	q.Del(key)
+}
+
+// EditMessageText replaces the text of a message sent by the bot.
+// Options that set reply_markup replace its inline keyboard, without them
+// the keyboard is removed.
+func (c *Client) EditMessageText(chatID, messageID int, text string, opts ...SendOption) error {
 	q := url.Values{}
 	q.Add("chat_id", strconv.Itoa(chatID))
+	q.Add("message_id", strconv.Itoa(messageID))
 	q.Add("text", text)
 
-	_, err := c.doRequest(sendMessage, q)
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return c.doCall(editMessageText, q)
+}
+
+// AnswerCallbackQuery acknowledges a pressed inline keyboard button.
+// A non-empty text is shown to the user as a short notification.
+func (c *Client) AnswerCallbackQuery(queryID, text string) error {
+	q := url.Values{}
+	q.Add("callback_query_id", queryID)
+	if text != "" {
+		q.Add("text", text)
+	}
+
+	return c.doCall(answerCallbackQuery, q)
+}
+
+// doCall performs a request whose result is not needed and checks the ok flag.
+func (c *Client) doCall(method string, q url.Values) error {
+	data, err := c.doRequest(method, q)
 	if err != nil {
 		return fmt.Errorf("request failed: %v", err)
 	}
 
+	var res Response
+
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	if !res.Ok {
+		return fmt.Errorf("telegram API returned ok=false: %s", res.Description)
+	}
+
 	return nil
 }
 
-// doRequest performs an HTTP GET request to the Telegram API using the given method and query parameters.
-func (c *Client) doRequest(method string, query url.Values) ([]byte, error) {
+// SetMyCommands replaces the bot's command menu for the given scope and language.
+// An empty languageCode applies the menu to users without a dedicated translation.
+func (c *Client) SetMyCommands(commands []BotCommand, scope BotCommandScope, languageCode string) error {
+	q, err := commandsQuery(scope, languageCode)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(commands)
+	if err != nil {
+		return fmt.Errorf("failed to marshal commands: %v", err)
+	}
+	q.Add("commands", string(data))
+
+	data, err = c.doRequest(setMyCommands, q)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+
+	var res Response
+
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	if !res.Ok {
+		return fmt.Errorf("telegram API returned ok=false: %s", res.Description)
+	}
+
+	return nil
+}
+
+// GetMyCommands returns the bot's current command menu for the given scope and language.
+func (c *Client) GetMyCommands(scope BotCommandScope, languageCode string) ([]BotCommand, error) {
+	q, err := commandsQuery(scope, languageCode)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.doRequest(getMyCommands, q)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+
+	var res CommandsResponse
+
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	if !res.Ok {
+		return nil, fmt.Errorf("telegram API returned ok=false")
+	}
+
+	return res.Result, nil
+}
+
+// commandsQuery builds the scope and language parameters shared by the command menu methods.
+func commandsQuery(scope BotCommandScope, languageCode string) (url.Values, error) {
+	q := url.Values{}
+
+	data, err := json.Marshal(scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal scope: %v", err)
+	}
+	q.Add("scope", string(data))
+
+	if languageCode != "" {
+		q.Add("language_code", languageCode)
+	}
+
+	return q, nil
+}
+
+// SendDocument uploads data as a file with the given name to the chat,
+// using a multipart/form-data POST request.
+func (c *Client) SendDocument(chatID int, fileName string, data []byte, caption string) error {
+	q := url.Values{}
+	q.Add("chat_id", strconv.Itoa(chatID))
+	if caption != "" {
+		q.Add("caption", caption)
+	}
+
+	c.limiter.wait(chatID)
+
+	resp, err := c.doUpload(sendDocument, q, "document", fileName, data)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+
+	var res Response
+
+	err = json.Unmarshal(resp, &res)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	if !res.Ok {
+		return fmt.Errorf("telegram API returned ok=false: %s", res.Description)
+	}
+
+	return nil
+}
+
+// SendAsFile delivers a message as a document instead of text. HTML texts are
+// sent as an .html page that keeps links clickable, anything else as a .txt file.
+// It matches the signature expected by SetFileFallback.
+func (c *Client) SendAsFile(chatID int, text, parseMode string) error {
+	if parseMode == ParseModeHTML {
+		page := "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"></head>\n" +
+			"<body style=\"white-space: pre-wrap\">" + text + "</body></html>\n"
+		return c.SendDocument(chatID, "message.html", []byte(page), "")
+	}
+
+	return c.SendDocument(chatID, "message.txt", []byte(text), "")
+}
+
+// GetFile returns the information needed to download a file sent to the bot.
+func (c *Client) GetFile(fileID string) (*File, error) {
+	q := url.Values{}
+	q.Add("file_id", fileID)
+
+	data, err := c.doRequest(getFile, q)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+
+	var res FileResponse
+
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	if !res.Ok {
+		return nil, fmt.Errorf("telegram API returned ok=false")
+	}
+
+	return &res.Result, nil
+}
+
+// GetChatMember returns the membership of a user in a chat.
+func (c *Client) GetChatMember(chatID, userID int) (*ChatMember, error) {
+	q := url.Values{}
+	q.Add("chat_id", strconv.Itoa(chatID))
+	q.Add("user_id", strconv.Itoa(userID))
+
+	data, err := c.doRequest(getChatMember, q)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+
+	var res ChatMemberResponse
+
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	if !res.Ok {
+		return nil, fmt.Errorf("telegram API returned ok=false")
+	}
+
+	return &res.Result, nil
+}
+
+// DownloadFile downloads a file by the path returned from GetFile.
+// Files larger than maxSize bytes are rejected with ErrFileTooLarge.
+func (c *Client) DownloadFile(filePath string, maxSize int64) ([]byte, error) {
 	u := url.URL{
 		Scheme: c.scheme,
 		Host:   c.host,
-		Path:   path.Join(c.basePath, method),
+		Path:   path.Join("file", c.basePath, filePath),
 	}
 
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("DownloadFile: create request failed: %v", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("DownloadFile: request execution failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DownloadFile: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("DownloadFile: read response failed: %v", err)
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	return data, nil
+}
+
+// doRequest performs an HTTP GET request to the Telegram API using the given method and query parameters.
+func (c *Client) doRequest(method string, query url.Values) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, c.methodURL(method), nil)
 	if err != nil {
 		return nil, fmt.Errorf("doRequest [%s]: create request failed: %v", method, err)
 	}
 
 	req.URL.RawQuery = query.Encode()
 
+	return c.do(method, req)
+}
+
+// doUpload performs a multipart/form-data POST request to the Telegram API,
+// sending the fields together with a single file.
+func (c *Client) doUpload(method string, fields url.Values, fileField, fileName string, data []byte) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for key, values := range fields {
+		for _, v := range values {
+			err := mw.WriteField(key, v)
+			if err != nil {
+				return nil, fmt.Errorf("doUpload [%s]: write field failed: %v", method, err)
+			}
+		}
+	}
+
+	fw, err := mw.CreateFormFile(fileField, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("doUpload [%s]: create file part failed: %v", method, err)
+	}
+
+	_, err = fw.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("doUpload [%s]: write file failed: %v", method, err)
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return nil, fmt.Errorf("doUpload [%s]: close multipart writer failed: %v", method, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.methodURL(method), &body)
+	if err != nil {
+		return nil, fmt.Errorf("doUpload [%s]: create request failed: %v", method, err)
+	}
+
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return c.do(method, req)
+}
+
+// methodURL returns the URL of a Bot API method.
+func (c *Client) methodURL(method string) string {
+	u := url.URL{
+		Scheme: c.scheme,
+		Host:   c.host,
+		Path:   path.Join(c.basePath, method),
+	}
+
+	return u.String()
+}
+
+// do executes a prepared request and returns the response body.
+func (c *Client) do(method string, req *http.Request) ([]byte, error) {
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("doRequest [%s]: request execution failed: %v", method, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("doRequest [%s]: %w", method, ErrUnauthorized)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("doRequest [%s]: unexpected status %d", method, resp.StatusCode)
 	}
@@ -1,6 +1,8 @@
 package telegram
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -84,4 +86,232 @@ func TestClient_SendMessage(t *testing.T) {
 	if receivedQuery.Get("chat_id") != "101" || receivedQuery.Get("text") != "your test" {
 		t.Errorf("unexpected query: %v", receivedQuery)
 	}
+
+	err = client.SendMessage(101, "<b>bold</b>", WithParseMode(ParseModeHTML), WithoutPreview(), WithReplyTo(5))
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	if receivedQuery.Get("parse_mode") != "HTML" ||
+		receivedQuery.Get("disable_web_page_preview") != "true" ||
+		receivedQuery.Get("reply_to_message_id") != "5" {
+		t.Errorf("unexpected query with options: %v", receivedQuery)
+	}
+
+	err = client.SendMessage(101, "accept?", WithInlineKeyboard([]InlineKeyboardButton{{Text: "Yes", CallbackData: "yes"}}))
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	want := `{"inline_keyboard":[[{"text":"Yes","callback_data":"yes"}]]}`
+	if got := receivedQuery.Get("reply_markup"); got != want {
+		t.Errorf("unexpected reply_markup: got %s, want %s", got, want)
+	}
+}
+
+func TestClient_AnswerCallbackQuery(t *testing.T) {
+	var receivedQuery url.Values
+	var receivedPath string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedQuery = r.URL.Query()
+		receivedPath = r.URL.Path
+		_, _ = w.Write([]byte(`{"ok": true, "result": true}`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse failed: %v", err)
+	}
+
+	client := NewClient(u.Scheme, u.Host, "test-token")
+	err = client.AnswerCallbackQuery("42", "Done")
+	if err != nil {
+		t.Fatalf("AnswerCallbackQuery failed: %v", err)
+	}
+
+	if receivedPath != "/bottest-token/answerCallbackQuery" {
+		t.Errorf("unexpected path: got %s", receivedPath)
+	}
+
+	if receivedQuery.Get("callback_query_id") != "42" || receivedQuery.Get("text") != "Done" {
+		t.Errorf("unexpected query: %v", receivedQuery)
+	}
+}
+
+func TestClient_GetChatMember(t *testing.T) {
+	var receivedQuery url.Values
+	var receivedPath string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedQuery = r.URL.Query()
+		receivedPath = r.URL.Path
+		_, _ = w.Write([]byte(`{"ok": true, "result": {"status": "administrator", "user": {"id": 7, "username": "alex"}}}`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse failed: %v", err)
+	}
+
+	client := NewClient(u.Scheme, u.Host, "test-token")
+	member, err := client.GetChatMember(-100, 7)
+	if err != nil {
+		t.Fatalf("GetChatMember failed: %v", err)
+	}
+
+	if receivedPath != "/bottest-token/getChatMember" {
+		t.Errorf("unexpected path: got %s", receivedPath)
+	}
+
+	if receivedQuery.Get("chat_id") != "-100" || receivedQuery.Get("user_id") != "7" {
+		t.Errorf("unexpected query: %v", receivedQuery)
+	}
+
+	if !member.IsAdmin() || member.User.Username != "alex" {
+		t.Errorf("unexpected member: %+v", member)
+	}
+}
+
+func TestClient_SetMyCommands(t *testing.T) {
+	var receivedQuery url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedQuery = r.URL.Query()
+
+		if r.URL.Path == "/bottest-token/getMyCommands" {
+			_, _ = w.Write([]byte(`{"ok": true, "result": [{"command": "help", "description": "Help"}]}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"ok": true, "result": true}`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse failed: %v", err)
+	}
+
+	client := NewClient(u.Scheme, u.Host, "test-token")
+	scope := BotCommandScope{Type: ScopeAllGroupChats}
+
+	err = client.SetMyCommands([]BotCommand{{Command: "help", Description: "Help"}}, scope, "ru")
+	if err != nil {
+		t.Fatalf("SetMyCommands failed: %v", err)
+	}
+
+	if receivedQuery.Get("commands") != `[{"command":"help","description":"Help"}]` ||
+		receivedQuery.Get("scope") != `{"type":"all_group_chats"}` ||
+		receivedQuery.Get("language_code") != "ru" {
+		t.Errorf("unexpected query: %v", receivedQuery)
+	}
+
+	commands, err := client.GetMyCommands(scope, "")
+	if err != nil {
+		t.Fatalf("GetMyCommands failed: %v", err)
+	}
+
+	if len(commands) != 1 || commands[0].Command != "help" {
+		t.Errorf("unexpected commands: %+v", commands)
+	}
+}
+
+func TestClient_GetMe(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantUser string
+		wantErr  error
+	}{
+		{
+			name:     "valid token",
+			status:   http.StatusOK,
+			body:     `{"ok": true, "result": {"id": 1, "is_bot": true, "first_name": "Namnada", "username": "NamnadaBot"}}`,
+			wantUser: "NamnadaBot",
+		},
+		{
+			name:    "invalid token",
+			status:  http.StatusUnauthorized,
+			body:    `{"ok": false, "error_code": 401, "description": "Unauthorized"}`,
+			wantErr: ErrUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatalf("url.Parse failed: %v", err)
+			}
+
+			client := NewClient(u.Scheme, u.Host, "test-token")
+			me, err := client.GetMe()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetMe() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetMe() failed: %v", err)
+			}
+
+			if me.Username != tt.wantUser {
+				t.Errorf("GetMe() username = %q, want %q", me.Username, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestClient_SendDocument(t *testing.T) {
+	var chatID, caption, fileName, content string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/bottest-token/sendDocument" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+
+		err := r.ParseMultipartForm(1 << 20)
+		if err != nil {
+			t.Errorf("ParseMultipartForm failed: %v", err)
+		}
+
+		chatID, caption = r.FormValue("chat_id"), r.FormValue("caption")
+
+		file, header, err := r.FormFile("document")
+		if err != nil {
+			t.Errorf("FormFile failed: %v", err)
+		} else {
+			data, _ := io.ReadAll(file)
+			fileName, content = header.Filename, string(data)
+		}
+
+		_, _ = w.Write([]byte(`{"ok": true, "result": {}}`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse failed: %v", err)
+	}
+
+	client := NewClient(u.Scheme, u.Host, "test-token")
+
+	err = client.SendDocument(42, "list.csv", []byte("url\nhttps://a.com\n"), "Your list")
+	if err != nil {
+		t.Fatalf("SendDocument failed: %v", err)
+	}
+
+	if chatID != "42" || caption != "Your list" || fileName != "list.csv" || content != "url\nhttps://a.com\n" {
+		t.Errorf("unexpected upload: chat=%q caption=%q file=%q content=%q", chatID, caption, fileName, content)
+	}
 }
@@ -7,26 +7,175 @@ type UpdateResponse struct {
 	Result []Update `json:"result"`
 }
 
-// Update represents a single update from Telegram, typically a new incoming message.
+// Update represents a single update from Telegram. At most one of the optional
+// fields is set, depending on what kind of update it is.
 type Update struct {
-	ID      int      `json:"update_id"`
+	ID                int            `json:"update_id"`
+	Message           *Message       `json:"message"`
+	EditedMessage     *Message       `json:"edited_message"`
+	ChannelPost       *Message       `json:"channel_post"`
+	EditedChannelPost *Message       `json:"edited_channel_post"`
+	CallbackQuery     *CallbackQuery `json:"callback_query"`
+}
+
+// CallbackQuery is sent when a user presses a button of an inline keyboard.
+// Message is the message the keyboard was attached to, if it is still available.
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	From    From     `json:"from"`
 	Message *Message `json:"message"`
+	Data    string   `json:"data"`
+}
+
+// InlineKeyboardButton is a button of an inline keyboard that sends
+// CallbackData back to the bot as a CallbackQuery when pressed.
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
 }
 
 // Message represents a Telegram message sent by a user, including the text, from and chat info.
 type Message struct {
-	Text string `json:"text"`
-	From From   `json:"From"`
-	Chat Chat   `json:"chat"`
+	ID            int            `json:"message_id"`
+	Text          string         `json:"text"`
+	Caption       string         `json:"caption"`
+	Document      *Document      `json:"document"`
+	From          From           `json:"From"`
+	Chat          Chat           `json:"chat"`
+	ForwardOrigin *MessageOrigin `json:"forward_origin"`
+}
+
+// UserResponse represents the response of getMe.
+type UserResponse struct {
+	Ok     bool `json:"ok"`
+	Result User `json:"result"`
+}
+
+// User represents a Telegram user or bot account.
+type User struct {
+	ID        int    `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username"`
+}
+
+// Document represents a general file attached to a message.
+type Document struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	FileSize int64  `json:"file_size"`
+}
+
+// FileResponse represents the response of getFile.
+type FileResponse struct {
+	Ok     bool `json:"ok"`
+	Result File `json:"result"`
+}
+
+// File describes a file ready to be downloaded with Client.DownloadFile.
+type File struct {
+	FileID   string `json:"file_id"`
+	FileSize int64  `json:"file_size"`
+	FilePath string `json:"file_path"`
 }
 
 // From represents the sender of a Telegram message.
-// It contains the Telegram username of the user who sent the message.
+// It contains the Telegram ID and username of the user who sent the message.
 type From struct {
+	ID       int    `json:"id"`
 	Username string `json:"username"`
 }
 
 // Chat represents information about the chat where the message was sent.
 type Chat struct {
-	ID int `json:"id"`
+	ID       int    `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Username string `json:"username"`
+}
+
+// Supported values of Chat.Type.
+const (
+	ChatPrivate    = "private"
+	ChatGroup      = "group"
+	ChatSupergroup = "supergroup"
+	ChatChannel    = "channel"
+)
+
+// Supported values of MessageOrigin.Type.
+const (
+	OriginUser       = "user"
+	OriginHiddenUser = "hidden_user"
+	OriginChat       = "chat"
+	OriginChannel    = "channel"
+)
+
+// MessageOrigin describes where a forwarded message originally came from.
+// Depending on Type, only some of the fields are filled.
+type MessageOrigin struct {
+	Type           string `json:"type"`
+	Date           int64  `json:"date"`
+	SenderUser     *From  `json:"sender_user"`
+	SenderUserName string `json:"sender_user_name"`
+	SenderChat     *Chat  `json:"sender_chat"`
+	Chat           *Chat  `json:"chat"`
+}
+
+// ChatMemberResponse represents the response of getChatMember.
+type ChatMemberResponse struct {
+	Ok     bool       `json:"ok"`
+	Result ChatMember `json:"result"`
+}
+
+// ChatMember describes the membership of a user in a chat.
+type ChatMember struct {
+	Status string `json:"status"`
+	User   User   `json:"user"`
+}
+
+// Values of ChatMember.Status for chat administrators.
+const (
+	MemberCreator       = "creator"
+	MemberAdministrator = "administrator"
+)
+
+// IsAdmin reports whether the member is an administrator or the creator of the chat.
+func (m *ChatMember) IsAdmin() bool {
+	return m.Status == MemberCreator || m.Status == MemberAdministrator
+}
+
+// Response is the generic envelope of a Telegram Bot API response
+// for methods whose result is not needed.
+type Response struct {
+	Ok          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// CommandsResponse represents the response of getMyCommands.
+type CommandsResponse struct {
+	Ok     bool         `json:"ok"`
+	Result []BotCommand `json:"result"`
+}
+
+// BotCommand represents a single entry of the bot's command menu.
+// Command is the command name without the leading slash.
+type BotCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// Supported values of BotCommandScope.Type.
+const (
+	ScopeDefault         = "default"
+	ScopeAllPrivateChats = "all_private_chats"
+	ScopeAllGroupChats   = "all_group_chats"
+	ScopeChat            = "chat"
+)
+
+// BotCommandScope defines the chats a command menu applies to.
+// ChatID is used only with ScopeChat.
+type BotCommandScope struct {
+	Type   string `json:"type"`
+	ChatID int    `json:"chat_id,omitempty"`
 }
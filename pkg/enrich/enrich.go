@@ -0,0 +1,383 @@
+// Package enrich fetches saved pages in the background and stores their
+// metadata, such as the title and description, on storage.Page.
+package enrich
+
+import (
+	"URLbot/pkg/safehttp"
+	"URLbot/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var ErrTooLarge = errors.New("page is too large")
+
+// StatusError is returned by Fetcher.Fetch for responses other than 200 OK.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.Code)
+}
+
+// userAgent identifies the bot to the sites it fetches.
+const userAgent = "NamnadaLinkBot/1.0 (+https://github.com/namnadaa/namnada-link)"
+
+// Document is a fetched web page.
+type Document struct {
+	URL         *url.URL // Final URL after redirects.
+	ContentType string   // Media type without parameters, e.g. "text/html".
+	Body        []byte
+}
+
+// IsHTML reports whether the document is an HTML page.
+func (d *Document) IsHTML() bool {
+	return d.ContentType == "text/html" || d.ContentType == "application/xhtml+xml"
+}
+
+// Fetcher downloads web pages with a timeout and a size cap. It only connects
+// to public addresses, see package safehttp.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewFetcher creates a Fetcher. Requests time out after timeout,
+// and bodies larger than maxBytes are rejected with ErrTooLarge.
+func NewFetcher(timeout time.Duration, maxBytes int64) *Fetcher {
+	return &Fetcher{
+		client:   safehttp.NewClient(timeout),
+		maxBytes: maxBytes,
+	}
+}
+
+// SetTransport replaces the guarded transport, e.g. with one that also
+// allows a local test server.
+func (f *Fetcher) SetTransport(t http.RoundTripper) {
+	f.client.Transport = t
+}
+
+// Fetch downloads the page at pageURL.
+func (f *Fetcher) Fetch(ctx context.Context, pageURL string) (*Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %v", err)
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request execution failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read response failed: %v", err)
+	}
+
+	if int64(len(body)) > f.maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	return &Document{
+		URL:         resp.Request.URL,
+		ContentType: contentType,
+		Body:        body,
+	}, nil
+}
+
+// Updater stores enriched pages. storage.Storage satisfies it.
+type Updater interface {
+	Update(userName, pageURL string, fn func(p *storage.Page)) error
+}
+
+// Lister lists every stored page for the backfill pass. storage.Storage satisfies it.
+type Lister interface {
+	ListAll() ([]*storage.Page, error)
+}
+
+// defaultQueueSize is how many pages may wait for enrichment before new ones
+// are dropped and left for the backfill pass.
+const defaultQueueSize = 1000
+
+// Pipeline enriches pages asynchronously with a pool of workers.
+// Pages that fail to be fetched because of network errors, server errors or
+// rate limiting are retried with exponential backoff; other failures, such as
+// 404 Not Found, are given up at once.
+//
+// The queue is bounded. When it is full, pages are dropped with a warning and
+// stay un-enriched until the backfill pass, if one is set, queues them again.
+type Pipeline struct {
+	fetcher     *Fetcher
+	store       Updater
+	archiver    *Archiver
+	workers     int
+	maxAttempts int
+	backoff     time.Duration
+
+	lister   Lister
+	backfill time.Duration
+
+	queue chan job
+}
+
+// job is a page waiting to be enriched.
+type job struct {
+	page    *storage.Page
+	attempt int
+}
+
+// NewPipeline creates a Pipeline that fetches pages with fetcher and saves
+// the results to store, running the given number of workers.
+func NewPipeline(fetcher *Fetcher, store Updater, workers int) *Pipeline {
+	return &Pipeline{
+		fetcher:     fetcher,
+		store:       store,
+		workers:     workers,
+		maxAttempts: 5,
+		backoff:     time.Minute,
+		queue:       make(chan job, defaultQueueSize),
+	}
+}
+
+// SetRetry configures how many times a page is attempted and the delay
+// before the first retry. The delay doubles after every failure.
+func (p *Pipeline) SetRetry(maxAttempts int, backoff time.Duration) {
+	p.maxAttempts = maxAttempts
+	p.backoff = backoff
+}
+
+// SetQueueSize changes how many pages may wait for enrichment.
+// It must be called before the first Enqueue.
+func (p *Pipeline) SetQueueSize(n int) {
+	p.queue = make(chan job, n)
+}
+
+// SetBackfill makes Run queue every page that has not been enriched yet once
+// per interval, such as pages dropped because the queue was full.
+func (p *Pipeline) SetBackfill(l Lister, interval time.Duration) {
+	p.lister = l
+	p.backfill = interval
+}
+
+// SetArchiver enables offline snapshots: every page fetched by the pipeline
+// is also archived with a.
+func (p *Pipeline) SetArchiver(a *Archiver) {
+	p.archiver = a
+}
+
+// Enqueue schedules a page for enrichment. It never blocks; if the queue is
+// full, the page is dropped.
+func (p *Pipeline) Enqueue(page *storage.Page) {
+	p.push(job{page: page.Clone()})
+}
+
+// Run processes queued pages until the context is canceled.
+func (p *Pipeline) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+
+	if p.lister != nil && p.backfill > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.runBackfill(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// work takes jobs from the queue until the context is canceled.
+func (p *Pipeline) work(ctx context.Context) {
+	for {
+		var j job
+		select {
+		case <-ctx.Done():
+			return
+		case j = <-p.queue:
+		}
+
+		err := p.enrich(ctx, j.page)
+		if err == nil {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		j.attempt++
+		if !temporary(err) {
+			slog.Warn("enrich: failed to fetch page", "url", j.page.URL, "err", err)
+			continue
+		}
+
+		if j.attempt >= p.maxAttempts {
+			slog.Warn("enrich: giving up on page", "url", j.page.URL, "attempts", j.attempt, "err", err)
+			continue
+		}
+
+		delay := p.backoff << (j.attempt - 1)
+		slog.Info("enrich: will retry page", "url", j.page.URL, "in", delay, "err", err)
+		time.AfterFunc(delay, func() { p.push(j) })
+	}
+}
+
+// runBackfill queues the pages that are not enriched yet every backfill
+// interval until the context is canceled.
+func (p *Pipeline) runBackfill(ctx context.Context) {
+	ticker := time.NewTicker(p.backfill)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pages, err := p.lister.ListAll()
+		if err != nil {
+			slog.Error("enrich: backfill failed", "err", err)
+			continue
+		}
+
+		queued := 0
+		for _, page := range pages {
+			if !page.EnrichedAt.IsZero() || !page.DeletedAt.IsZero() {
+				continue
+			}
+			if !p.push(job{page: page}) {
+				break
+			}
+			queued++
+		}
+
+		if queued > 0 {
+			slog.Info("enrich: backfill queued pages", "pages", queued)
+		}
+	}
+}
+
+// temporary reports whether a failed enrichment may succeed later:
+// network errors, 5xx responses and 429 Too Many Requests are retried.
+func temporary(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= http.StatusInternalServerError || statusErr.Code == http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, safehttp.ErrForbiddenAddress) {
+		return false
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr)
+}
+
+// enrich fetches a single page and stores its metadata.
+func (p *Pipeline) enrich(ctx context.Context, page *storage.Page) error {
+	doc, err := p.fetcher.Fetch(ctx, page.URL)
+	if err != nil {
+		return err
+	}
+
+	var md Metadata
+	var text string
+	switch {
+	case doc.IsHTML():
+		md = ParseHTML(doc.Body, doc.URL)
+		text = ExtractText(doc.Body)
+	case doc.ContentType == "text/plain":
+		text = string(doc.Body)
+	}
+	md.WordCount = CountWords(text)
+	md.ReadingTime = ReadingTime(md.WordCount)
+
+	var snapshotKey string
+	if p.archiver != nil {
+		snapshotKey, err = p.archiver.Archive(page, doc, md, text)
+		if err != nil {
+			// The metadata is still worth saving without a snapshot.
+			slog.Warn("enrich: failed to archive page", "url", page.URL, "err", err)
+		}
+	}
+
+	now := time.Now()
+	err = p.store.Update(page.UserName, page.URL, func(stored *storage.Page) {
+		Apply(stored, md)
+		stored.EnrichedAt = now
+		if snapshotKey != "" {
+			stored.SnapshotKey = snapshotKey
+			stored.SnapshotAt = now
+		}
+	})
+	if errors.Is(err, storage.ErrNoPagesFound) {
+		// The page was removed while it was being fetched.
+		return nil
+	}
+
+	return err
+}
+
+// Apply copies the known metadata fields onto the page.
+func Apply(page *storage.Page, md Metadata) {
+	if md.Title != "" {
+		page.Title = md.Title
+	}
+	if md.Description != "" {
+		page.Description = md.Description
+	}
+	if md.SiteName != "" {
+		page.SiteName = md.SiteName
+	}
+	if md.CanonicalURL != "" {
+		page.CanonicalURL = md.CanonicalURL
+	}
+	if md.Language != "" {
+		page.Language = md.Language
+	}
+	if md.WordCount > 0 {
+		page.WordCount = md.WordCount
+		page.ReadingTime = md.ReadingTime
+	}
+}
+
+// push adds a job to the queue without blocking. It reports false and drops
+// the job if the queue is full.
+func (p *Pipeline) push(j job) bool {
+	select {
+	case p.queue <- j:
+		return true
+	default:
+		slog.Warn("enrich: queue is full, page left for a later pass", "url", j.page.URL)
+		return false
+	}
+}
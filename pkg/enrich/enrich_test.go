@@ -0,0 +1,420 @@
+package enrich_test
+
+import (
+	"URLbot/pkg/blob/filesystem"
+	"URLbot/pkg/enrich"
+	"URLbot/pkg/safehttp"
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseHTML(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1?utm=x")
+
+	tests := []struct {
+		name string
+		html string
+		want enrich.Metadata
+	}{
+		{
+			name: "plain title and description",
+			html: `<html lang="en"><head><title>
+				Hello &amp; welcome </title>
+				<meta name="description" content="A short post"></head><body><title>Not this</title></body></html>`,
+			want: enrich.Metadata{
+				Title:       "Hello & welcome",
+				Description: "A short post",
+				Language:    "en",
+			},
+		},
+		{
+			name: "open graph takes precedence",
+			html: `<head>
+				<title>Plain</title>
+				<meta content="OG title" property="og:title">
+				<meta property='og:description' content='OG "description"'>
+				<meta name="description" content="Plain description">
+				<meta property="og:site_name" content="Example Blog">
+				<meta property="og:locale" content="ru_RU">
+				<link rel="stylesheet" href="/style.css">
+				<link rel="canonical" href="/posts/1">
+			</head>`,
+			want: enrich.Metadata{
+				Title:        "OG title",
+				Description:  `OG "description"`,
+				SiteName:     "Example Blog",
+				CanonicalURL: "https://example.com/posts/1",
+				Language:     "ru-RU",
+			},
+		},
+		{
+			name: "no metadata",
+			html: `<p>just text</p>`,
+			want: enrich.Metadata{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := enrich.ParseHTML([]byte(tt.html), base)
+			if got != tt.want {
+				t.Errorf("ParseHTML() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "article wins over page chrome",
+			html: `<html><head><title>T</title></head><body>
+				<header><a href="/">Home</a></header>
+				<nav><ul><li><a href="/a">A</a></li><li><a href="/b">B</a></li></ul></nav>
+				<article><h1>Title</h1><p>First <b>bold</b> paragraph &amp; more.</p>
+				<script>var x = "<p>not text</p>";</script>
+				<p>Second<br>line</p></article>
+				<footer>Copyright</footer></body></html>`,
+			want: "Title\n\nFirst bold paragraph & more.\n\nSecond\n\nline",
+		},
+		{
+			name: "main element",
+			html: `<body><div>Sidebar</div><main><p>Main text</p></main></body>`,
+			want: "Main text",
+		},
+		{
+			name: "whole body without link-heavy blocks",
+			html: `<body><div><a href="/1">Menu one</a> | <a href="/2">Menu two</a></div><p>Read <a href="/x">this</a> carefully, please.</p><!-- <p>hidden</p> --></body>`,
+			want: "Read this carefully, please.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := enrich.ExtractText([]byte(tt.html))
+			if got != tt.want {
+				t.Errorf("ExtractText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	tests := []struct {
+		words int
+		want  time.Duration
+	}{
+		{words: 0, want: 0},
+		{words: 1, want: time.Minute},
+		{words: 200, want: time.Minute},
+		{words: 201, want: 2 * time.Minute},
+		{words: 4000, want: 20 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := enrich.ReadingTime(tt.words); got != tt.want {
+			t.Errorf("ReadingTime(%d) = %v, want %v", tt.words, got, tt.want)
+		}
+	}
+}
+
+// newFetcher creates a Fetcher that may connect to local test servers.
+func newFetcher(maxBytes int64) *enrich.Fetcher {
+	f := enrich.NewFetcher(time.Second, maxBytes)
+	f.SetTransport(safehttp.NewTransport(netip.MustParsePrefix("127.0.0.0/8")))
+
+	return f
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<title>ok</title>"))
+		}
+	}))
+	defer server.Close()
+
+	f := newFetcher(50)
+
+	doc, err := f.Fetch(context.Background(), server.URL+"/page")
+	if err != nil {
+		t.Fatalf("Fetch() failed: %v", err)
+	}
+	if !doc.IsHTML() || string(doc.Body) != "<title>ok</title>" {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+
+	_, err = f.Fetch(context.Background(), server.URL+"/big")
+	if !errors.Is(err, enrich.ErrTooLarge) {
+		t.Errorf("Fetch() of a large page error = %v, want %v", err, enrich.ErrTooLarge)
+	}
+
+	_, err = f.Fetch(context.Background(), server.URL+"/missing")
+	var statusErr *enrich.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Errorf("Fetch() of a missing page error = %v, want status 404", err)
+	}
+}
+
+func TestPipeline_permanentError(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	s := memory.New()
+	page := &storage.Page{URL: server.URL + "/gone", UserName: "Alex"}
+
+	err := s.Save(page)
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	p := enrich.NewPipeline(newFetcher(1<<20), s, 1)
+	p.SetRetry(3, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	p.Enqueue(page)
+	p.Run(ctx)
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("missing page was fetched %d times, want 1", n)
+	}
+}
+
+func TestFetcher_RefusesPrivateAddresses(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	f := enrich.NewFetcher(time.Second, 1<<20)
+
+	_, err := f.Fetch(context.Background(), server.URL)
+	if !errors.Is(err, safehttp.ErrForbiddenAddress) {
+		t.Errorf("Fetch() of %s error = %v, want %v", server.URL, err, safehttp.ErrForbiddenAddress)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("internal server got %d requests, want 0", n)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<head><meta property="og:title" content="Fetched title"></head><body><p>One two three</p></body>`))
+	}))
+	defer server.Close()
+
+	s := memory.New()
+	page := &storage.Page{URL: server.URL + "/article", UserName: "Alex"}
+
+	err := s.Save(page)
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	p := enrich.NewPipeline(newFetcher(1<<20), s, 2)
+	p.SetRetry(3, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	p.Enqueue(page)
+
+	for {
+		pages, err := s.List("Alex")
+		if err != nil {
+			t.Fatalf("List() failed: %v", err)
+		}
+
+		if pages[0].Title == "Fetched title" {
+			if pages[0].EnrichedAt.IsZero() {
+				t.Error("EnrichedAt is not set")
+			}
+			if pages[0].WordCount != 3 || pages[0].ReadingTime != time.Minute {
+				t.Errorf("WordCount = %d, ReadingTime = %v, want 3 and 1m", pages[0].WordCount, pages[0].ReadingTime)
+			}
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("page was not enriched in time")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("page was fetched %d times, want 2", got)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestPipeline_queueFull(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<head><title>Fetched title</title></head>`))
+	}))
+	defer server.Close()
+
+	s := memory.New()
+	var pages []*storage.Page
+	for _, path := range []string{"/first", "/second"} {
+		page := &storage.Page{URL: server.URL + path, UserName: "Alex"}
+		if err := s.Save(page); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		pages = append(pages, page)
+	}
+
+	p := enrich.NewPipeline(newFetcher(1<<20), s, 1)
+	p.SetQueueSize(1)
+	p.SetBackfill(s, 50*time.Millisecond)
+
+	// The second page does not fit in the queue and waits for the backfill pass.
+	p.Enqueue(pages[0])
+	p.Enqueue(pages[1])
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	for _, page := range pages {
+		for {
+			stored, err := s.Get("Alex", page.ID)
+			if err != nil {
+				t.Fatalf("Get() failed: %v", err)
+			}
+			if !stored.EnrichedAt.IsZero() {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Fatalf("%s was not enriched in time", page.URL)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func TestPipeline_archive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<html lang="en"><head><title>Tips &amp; tricks</title></head><body>
+			<article><p>Use <b>gofmt</b> &lt;always&gt;.</p><script>alert(1)</script><p>Second paragraph</p></article>
+			</body></html>`))
+	}))
+	defer server.Close()
+
+	blobs, err := filesystem.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("filesystem.New() failed: %v", err)
+	}
+
+	s := memory.New()
+	page := &storage.Page{URL: server.URL, UserName: "Alex"}
+
+	err = s.Save(page)
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	p := enrich.NewPipeline(newFetcher(1<<20), s, 1)
+	p.SetArchiver(enrich.NewArchiver(blobs))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	p.Enqueue(page)
+
+	var stored *storage.Page
+	for stored == nil || stored.SnapshotKey == "" {
+		select {
+		case <-ctx.Done():
+			t.Fatal("page was not archived in time")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		stored, err = s.Get("Alex", page.ID)
+		if err != nil {
+			t.Fatalf("Get() failed: %v", err)
+		}
+	}
+
+	cancel()
+	<-done
+
+	data, err := blobs.Get(stored.SnapshotKey)
+	if err != nil {
+		t.Fatalf("blob Get() failed: %v", err)
+	}
+
+	snapshot := string(data)
+	for _, want := range []string{`<html lang="en">`, "<title>Tips &amp; tricks</title>", "<p>Use gofmt &lt;always&gt;.</p>", "<p>Second paragraph</p>"} {
+		if !strings.Contains(snapshot, want) {
+			t.Errorf("snapshot does not contain %q:\n%s", want, snapshot)
+		}
+	}
+	if strings.Contains(snapshot, "alert") {
+		t.Errorf("snapshot contains a script:\n%s", snapshot)
+	}
+}
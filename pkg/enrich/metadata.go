@@ -0,0 +1,132 @@
+package enrich
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Metadata describes a web page: the fields found in its HTML head and
+// the length of its readable text.
+type Metadata struct {
+	Title        string
+	Description  string
+	SiteName     string
+	CanonicalURL string
+	Language     string
+	WordCount    int
+	ReadingTime  time.Duration
+}
+
+var (
+	titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaRe  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	linkRe  = regexp.MustCompile(`(?is)<link\s[^>]*>`)
+	htmlRe  = regexp.MustCompile(`(?is)<html\s[^>]*>`)
+	attrRe  = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	spaceRe = regexp.MustCompile(`\s+`)
+)
+
+// ParseHTML extracts page metadata from an HTML document. Open Graph tags take
+// precedence over the plain <title> and description. Relative canonical URLs
+// are resolved against base.
+func ParseHTML(data []byte, base *url.URL) Metadata {
+	doc := string(data)
+	if end := strings.Index(strings.ToLower(doc), "</head>"); end >= 0 {
+		doc = doc[:end]
+	}
+
+	var md Metadata
+
+	if m := titleRe.FindStringSubmatch(doc); m != nil {
+		md.Title = clean(m[1])
+	}
+
+	for _, tag := range metaRe.FindAllString(doc, -1) {
+		attrs := parseAttrs(tag)
+
+		name := strings.ToLower(attrs["property"])
+		if name == "" {
+			name = strings.ToLower(attrs["name"])
+		}
+		content := clean(attrs["content"])
+
+		switch name {
+		case "og:title":
+			md.Title = content
+		case "og:description":
+			md.Description = content
+		case "description":
+			if md.Description == "" {
+				md.Description = content
+			}
+		case "og:site_name":
+			md.SiteName = content
+		case "og:locale":
+			if md.Language == "" {
+				md.Language = strings.ReplaceAll(content, "_", "-")
+			}
+		}
+	}
+
+	for _, tag := range linkRe.FindAllString(doc, -1) {
+		attrs := parseAttrs(tag)
+		if !hasToken(attrs["rel"], "canonical") || attrs["href"] == "" {
+			continue
+		}
+
+		md.CanonicalURL = resolve(base, attrs["href"])
+		break
+	}
+
+	if tag := htmlRe.FindString(doc); tag != "" {
+		if lang := parseAttrs(tag)["lang"]; lang != "" {
+			md.Language = lang
+		}
+	}
+
+	return md
+}
+
+// parseAttrs returns the attributes of an HTML tag, with lower-case names.
+func parseAttrs(tag string) map[string]string {
+	attrs := make(map[string]string)
+
+	for _, m := range attrRe.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+	}
+
+	return attrs
+}
+
+// hasToken reports whether a space-separated attribute value contains the token.
+func hasToken(value, token string) bool {
+	for _, f := range strings.Fields(value) {
+		if strings.EqualFold(f, token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolve makes ref absolute relative to base.
+func resolve(base *url.URL, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+
+	if base == nil {
+		return u.String()
+	}
+
+	return base.ResolveReference(u).String()
+}
+
+// clean unescapes HTML entities and collapses whitespace.
+func clean(s string) string {
+	return strings.TrimSpace(spaceRe.ReplaceAllString(html.UnescapeString(s), " "))
+}
@@ -0,0 +1,113 @@
+package enrich
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// wordsPerMinute is the reading speed used to estimate reading time.
+const wordsPerMinute = 200
+
+// maxLinkDensity is the share of link text above which a block is treated
+// as navigation rather than article content.
+const maxLinkDensity = 0.5
+
+var (
+	bodyRe    = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+	commentRe = regexp.MustCompile(`(?s)<!--.*?-->`)
+	articleRe = regexp.MustCompile(`(?is)<article[^>]*>(.*?)</article>`)
+	mainRe    = regexp.MustCompile(`(?is)<main[^>]*>(.*?)</main>`)
+	blockRe   = regexp.MustCompile(`(?i)</?(?:p|div|section|h[1-6]|ul|ol|li|pre|blockquote|table|tr|td|th|dl|dt|dd|figure|figcaption|br|hr)\b[^>]*>`)
+	anchorRe  = regexp.MustCompile(`(?is)<a\b[^>]*>(.*?)</a>`)
+	tagRe     = regexp.MustCompile(`(?s)<[^>]*>`)
+	boilerRes = boilerplate("script", "style", "noscript", "template", "svg", "iframe", "nav", "header", "footer", "aside", "form", "button", "select")
+)
+
+// boilerplate returns expressions matching the given elements with their content.
+func boilerplate(tags ...string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, 0, len(tags))
+	for _, tag := range tags {
+		res = append(res, regexp.MustCompile(`(?is)<`+tag+`\b[^>]*>.*?</`+tag+`\s*>`))
+	}
+
+	return res
+}
+
+// ExtractText returns the readable text of an HTML document: the content of
+// its largest <article> or <main> element, or of the whole body, without
+// scripts, navigation, headers, footers and link-heavy blocks such as menus.
+// Paragraphs are separated by blank lines.
+func ExtractText(data []byte) string {
+	doc := string(data)
+	if m := bodyRe.FindStringSubmatch(doc); m != nil {
+		doc = m[1]
+	}
+
+	doc = commentRe.ReplaceAllString(doc, "")
+	for _, re := range boilerRes {
+		doc = re.ReplaceAllString(doc, "")
+	}
+
+	doc = mainContent(doc)
+
+	var paragraphs []string
+	for _, block := range blockRe.Split(doc, -1) {
+		text := clean(tagRe.ReplaceAllString(block, ""))
+		if text == "" || linkDensity(block, text) > maxLinkDensity {
+			continue
+		}
+
+		paragraphs = append(paragraphs, text)
+	}
+
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// mainContent returns the largest <article> of the document, its <main>
+// element if there is no article, or the document itself.
+func mainContent(doc string) string {
+	var best string
+	for _, m := range articleRe.FindAllStringSubmatch(doc, -1) {
+		if len(m[1]) > len(best) {
+			best = m[1]
+		}
+	}
+
+	if best != "" {
+		return best
+	}
+
+	if m := mainRe.FindStringSubmatch(doc); m != nil {
+		return m[1]
+	}
+
+	return doc
+}
+
+// linkDensity returns the share of the block's text that is inside links.
+func linkDensity(block, text string) float64 {
+	links := 0
+	for _, m := range anchorRe.FindAllStringSubmatch(block, -1) {
+		links += len(clean(tagRe.ReplaceAllString(m[1], "")))
+	}
+
+	return float64(links) / float64(len(text))
+}
+
+// CountWords returns the number of words in text.
+func CountWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+// ReadingTime estimates how long it takes to read the given number of words,
+// rounded up to whole minutes. It returns zero only for an empty text.
+func ReadingTime(words int) time.Duration {
+	if words <= 0 {
+		return 0
+	}
+
+	minutes := (words + wordsPerMinute - 1) / wordsPerMinute
+
+	return time.Duration(minutes) * time.Minute
+}
@@ -0,0 +1,83 @@
+package enrich
+
+import (
+	"URLbot/pkg/blob"
+	"URLbot/pkg/storage"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Archiver stores offline copies of fetched pages in a blob store.
+type Archiver struct {
+	store blob.Store
+}
+
+// NewArchiver creates an Archiver that writes snapshots to store.
+func NewArchiver(store blob.Store) *Archiver {
+	return &Archiver{store: store}
+}
+
+// Archive stores a snapshot of the page built from its readable text and
+// returns the snapshot key. HTML pages are archived as sanitized HTML that
+// contains only the extracted paragraphs, other documents as plain text.
+// Nothing is stored for pages without an ID or without text.
+func (a *Archiver) Archive(page *storage.Page, doc *Document, md Metadata, text string) (string, error) {
+	if page.ID == 0 || strings.TrimSpace(text) == "" {
+		return "", nil
+	}
+
+	key := fmt.Sprintf("snapshots/%d.txt", page.ID)
+	data := []byte(text)
+
+	if doc.IsHTML() {
+		key = fmt.Sprintf("snapshots/%d.html", page.ID)
+		data = renderSnapshot(page.URL, md, text, time.Now())
+	}
+
+	err := a.store.Put(key, data)
+	if err != nil {
+		return "", fmt.Errorf("store snapshot failed: %v", err)
+	}
+
+	return key, nil
+}
+
+// renderSnapshot builds a standalone HTML document from the page title and
+// its paragraphs. All text is escaped, so no markup or scripts of the original
+// page make it into the snapshot.
+func renderSnapshot(pageURL string, md Metadata, text string, archivedAt time.Time) []byte {
+	title := md.Title
+	if title == "" {
+		title = pageURL
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString("<!DOCTYPE html>\n")
+	if md.Language != "" {
+		fmt.Fprintf(&builder, "<html lang=\"%s\">\n", html.EscapeString(md.Language))
+	} else {
+		builder.WriteString("<html>\n")
+	}
+	builder.WriteString("<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&builder, "<title>%s</title>\n", html.EscapeString(title))
+	builder.WriteString("</head>\n<body>\n")
+	fmt.Fprintf(&builder, "<h1>%s</h1>\n", html.EscapeString(title))
+
+	source := html.EscapeString(pageURL)
+	if u, err := url.Parse(pageURL); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		source = fmt.Sprintf("<a href=\"%s\">%s</a>", source, source)
+	}
+	fmt.Fprintf(&builder, "<p><small>%s<br>Archived %s</small></p>\n", source, archivedAt.UTC().Format(time.DateOnly))
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		fmt.Fprintf(&builder, "<p>%s</p>\n", html.EscapeString(paragraph))
+	}
+
+	builder.WriteString("</body>\n</html>\n")
+
+	return []byte(builder.String())
+}
@@ -0,0 +1,447 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"URLbot/pkg/format"
+	"URLbot/pkg/storage"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxCollectionName is the longest allowed collection name, in characters.
+const maxCollectionName = 64
+
+// collectionArgs holds the parsed arguments of /collection.
+type collectionArgs struct {
+	action string // "", "new", "rename", "delete", "show", "add", "remove", "move" or "order".
+	name   string // Collection the action applies to.
+	target string // New name for "rename", destination for "move".
+	id     int    // Page ID.
+	pos    int    // 1-based position, zero for the end.
+}
+
+// collection lists, creates, renames and deletes collections and moves pages
+// in, out of and within them. Collections shared by other users are referred
+// to as @owner/name; what a member may change is checked by the storage.
+func (p *Processor) collection(req *Request) error {
+	args := req.Args.(collectionArgs)
+	owner, name := collRef(req.Owner, args.name)
+
+	var err error
+	var msg string
+
+	switch args.action {
+	case "":
+		return p.sendCollections(req)
+	case "show":
+		return p.showCollection(req, args.name)
+	case "new":
+		_, err = p.storage.CreateCollection(req.Owner, args.name)
+		msg = fmt.Sprintf(msgCollCreatedFmt, format.HTML.Bold(args.name))
+	case "rename":
+		err = p.storage.UpdateCollection(req.Owner, owner, name, func(c *storage.Collection) {
+			c.Name = args.target
+		})
+		msg = fmt.Sprintf(msgCollRenamedFmt, format.HTML.Bold(args.target))
+	case "delete":
+		if owner != req.Owner {
+			return p.reply(req.Meta, msgCollForbidden)
+		}
+		err = p.storage.RemoveCollection(req.Owner, owner, name)
+		msg = fmt.Sprintf(msgCollDeletedFmt, format.HTML.Bold(args.name))
+	case "add", "order":
+		id := args.id
+		if args.action == "add" && owner != req.Owner {
+			id, err = p.addToShared(req, owner, name, args.id)
+		} else {
+			_, err = p.storage.Get(owner, args.id)
+		}
+		if err != nil {
+			if errors.Is(err, storage.ErrNoPagesFound) {
+				return p.reply(req.Meta, msgPageNotFound)
+			}
+
+			return p.collectionError(req, err, args.name)
+		}
+
+		pos := 0
+		found := true
+		err = p.storage.UpdateCollection(req.Owner, owner, name, func(c *storage.Collection) {
+			if args.action == "order" && !c.Contains(id) {
+				found = false
+				return
+			}
+
+			c.Insert(id, args.pos)
+			pos = len(c.PageIDs)
+			if args.pos > 0 && args.pos < pos {
+				pos = args.pos
+			}
+		})
+		if err == nil && !found {
+			return p.reply(req.Meta, fmt.Sprintf(msgNotInCollFmt, id, format.HTML.Bold(args.name)))
+		}
+		msg = fmt.Sprintf(msgCollPosFmt, id, pos, format.HTML.Bold(args.name))
+	case "remove":
+		found := false
+		err = p.storage.UpdateCollection(req.Owner, owner, name, func(c *storage.Collection) {
+			found = c.Remove(args.id)
+		})
+		if err == nil && !found {
+			return p.reply(req.Meta, fmt.Sprintf(msgNotInCollFmt, args.id, format.HTML.Bold(args.name)))
+		}
+		msg = fmt.Sprintf(msgCollRemovedFmt, args.id, format.HTML.Bold(args.name))
+	case "move":
+		return p.moveToCollection(req, args)
+	default:
+		return fmt.Errorf("unknown collection action %q", args.action)
+	}
+
+	if err != nil {
+		return p.collectionError(req, err, args.name)
+	}
+
+	return p.reply(req.Meta, msg)
+}
+
+// addToShared copies one of the user's pages into the list of the owner of a
+// shared collection and returns its ID there, since collections can only hold
+// pages of their owner. Members who can't change the collection get storage.ErrForbidden.
+func (p *Processor) addToShared(req *Request, owner, name string, id int) (int, error) {
+	c, err := p.storage.Collection(req.Owner, owner, name)
+	if err != nil {
+		return 0, err
+	}
+	if c.Access(req.Owner) < storage.Collaborator {
+		return 0, storage.ErrForbidden
+	}
+
+	page, err := p.storage.Get(req.Owner, id)
+	if err != nil {
+		return 0, err
+	}
+
+	cp := &storage.Page{
+		URL:         page.URL,
+		UserName:    owner,
+		AddedBy:     req.Meta.UserName,
+		Tags:        page.Tags,
+		Title:       page.Title,
+		Description: page.Description,
+		SiteName:    page.SiteName,
+		ReadingTime: page.ReadingTime,
+	}
+
+	err = p.storage.Save(cp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save page: %v", err)
+	}
+	if cp.ID != 0 {
+		p.service.Enqueue(cp)
+		return cp.ID, nil
+	}
+
+	// The owner already has the link.
+	pages, err := p.storage.List(owner)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pages: %v", err)
+	}
+	for _, stored := range pages {
+		if stored.URL == page.URL {
+			return stored.ID, nil
+		}
+	}
+
+	return 0, storage.ErrNoPagesFound
+}
+
+// moveToCollection takes a page out of one collection and appends it to another.
+// The destination is updated first, so a failure never loses the page.
+// Both collections must belong to the same owner, as page IDs are per owner.
+func (p *Processor) moveToCollection(req *Request, args collectionArgs) error {
+	owner, name := collRef(req.Owner, args.name)
+	targetOwner, target := collRef(req.Owner, args.target)
+
+	if owner != targetOwner {
+		return p.reply(req.Meta, msgCollMoveOwners)
+	}
+
+	source, err := p.storage.Collection(req.Owner, owner, name)
+	if err != nil {
+		return p.collectionError(req, err, args.name)
+	}
+
+	if !source.Contains(args.id) {
+		return p.reply(req.Meta, fmt.Sprintf(msgNotInCollFmt, args.id, format.HTML.Bold(args.name)))
+	}
+
+	err = p.storage.UpdateCollection(req.Owner, owner, target, func(c *storage.Collection) {
+		c.Insert(args.id, 0)
+	})
+	if err != nil {
+		return p.collectionError(req, err, args.target)
+	}
+
+	err = p.storage.UpdateCollection(req.Owner, owner, name, func(c *storage.Collection) {
+		c.Remove(args.id)
+	})
+	if err != nil {
+		return p.collectionError(req, err, args.name)
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgCollMovedFmt, args.id,
+		format.HTML.Bold(args.name), format.HTML.Bold(args.target)))
+}
+
+// collectionError replies with a message for the storage errors a user can cause
+// and wraps any other error.
+func (p *Processor) collectionError(req *Request, err error, name string) error {
+	switch {
+	case errors.Is(err, storage.ErrNoCollection):
+		return p.reply(req.Meta, fmt.Sprintf(msgNoCollFmt, format.HTML.Bold(name)))
+	case errors.Is(err, storage.ErrCollectionExists):
+		return p.reply(req.Meta, msgCollExists)
+	case errors.Is(err, storage.ErrForbidden):
+		return p.reply(req.Meta, msgCollForbidden)
+	}
+
+	return fmt.Errorf("failed to update collection: %v", err)
+}
+
+// sendCollections lists the collections the owner has or is a member of,
+// with the number of pages in each.
+func (p *Processor) sendCollections(req *Request) error {
+	colls, err := p.storage.Collections(req.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to get collections: %v", err)
+	}
+
+	if len(colls) == 0 {
+		return p.reply(req.Meta, msgNoCollections)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(format.HTML.Bold(msgCollsHeader) + "\n\n")
+
+	for _, c := range colls {
+		fmt.Fprintf(&builder, "• %s — %d", format.HTML.Escape(collDisplayName(req.Owner, c)), len(c.PageIDs))
+		switch perm := c.Access(req.Owner); {
+		case perm == storage.FullAccess && len(c.Members) > 0:
+			fmt.Fprintf(&builder, " 👥 %d", len(c.Members))
+		case perm != storage.FullAccess:
+			fmt.Fprintf(&builder, " (%s)", permissionNames[perm])
+		}
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("\n" + msgCollFooter)
+
+	return p.reply(req.Meta, builder.String())
+}
+
+// showCollection lists the pages of a collection in reading order.
+func (p *Processor) showCollection(req *Request, ref string) error {
+	owner, name := collRef(req.Owner, ref)
+
+	c, err := p.storage.Collection(req.Owner, owner, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoCollection) {
+			return p.reply(req.Meta, fmt.Sprintf(msgNoCollFmt, format.HTML.Bold(ref)))
+		}
+
+		return fmt.Errorf("failed to get collection: %v", err)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(format.HTML.Bold("🗂️ "+collDisplayName(req.Owner, c)) + "\n\n")
+
+	shown := 0
+	now := time.Now()
+	for _, id := range c.PageIDs {
+		page, err := p.storage.Get(owner, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoPagesFound) {
+				continue
+			}
+
+			return fmt.Errorf("failed to get page: %v", err)
+		}
+
+		shown++
+		status := "📖"
+		switch {
+		case page.Read:
+			status = "✅"
+		case page.Snoozed(now):
+			status = "💤"
+		}
+		fmt.Fprintf(&builder, "%d. %s #%d %s\n", shown, status, page.ID, format.HTML.Link(listTitle(page), page.URL))
+	}
+
+	if shown == 0 {
+		return p.reply(req.Meta, fmt.Sprintf(msgCollEmptyFmt, format.HTML.Bold(c.Name)))
+	}
+
+	return p.reply(req.Meta, builder.String(), telegram.WithoutPreview())
+}
+
+// next sends the first unread page of a collection, so a collection can be
+// read in order instead of at random. Pages of shared collections are not
+// marked as served, since they belong to the owner's list.
+func (p *Processor) next(req *Request) error {
+	ref := req.Args.(string)
+	owner, name := collRef(req.Owner, ref)
+
+	page, err := p.storage.NextInCollection(req.Owner, owner, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNoCollection):
+			return p.reply(req.Meta, fmt.Sprintf(msgNoCollFmt, format.HTML.Bold(ref)))
+		case errors.Is(err, storage.ErrNoPagesFound):
+			return p.reply(req.Meta, fmt.Sprintf(msgCollDoneFmt, format.HTML.Bold(ref)))
+		}
+
+		return fmt.Errorf("failed to get next page: %v", err)
+	}
+
+	if owner == req.Owner {
+		err = p.storage.MarkServed(req.Owner, []string{page.URL}, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to mark page as served: %v", err)
+		}
+	}
+
+	return p.reply(req.Meta, formatPage(page))
+}
+
+// parseCollectionArgs parses the arguments of /collection. Names with spaces
+// must be put in double quotes, except where the name is the only argument:
+//
+//	/collection                               list collections
+//	/collection new|delete|show <name>
+//	/collection rename <name> <new name>
+//	/collection add <name> <id> [position]
+//	/collection remove <name> <id>
+//	/collection order <name> <id> <position>
+//	/collection move <id> <from> <to>
+func parseCollectionArgs(raw string) (any, error) {
+	fields := splitQuoted(raw)
+	usage := &UsageError{Msg: msgCollUsage}
+
+	if len(fields) == 0 {
+		return collectionArgs{}, nil
+	}
+
+	args := collectionArgs{action: strings.ToLower(fields[0])}
+	fields = fields[1:]
+
+	var ok bool
+	switch {
+	case len(fields) > 0 && (args.action == "new" || args.action == "delete" || args.action == "show"):
+		args.name = strings.Join(fields, " ")
+	case len(fields) == 2 && args.action == "rename":
+		args.name, args.target = fields[0], fields[1]
+	case (len(fields) == 2 || len(fields) == 3) && args.action == "add",
+		len(fields) == 2 && args.action == "remove",
+		len(fields) == 3 && args.action == "order":
+		args.name = fields[0]
+		if args.id, ok = parseID(fields[1]); !ok {
+			return nil, usage
+		}
+		if len(fields) == 3 {
+			if args.pos, ok = parseID(fields[2]); !ok {
+				return nil, usage
+			}
+		}
+	case len(fields) == 3 && args.action == "move":
+		if args.id, ok = parseID(fields[0]); !ok {
+			return nil, usage
+		}
+		args.name, args.target = fields[1], fields[2]
+		if strings.EqualFold(args.name, args.target) {
+			return nil, usage
+		}
+	default:
+		return nil, usage
+	}
+
+	for _, name := range []string{args.name, args.target} {
+		if utf8.RuneCountInString(name) > maxCollectionName {
+			return nil, &UsageError{Msg: fmt.Sprintf(msgCollNameFmt, maxCollectionName)}
+		}
+	}
+
+	// Names starting with "@" refer to collections shared by other users.
+	if (args.action == "new" && strings.HasPrefix(args.name, "@")) ||
+		(args.action == "rename" && strings.HasPrefix(args.target, "@")) {
+		return nil, &UsageError{Msg: msgCollAtName}
+	}
+
+	return args, nil
+}
+
+// collRef resolves a collection reference given by the user. References of the
+// form @owner/name point to a collection shared by another user, anything else
+// to a collection of the user.
+func collRef(user, ref string) (owner, name string) {
+	if rest, ok := strings.CutPrefix(ref, "@"); ok {
+		if owner, name, ok := strings.Cut(rest, "/"); ok && owner != "" && name != "" {
+			return owner, name
+		}
+	}
+
+	return user, ref
+}
+
+// collDisplayName returns the name the user refers to a collection by.
+func collDisplayName(user string, c *storage.Collection) string {
+	if c.Owner == user {
+		return c.Name
+	}
+
+	return "@" + c.Owner + "/" + c.Name
+}
+
+// splitQuoted splits s into fields separated by spaces. Text in double quotes
+// is kept as a single field without the quotes.
+func splitQuoted(s string) []string {
+	var fields []string
+	var field strings.Builder
+	inQuotes, inField := false, false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			inField = true
+		case r == ' ' && !inQuotes:
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+
+	if inField {
+		fields = append(fields, field.String())
+	}
+
+	return fields
+}
+
+// parseNextArgs parses the collection name of /next. Quotes around the name are optional.
+func parseNextArgs(raw string) (any, error) {
+	name := strings.Join(splitQuoted(raw), " ")
+	if name == "" {
+		return nil, &UsageError{Msg: msgNextUsage}
+	}
+
+	return name, nil
+}
@@ -0,0 +1,130 @@
+package telegram
+
+import (
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessor_collection(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	for i := 1; i <= 4; i++ {
+		err := s.Save(&storage.Page{URL: fmt.Sprintf("https://example.com/%d", i), UserName: "alex"})
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	steps := []struct {
+		text     string
+		wantSend string
+	}{
+		{text: "/collection", wantSend: msgNoCollections},
+		{text: `/collection new "Deep Work"`, wantSend: fmt.Sprintf(msgCollCreatedFmt, "<b>Deep Work</b>")},
+		{text: "/collection new deep work", wantSend: msgCollExists},
+		{text: "/collection new Weekend", wantSend: fmt.Sprintf(msgCollCreatedFmt, "<b>Weekend</b>")},
+		{text: `/collection show "Deep Work"`, wantSend: fmt.Sprintf(msgCollEmptyFmt, "<b>Deep Work</b>")},
+		{text: `/next "Deep Work"`, wantSend: fmt.Sprintf(msgCollDoneFmt, "<b>Deep Work</b>")},
+		{text: `/collection add "Deep Work" 3`, wantSend: fmt.Sprintf(msgCollPosFmt, 3, 1, "<b>Deep Work</b>")},
+		{text: `/collection add "Deep Work" 1`, wantSend: fmt.Sprintf(msgCollPosFmt, 1, 2, "<b>Deep Work</b>")},
+		{text: `/collection add "Deep Work" #2 1`, wantSend: fmt.Sprintf(msgCollPosFmt, 2, 1, "<b>Deep Work</b>")},
+		{text: `/collection add "Deep Work" 9`, wantSend: msgPageNotFound},
+		{text: "/collection add Nope 1", wantSend: fmt.Sprintf(msgNoCollFmt, "<b>Nope</b>")},
+		{text: `/collection order "Deep Work" 1 1`, wantSend: fmt.Sprintf(msgCollPosFmt, 1, 1, "<b>Deep Work</b>")},
+		{text: `/collection order "Deep Work" 4 1`, wantSend: fmt.Sprintf(msgNotInCollFmt, 4, "<b>Deep Work</b>")},
+		{text: "/read 1", wantSend: msgMarkedAsRead},
+		{text: "/next deep work", wantSend: `<a href="https://example.com/2">`},
+		{text: `/collection move 2 "Deep Work" Weekend`, wantSend: fmt.Sprintf(msgCollMovedFmt, 2, "<b>Deep Work</b>", "<b>Weekend</b>")},
+		{text: `/collection move 2 "Deep Work" Weekend`, wantSend: fmt.Sprintf(msgNotInCollFmt, 2, "<b>Deep Work</b>")},
+		{text: `/next "Deep Work"`, wantSend: `<a href="https://example.com/3">`},
+		{text: `/collection remove "Deep Work" 3`, wantSend: fmt.Sprintf(msgCollRemovedFmt, 3, "<b>Deep Work</b>")},
+		{text: `/collection rename Weekend "Deep Work"`, wantSend: msgCollExists},
+		{text: "/collection rename Weekend Saturday", wantSend: fmt.Sprintf(msgCollRenamedFmt, "<b>Saturday</b>")},
+		{text: "/collection", wantSend: "<b>" + msgCollsHeader + "</b>\n\n• Deep Work — 1\n• Saturday — 1\n"},
+		{text: `/collection show "Deep Work"`, wantSend: "<b>🗂️ Deep Work</b>\n\n1. ✅ #1 "},
+		{text: `/collection delete "Deep Work"`, wantSend: fmt.Sprintf(msgCollDeletedFmt, "<b>Deep Work</b>")},
+		{text: `/next "Deep Work"`, wantSend: fmt.Sprintf(msgNoCollFmt, "<b>Deep Work</b>")},
+		{text: "/next", wantSend: msgNextUsage},
+		{text: "/collection add Saturday", wantSend: msgCollUsage},
+	}
+
+	for _, step := range steps {
+		client.sent = nil
+
+		err := p.doCmd(step.text, meta)
+		if err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", step.text, err)
+		}
+
+		if len(client.sent) != 1 || !strings.HasPrefix(client.sent[0], step.wantSend) {
+			t.Errorf("doCmd(%q) sent %q, want %q", step.text, client.sent, step.wantSend)
+		}
+	}
+
+	pages, _ := s.List("alex")
+	if len(pages) != 4 {
+		t.Errorf("List() returned %d pages after deleting a collection, want all 4 kept", len(pages))
+	}
+}
+
+func TestParseCollectionArgs(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    collectionArgs
+		wantErr bool
+	}{
+		{raw: "", want: collectionArgs{}},
+		{raw: `new "Deep Work"`, want: collectionArgs{action: "new", name: "Deep Work"}},
+		{raw: `Rename "Deep Work" Focus`, want: collectionArgs{action: "rename", name: "Deep Work", target: "Focus"}},
+		{raw: "add Focus #3", want: collectionArgs{action: "add", name: "Focus", id: 3}},
+		{raw: "add Focus 3 2", want: collectionArgs{action: "add", name: "Focus", id: 3, pos: 2}},
+		{raw: "order Focus 3 1", want: collectionArgs{action: "order", name: "Focus", id: 3, pos: 1}},
+		{raw: "move 3 Focus Weekend", want: collectionArgs{action: "move", name: "Focus", target: "Weekend", id: 3}},
+		{raw: "move 3 Focus focus", wantErr: true},
+		{raw: "order Focus 3", wantErr: true},
+		{raw: "add Focus x", wantErr: true},
+		{raw: "show deep  work", want: collectionArgs{action: "show", name: "deep work"}},
+		{raw: "new", wantErr: true},
+		{raw: "new " + strings.Repeat("a", 65), wantErr: true},
+		{raw: "shuffle Focus", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseCollectionArgs(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCollectionArgs(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCollectionArgs(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{s: "", want: nil},
+		{s: "  a  b ", want: []string{"a", "b"}},
+		{s: `"Deep Work" 3`, want: []string{"Deep Work", "3"}},
+		{s: `a"b c"d`, want: []string{"ab cd"}},
+		{s: `""`, want: []string{""}},
+		{s: `"unclosed quote`, want: []string{"unclosed quote"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitQuoted(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitQuoted(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
@@ -1,85 +1,358 @@
 package telegram
 
 import (
+	"URLbot/pkg/blob"
+	"URLbot/pkg/clients/telegram"
+	"URLbot/pkg/exporter"
+	"URLbot/pkg/format"
+	"URLbot/pkg/importer"
+	"URLbot/pkg/service"
 	"URLbot/pkg/storage"
+	"bytes"
 	"errors"
 	"fmt"
-	"log/slog"
 	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Supported Telegram bot commands.
 const (
-	StartCmd = "/start"  // Shows a welcome message.
-	RndCmd   = "/random" // Sends a random unread page.
-	ReadCmd  = "/read"   // Marks a page as read.
-	RmvCmd   = "/remove" // Removes a saved page.
-	ListCmd  = "/list"   // Show all saved pages.
-	HelpCmd  = "/help"   // Displays help information.
+	StartCmd     = "/start"      // Shows a welcome message.
+	RndCmd       = "/random"     // Sends a random unread page.
+	ReadCmd      = "/read"       // Marks a page as read.
+	UnreadCmd    = "/unread"     // Marks a page as unread again.
+	RmvCmd       = "/remove"     // Removes a saved page.
+	ListCmd      = "/list"       // Show all saved pages.
+	HelpCmd      = "/help"       // Displays help information.
+	GroupModeCmd = "/groupmode"  // Switches a group between personal and shared lists.
+	ExportCmd    = "/export"     // Sends the reading list as a file.
+	ImportCmd    = "/import"     // Imports links from an attached file.
+	SnapshotCmd  = "/snapshot"   // Sends the archived copy of a page.
+	BrokenCmd    = "/broken"     // Lists, removes or fixes dead and moved links.
+	DigestCmd    = "/digest"     // Schedules a daily or weekly digest of unread pages.
+	SnoozeCmd    = "/snooze"     // Hides a page until a later time and then reminds about it.
+	ModeCmd      = "/mode"       // Chooses how /random picks pages.
+	StatsCmd     = "/stats"      // Shows reading statistics.
+	TagCmd       = "/tag"        // Adds or removes tags of a page.
+	UndoCmd      = "/undo"       // Reverses the last save, read, unread, remove or tag.
+	TrashCmd     = "/trash"      // Lists removed pages and restores them.
+	CollCmd      = "/collection" // Manages named, ordered reading queues.
+	NextCmd      = "/next"       // Sends the next unread page of a collection.
+	ShareCmd     = "/share"      // Offers a page or a collection to another user.
+	UnshareCmd   = "/unshare"    // Takes away access to a shared collection.
+	FeedCmd      = "/feed"       // Shows or rotates the links of the user's feeds.
+
+	SubCmd   = "/subscribe"     // Follows an RSS or Atom feed.
+	UnsubCmd = "/unsubscribe"   // Stops following a feed.
+	SubsCmd  = "/subscriptions" // Lists the followed feeds.
+	TokenCmd = "/token"         // Issues or revokes the user's API token.
 )
 
+// maxImportSize is the largest file accepted by /import, in bytes.
+const maxImportSize = 10 << 20
+
+// maxRandomCount is the largest number of pages /random sends at once.
+const maxRandomCount = service.MaxRandom
+
+// registerCommands adds all supported commands to the processor's router.
+// The order of registration defines the order in /help and in the Telegram menu.
+func (p *Processor) registerCommands() {
+	p.router.Register(Command{
+		Name:         StartCmd,
+		Description:  "Show the welcome message",
+		Translations: map[string]string{"ru": "Показать приветствие"},
+		Handler:      p.sendHello,
+	})
+	p.router.Register(Command{
+		Name:         RndCmd,
+		Usage:        "[count] [short|long]",
+		Description:  "Get random unread articles",
+		Translations: map[string]string{"ru": "Случайные непрочитанные статьи"},
+		Parse:        parseRandomArgs,
+		Handler:      p.sendRandom,
+	})
+	p.router.Register(Command{
+		Name:         ReadCmd,
+		Usage:        "<url|id>",
+		Description:  "Mark an article as read",
+		Translations: map[string]string{"ru": "Отметить статью прочитанной"},
+		Parse:        requireArg(msgURLRequired),
+		Handler:      p.markAsRead,
+	})
+	p.router.Register(Command{
+		Name:         UnreadCmd,
+		Usage:        "<id>",
+		Description:  "Mark an article as unread again",
+		Translations: map[string]string{"ru": "Снова отметить статью непрочитанной"},
+		Parse:        requireID(msgUnreadUsage),
+		Handler:      p.markAsUnread,
+	})
+	p.router.Register(Command{
+		Name:         RmvCmd,
+		Usage:        "<url>",
+		Description:  "Move an article to the trash",
+		Translations: map[string]string{"ru": "Переместить статью в корзину"},
+		Parse:        requireArg(msgURLRequired),
+		Handler:      p.removePage,
+	})
+	p.router.Register(Command{
+		Name:         ListCmd,
+		Description:  "Show all saved articles",
+		Translations: map[string]string{"ru": "Показать все сохранённые статьи"},
+		Handler:      p.sendList,
+	})
+	p.router.Register(Command{
+		Name:         HelpCmd,
+		Description:  "Show this help message",
+		Translations: map[string]string{"ru": "Показать справку"},
+		Handler:      p.sendHelp,
+	})
+	p.router.Register(Command{
+		Name:         GroupModeCmd,
+		Usage:        "[personal|shared]",
+		Description:  "Use personal or shared lists in a group chat",
+		Translations: map[string]string{"ru": "Личные или общие списки в группе"},
+		Scope:        GroupOnly,
+		Handler:      p.groupMode,
+	})
+	p.router.Register(Command{
+		Name:         ExportCmd,
+		Usage:        "[json|csv|html|md]",
+		Description:  "Download your list as a file",
+		Translations: map[string]string{"ru": "Скачать список файлом"},
+		Parse:        parseExportFormat,
+		Handler:      p.exportList,
+	})
+	p.router.Register(Command{
+		Name:         ImportCmd,
+		Description:  "Send a file with this caption to import links",
+		Translations: map[string]string{"ru": "Отправьте файл с этой подписью для импорта"},
+		Handler:      p.importList,
+	})
+	p.router.Register(Command{
+		Name:         SnapshotCmd,
+		Usage:        "<id>",
+		Description:  "Get the offline copy of an article",
+		Translations: map[string]string{"ru": "Получить сохранённую копию статьи"},
+		Parse:        requireID(msgSnapshotUsage),
+		Handler:      p.sendSnapshot,
+	})
+	p.router.Register(Command{
+		Name:         BrokenCmd,
+		Usage:        "[remove|fix] [id]",
+		Description:  "Show dead links, remove them or fix moved ones",
+		Translations: map[string]string{"ru": "Битые ссылки: показать, удалить или исправить"},
+		Parse:        parseBrokenArgs,
+		Handler:      p.brokenLinks,
+	})
+	p.router.Register(Command{
+		Name:         DigestCmd,
+		Usage:        "[daily|weekly <day>] <hh:mm> [time zone] [count] | off",
+		Description:  "Get unread articles on a schedule",
+		Translations: map[string]string{"ru": "Подборка непрочитанного по расписанию"},
+		Parse:        parseDigestArgs,
+		Handler:      p.digest,
+	})
+	p.router.Register(Command{
+		Name:         SnoozeCmd,
+		Usage:        "<id> <3d|2w|yyyy-mm-dd|off>",
+		Description:  "Hide an article until later and get a reminder",
+		Translations: map[string]string{"ru": "Отложить статью и напомнить позже"},
+		Parse:        parseSnoozeArgs,
+		Handler:      p.snooze,
+	})
+	p.router.Register(Command{
+		Name:         ModeCmd,
+		Usage:        "[uniform|oldest|newest|weighted|short|spaced]",
+		Description:  "Choose how /random picks articles",
+		Translations: map[string]string{"ru": "Как /random выбирает статьи"},
+		Handler:      p.selectionMode,
+	})
+	p.router.Register(Command{
+		Name:         StatsCmd,
+		Description:  "Show your reading statistics",
+		Translations: map[string]string{"ru": "Статистика чтения"},
+		Handler:      p.stats,
+	})
+	p.router.Register(Command{
+		Name:         TagCmd,
+		Usage:        "<id> <tag|-tag>...",
+		Description:  "Add or remove tags of an article",
+		Translations: map[string]string{"ru": "Добавить или убрать теги статьи"},
+		Parse:        parseTagArgs,
+		Handler:      p.tag,
+	})
+	p.router.Register(Command{
+		Name:         UndoCmd,
+		Description:  "Undo your last save, read, unread, remove or tag",
+		Translations: map[string]string{"ru": "Отменить последнее действие"},
+		Handler:      p.undo,
+	})
+	p.router.Register(Command{
+		Name:         TrashCmd,
+		Usage:        "[restore <id>]",
+		Description:  "Show removed articles or restore one",
+		Translations: map[string]string{"ru": "Корзина: показать или восстановить статьи"},
+		Parse:        parseTrashArgs,
+		Handler:      p.trash,
+	})
+	p.router.Register(Command{
+		Name:         CollCmd,
+		Usage:        "[new|rename|delete|show|add|remove|order|move] ...",
+		Description:  "Manage your reading queues",
+		Translations: map[string]string{"ru": "Управлять очередями чтения"},
+		Parse:        parseCollectionArgs,
+		Handler:      p.collection,
+	})
+	p.router.Register(Command{
+		Name:         NextCmd,
+		Usage:        "<collection>",
+		Description:  "Get the next unread article of a collection",
+		Translations: map[string]string{"ru": "Следующая статья из очереди"},
+		Parse:        parseNextArgs,
+		Handler:      p.next,
+	})
+	p.router.Register(Command{
+		Name:         ShareCmd,
+		Usage:        "<id> [@user] | collection <name> [@user] [read|edit]",
+		Description:  "Share an article or a collection",
+		Translations: map[string]string{"ru": "Поделиться статьёй или очередью"},
+		Parse:        parseShareArgs,
+		Handler:      p.share,
+	})
+	p.router.Register(Command{
+		Name:         UnshareCmd,
+		Usage:        "<collection> @user",
+		Description:  "Stop sharing a collection with someone",
+		Translations: map[string]string{"ru": "Закрыть доступ к очереди"},
+		Parse:        parseUnshareArgs,
+		Handler:      p.unshare,
+	})
+	p.router.Register(Command{
+		Name:         FeedCmd,
+		Usage:        "[rotate]",
+		Description:  "Get Atom, RSS and JSON feeds of your list",
+		Translations: map[string]string{"ru": "Ленты Atom, RSS и JSON вашего списка"},
+		Parse:        parseFeedArgs,
+		Handler:      p.sendFeeds,
+	})
+	p.router.Register(Command{
+		Name:         SubCmd,
+		Usage:        "<feed-url> [tag]",
+		Description:  "Save new articles of an RSS or Atom feed",
+		Translations: map[string]string{"ru": "Подписаться на ленту RSS или Atom"},
+		Parse:        parseSubscribeArgs,
+		Handler:      p.subscribe,
+	})
+	p.router.Register(Command{
+		Name:         UnsubCmd,
+		Usage:        "<feed-url|n>",
+		Description:  "Stop following a feed",
+		Translations: map[string]string{"ru": "Отписаться от ленты"},
+		Parse:        parseUnsubscribeArgs,
+		Handler:      p.unsubscribe,
+	})
+	p.router.Register(Command{
+		Name:         SubsCmd,
+		Description:  "List the feeds you follow",
+		Translations: map[string]string{"ru": "Список ваших подписок"},
+		Handler:      p.sendSubscriptions,
+	})
+	p.router.Register(Command{
+		Name:         TokenCmd,
+		Usage:        "[revoke]",
+		Description:  "Get a token for the HTTP API",
+		Translations: map[string]string{"ru": "Получить токен для HTTP API"},
+		Scope:        PrivateOnly,
+		Parse:        parseTokenArgs,
+		Handler:      p.token,
+	})
+}
+
 // doCmd handles an incoming command or message text from the user.
-// If the text is a valid URL, it saves the page. Otherwise, it executes
-// one of the supported bot commands such as /start, /rnd, /read, etc.
-func (p *Processor) doCmd(text, username string, chatID int) error {
+// If the text is a valid URL, it saves the page. Otherwise, it dispatches
+// the text to one of the commands registered in the router.
+//
+// In group chats the bot follows Telegram's privacy mode: commands addressed
+// to another bot and plain text that is neither a link nor a command are ignored.
+func (p *Processor) doCmd(text string, meta Meta) error {
 	text = strings.TrimSpace(text)
 
-	slog.Info("got new command", "text", text, "username", username)
+	cmd, arg, mention := parseCmd(text)
+
+	if mention != "" && !p.isBotName(mention) {
+		return nil
+	}
+
+	owner, err := p.owner(meta)
+	if err != nil {
+		return err
+	}
 
-	cmd, arg := parseCmd(text)
+	req := &Request{
+		Cmd:   cmd,
+		Raw:   arg,
+		Owner: owner,
+		Meta:  meta,
+	}
 
 	if isAddCmd(cmd) {
-		return p.savePage(cmd, username, chatID)
-	}
-
-	switch cmd {
-	case StartCmd:
-		return p.sendHello(chatID)
-	case RndCmd:
-		return p.sendRandom(username, chatID)
-	case ReadCmd:
-		if arg == "" {
-			return p.client.SendMessage(chatID, msgURLRequired)
-		}
-		return p.markAsRead(arg, username, chatID)
-	case RmvCmd:
-		if arg == "" {
-			return p.client.SendMessage(chatID, msgURLRequired)
-		}
-		return p.removePage(arg, username, chatID)
-	case ListCmd:
-		return p.sendList(username, chatID)
-	case HelpCmd:
-		return p.sendHelp(chatID)
+		return p.router.Handle(req, p.savePage)
+	}
+
+	err = p.router.Dispatch(req)
+
+	var usageErr *UsageError
+	switch {
+	case errors.As(err, &usageErr):
+		return p.reply(meta, usageErr.Msg)
+	case errors.Is(err, ErrUnknownCommand):
+		if meta.Group && mention == "" {
+			return nil
+		}
+		return p.reply(meta, msgUnknownCommand)
 	default:
-		return p.client.SendMessage(chatID, msgUnknownCommand)
+		return err
 	}
 }
 
-// savePage saves a new page for the given user if it does not already exist.
-// After successful saving, it sends a confirmation message back to the user.
-func (p *Processor) savePage(pageURL, username string, chatID int) error {
-	page := &storage.Page{
-		URL:      pageURL,
-		UserName: username,
+// owner returns the storage owner for a message: the chat itself for channels
+// and group chats in shared mode, and the sender in every other case.
+func (p *Processor) owner(meta Meta) (string, error) {
+	if meta.Channel || !meta.Group {
+		return meta.UserName, nil
 	}
 
-	isExists, err := p.storage.IsExists(page)
+	mode, err := p.storage.ChatMode(meta.ChatID)
 	if err != nil {
-		return fmt.Errorf("failed to check if the page exists: %v", err)
+		return "", fmt.Errorf("failed to get chat mode: %v", err)
 	}
 
-	if isExists {
-		return p.client.SendMessage(chatID, msgAlreadyExists)
+	if mode == storage.Shared {
+		return chatOwner(meta.ChatID), nil
 	}
 
-	err = p.storage.Save(page)
-	if err != nil {
-		return fmt.Errorf("failed to save page: %v", err)
+	return meta.UserName, nil
+}
+
+// isBotName reports whether a command mention (the part after "@") refers to this bot.
+// When the bot's own username is unknown every mention is accepted.
+func (p *Processor) isBotName(mention string) bool {
+	return p.botName == "" || strings.EqualFold(mention, p.botName)
+}
+
+// reply sends an HTML-formatted message to the chat the request came from.
+// In group chats the message is sent as a reply to the user's message.
+func (p *Processor) reply(meta Meta, text string, opts ...telegram.SendOption) error {
+	opts = append([]telegram.SendOption{telegram.WithParseMode(telegram.ParseModeHTML)}, opts...)
+	if meta.Group {
+		opts = append(opts, telegram.WithReplyTo(meta.MessageID))
 	}
 
-	err = p.client.SendMessage(chatID, msgSaved)
+	err := p.client.SendMessage(meta.ChatID, text, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %v", err)
 	}
@@ -87,135 +360,721 @@ func (p *Processor) savePage(pageURL, username string, chatID int) error {
 	return nil
 }
 
-// sendHello sends a greeting message to the user.
-func (p *Processor) sendHello(chatID int) error {
-	return p.client.SendMessage(chatID, msgHello)
+// groupMode shows or changes the list mode of a group chat. The mode decides
+// whose list every later save goes to, so only chat administrators change it.
+func (p *Processor) groupMode(req *Request) error {
+	chatID := req.Meta.ChatID
+
+	if !req.Meta.Group {
+		return p.reply(req.Meta, msgGroupOnly)
+	}
+
+	var mode storage.ListMode
+
+	switch strings.ToLower(req.Raw) {
+	case "":
+		current, err := p.storage.ChatMode(chatID)
+		if err != nil {
+			return fmt.Errorf("failed to get chat mode: %v", err)
+		}
+		return p.reply(req.Meta, fmt.Sprintf(msgGroupModeFmt, current))
+	case storage.Personal.String():
+		mode = storage.Personal
+	case storage.Shared.String():
+		mode = storage.Shared
+	default:
+		return p.reply(req.Meta, msgGroupModeUsage)
+	}
+
+	member, err := p.client.GetChatMember(chatID, req.Meta.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get chat member: %v", err)
+	}
+
+	if !member.IsAdmin() {
+		return p.reply(req.Meta, msgGroupAdminOnly)
+	}
+
+	err = p.storage.SetChatMode(chatID, mode)
+	if err != nil {
+		return fmt.Errorf("failed to set chat mode: %v", err)
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgGroupModeSetFmt, mode))
+}
+
+// savePage saves the requested URL for the owner if it does not already exist.
+// After successful saving, it remembers the message, so an edit can replace
+// the link, and sends a confirmation message back to the user.
+func (p *Processor) savePage(req *Request) error {
+	page, err := p.service.Add(req.Owner, req.Meta.UserName, req.Cmd, nil)
+	if err != nil {
+		if errors.Is(err, storage.ErrPageExists) {
+			return p.reply(req.Meta, msgAlreadyExists)
+		}
+
+		return err
+	}
+
+	p.remember(req.Meta, page.URL)
+
+	return p.reply(req.Meta, msgSaved)
+}
+
+// sendHello sends a greeting message to the user. When /start carries a
+// token, the user has opened a share link and gets the share offer instead.
+func (p *Processor) sendHello(req *Request) error {
+	if token := strings.TrimSpace(req.Raw); token != "" {
+		return p.openShare(req, token)
+	}
+
+	return p.reply(req.Meta, msgHello)
 }
 
-// sendRandom retrieves a random unread page for the user
-// and sends its URL as a message. If there are no unread pages, it notifies the user.
-func (p *Processor) sendRandom(username string, chatID int) error {
-	page, err := p.storage.GetRandomUnread(username)
+// sendRandom picks an unread page with the owner's selection strategy
+// and sends it as a message. If there are no unread pages, it notifies the user.
+// With "short" or "long" only pages with a matching reading time are considered.
+func (p *Processor) sendRandom(req *Request) error {
+	args := req.Args.(randomArgs)
+
+	pages, err := p.service.Random(req.Owner, args.count, args.filter)
 	if err != nil {
-		if errors.Is(err, storage.ErrNoPagesFound) {
-			return p.client.SendMessage(chatID, msgNoSavedPages)
+		return err
+	}
+
+	if len(pages) == 0 {
+		if args.filter != nil {
+			return p.reply(req.Meta, msgNoMatchingPages)
+		}
+		return p.reply(req.Meta, msgNoSavedPages)
+	}
+
+	if len(pages) == 1 {
+		return p.reply(req.Meta, formatPage(pages[0]))
+	}
+
+	return p.reply(req.Meta, formatPicks(pages), telegram.WithoutPreview())
+}
+
+// selectionMode shows or changes the strategy /random uses to pick pages.
+func (p *Processor) selectionMode(req *Request) error {
+	name := strings.TrimSpace(req.Raw)
+
+	if name == "" {
+		current, err := p.storage.Strategy(req.Owner)
+		if err != nil {
+			return fmt.Errorf("failed to get selection strategy: %v", err)
 		}
 
-		return fmt.Errorf("failed to get random unread page: %v", err)
+		return p.reply(req.Meta, fmt.Sprintf(msgModeFmt, current)+msgModeList)
 	}
 
-	err = p.client.SendMessage(chatID, page.URL)
+	strategy, err := storage.ParseStrategy(name)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
+		return p.reply(req.Meta, msgModeUsage+msgModeList)
 	}
 
-	return nil
+	err = p.storage.SetStrategy(req.Owner, strategy)
+	if err != nil {
+		return fmt.Errorf("failed to set selection strategy: %v", err)
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgModeSetFmt, strategy))
 }
 
-// markAsRead marks a specific page as read for the given user.
+// markAsRead marks a page, given by its URL or ID, as read for the given user.
 // It then sends a confirmation message back to the user.
-func (p *Processor) markAsRead(pageURL, username string, chatID int) error {
-	page := &storage.Page{
-		URL:      pageURL,
-		UserName: username,
+func (p *Processor) markAsRead(req *Request) error {
+	pageURL, err := p.pageURL(req.Owner, req.Args.(string))
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return p.reply(req.Meta, msgPageNotFound)
+		}
+
+		return err
 	}
 
-	err := p.storage.MarkAsRead(page)
+	err = p.service.MarkRead(req.Owner, pageURL)
 	if err != nil {
-		return fmt.Errorf("failed to mark page as read: %v", err)
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return p.reply(req.Meta, msgPageNotFound)
+		}
+
+		return err
 	}
 
-	err = p.client.SendMessage(chatID, msgMarkedAsRead)
+	return p.reply(req.Meta, msgMarkedAsRead)
+}
+
+// markAsUnread marks a read page as unread again, keeping its metadata.
+func (p *Processor) markAsUnread(req *Request) error {
+	page, err := p.service.Get(req.Owner, req.Args.(int))
 	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return p.reply(req.Meta, msgPageNotFound)
+		}
+
+		return err
 	}
 
-	return nil
+	changed, err := p.service.MarkUnread(page.UserName, page.URL)
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		return p.reply(req.Meta, msgAlreadyUnread)
+	}
+
+	return p.reply(req.Meta, msgMarkedAsUnread)
+}
+
+// pageURL returns the URL of a page given by its ID, or ref itself if it is not an ID.
+func (p *Processor) pageURL(owner, ref string) (string, error) {
+	id, ok := parseID(ref)
+	if !ok {
+		return ref, nil
+	}
+
+	page, err := p.service.Get(owner, id)
+	if err != nil {
+		return "", err
+	}
+
+	return page.URL, nil
 }
 
-// removePage deletes a saved page for the given user
+// removePage moves a saved page of the given user to the trash
 // and sends a confirmation message to the user.
-func (p *Processor) removePage(pageURL, username string, chatID int) error {
-	page := &storage.Page{
-		URL:      pageURL,
-		UserName: username,
+func (p *Processor) removePage(req *Request) error {
+	err := p.service.Remove(req.Owner, req.Args.(string))
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return p.reply(req.Meta, msgPageNotFound)
+		}
+
+		return err
 	}
 
-	err := p.storage.Remove(page)
+	return p.reply(req.Meta, msgRemoved)
+}
+
+// tag adds tags to a page or removes the ones prefixed with "-".
+func (p *Processor) tag(req *Request) error {
+	args := req.Args.(tagArgs)
+
+	page, err := p.service.Get(req.Owner, args.id)
 	if err != nil {
-		return fmt.Errorf("failed to remove page: %v", err)
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return p.reply(req.Meta, msgPageNotFound)
+		}
+
+		return err
 	}
 
-	err = p.client.SendMessage(chatID, msgRemoved)
+	tags, err := p.service.Tag(page.UserName, page.URL, args.add, args.remove)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return p.reply(req.Meta, msgPageNotFound)
+		}
+
+		return err
 	}
 
-	return nil
+	if len(tags) == 0 {
+		return p.reply(req.Meta, fmt.Sprintf(msgNoTagsFmt, page.ID))
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgTagsFmt, page.ID, format.HTML.Escape("#"+strings.Join(tags, " #"))))
 }
 
 // sendList retrieves and sends the full list of saved pages for the user.
-// Each page is shown with a [ ] or [x] prefix indicating unread or read status.
-func (p *Processor) sendList(username string, chatID int) error {
-	pages, err := p.storage.List(username)
+// Each page is shown as a clickable link prefixed with its ID and read status.
+func (p *Processor) sendList(req *Request) error {
+	pages, _, err := p.service.List(req.Owner, service.ListQuery{})
+	if err != nil {
+		return err
+	}
+
+	if len(pages) == 0 {
+		return p.reply(req.Meta, msgNoSavedPages)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(format.HTML.Bold("Your saved articles:") + "\n\n")
+
+	now := time.Now()
+
+	for _, page := range pages {
+		status := "📖"
+		switch {
+		case page.Read:
+			status = "✅"
+		case page.Snoozed(now):
+			status = "💤"
+		}
+		fmt.Fprintf(&builder, "%d. %s %s", page.ID, status, format.HTML.Link(listTitle(page), page.URL))
+		if page.AddedBy != "" && page.AddedBy != page.UserName {
+			builder.WriteString(" " + format.HTML.Italic("added by @"+page.AddedBy))
+		}
+		builder.WriteString("\n")
+	}
+
+	return p.reply(req.Meta, builder.String(), telegram.WithoutPreview())
+}
+
+// exportList sends the owner's reading list as a file in the requested format.
+func (p *Processor) exportList(req *Request) error {
+	pages, err := p.storage.List(req.Owner)
 	if err != nil {
 		if errors.Is(err, storage.ErrNoPagesFound) {
-			return p.client.SendMessage(chatID, msgNoSavedPages)
+			return p.reply(req.Meta, msgNoSavedPages)
 		}
 
 		return fmt.Errorf("failed to fetch pages list: %v", err)
 	}
 
-	var builder strings.Builder
-	builder.WriteString("Your saved articles:\n\n")
+	f := req.Args.(exporter.Format)
+
+	var buf bytes.Buffer
 
-	for i, page := range pages {
-		status := ""
-		if page.Read {
-			status = "(Read)"
+	err = exporter.Export(&buf, f, pages)
+	if err != nil {
+		return fmt.Errorf("failed to export pages: %v", err)
+	}
+
+	err = p.client.SendDocument(req.Meta.ChatID, f.FileName(time.Now()), buf.Bytes(), fmt.Sprintf(msgExportFmt, len(pages)))
+	if err != nil {
+		return fmt.Errorf("failed to send document: %v", err)
+	}
+
+	return nil
+}
+
+// importList downloads the document attached to the /import message, parses it
+// and saves all new links in one batch. It reports how many links were imported,
+// skipped as duplicates and rejected as invalid.
+func (p *Processor) importList(req *Request) error {
+	if req.Meta.FileID == "" {
+		return p.reply(req.Meta, msgImportUsage)
+	}
+
+	if req.Meta.FileSize > maxImportSize {
+		return p.reply(req.Meta, msgImportTooLarge)
+	}
+
+	file, err := p.client.GetFile(req.Meta.FileID)
+	if err != nil {
+		return fmt.Errorf("failed to get file: %v", err)
+	}
+
+	data, err := p.client.DownloadFile(file.FilePath, maxImportSize)
+	if err != nil {
+		if errors.Is(err, telegram.ErrFileTooLarge) {
+			return p.reply(req.Meta, msgImportTooLarge)
 		}
-		fmt.Fprintf(&builder, "%d. %s %s\n", i+1, status, page.URL)
+
+		return fmt.Errorf("failed to download file: %v", err)
 	}
 
-	err = p.client.SendMessage(chatID, builder.String())
+	res, err := importer.Parse(data)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
+		return p.reply(req.Meta, msgImportFailed)
+	}
+
+	for _, page := range res.Pages {
+		page.UserName = req.Owner
+		page.AddedBy = req.Meta.UserName
+	}
+
+	added, err := p.storage.SaveBatch(res.Pages)
+	if err != nil {
+		return fmt.Errorf("failed to save imported pages: %v", err)
+	}
+
+	for _, page := range res.Pages {
+		// Pages skipped as duplicates are left without an ID.
+		if page.ID != 0 && page.Title == "" {
+			p.service.Enqueue(page)
+		}
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgImportDoneFmt, added, len(res.Pages)-added, res.Invalid))
+}
+
+// sendSnapshot sends the archived copy of the requested page as a document.
+func (p *Processor) sendSnapshot(req *Request) error {
+	if p.blobs == nil {
+		return p.reply(req.Meta, msgSnapshotsDisabled)
+	}
+
+	page, err := p.storage.Get(req.Owner, req.Args.(int))
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return p.reply(req.Meta, msgPageNotFound)
+		}
+
+		return fmt.Errorf("failed to get page: %v", err)
+	}
+
+	if page.SnapshotKey == "" {
+		return p.reply(req.Meta, msgNoSnapshot)
+	}
+
+	data, err := p.blobs.Get(page.SnapshotKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			return p.reply(req.Meta, msgNoSnapshot)
+		}
+
+		return fmt.Errorf("failed to read snapshot: %v", err)
+	}
+
+	fileName := fmt.Sprintf("snapshot-%d%s", page.ID, path.Ext(page.SnapshotKey))
+
+	err = p.client.SendDocument(req.Meta.ChatID, fileName, data, page.DisplayTitle())
+	if err != nil {
+		return fmt.Errorf("failed to send document: %v", err)
 	}
 
 	return nil
 }
 
-// sendHelp sends a help message describing all supported commands and usage instructions.
-func (p *Processor) sendHelp(chatID int) error {
-	return p.client.SendMessage(chatID, msgHelp)
+// brokenLinks lists the owner's dead and moved links found by the link checker.
+// With "remove" it moves the dead links to the trash, and with "fix" it changes
+// moved links to their redirect target in place. Moved links whose target is
+// already saved are left alone. An ID limits the action to one page.
+func (p *Processor) brokenLinks(req *Request) error {
+	args := req.Args.(brokenArgs)
+
+	pages, err := p.storage.List(req.Owner)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return p.reply(req.Meta, msgNoSavedPages)
+		}
+
+		return fmt.Errorf("failed to fetch pages list: %v", err)
+	}
+
+	var dead, moved []*storage.Page
+	found := false
+	for _, page := range pages {
+		if args.id != 0 && page.ID != args.id {
+			continue
+		}
+		found = true
+
+		switch {
+		case page.Broken():
+			dead = append(dead, page)
+		case page.Moved():
+			moved = append(moved, page)
+		}
+	}
+
+	if !found {
+		return p.reply(req.Meta, msgPageNotFound)
+	}
+
+	switch args.action {
+	case "remove":
+		for _, page := range dead {
+			err := p.service.Remove(req.Owner, page.URL)
+			if err != nil && !errors.Is(err, storage.ErrNoPagesFound) {
+				return err
+			}
+		}
+		return p.reply(req.Meta, fmt.Sprintf(msgBrokenRemovedFmt, len(dead)))
+	case "fix":
+		fixed, skipped := 0, 0
+		for _, page := range moved {
+			err := p.service.ChangeURL(req.Owner, page.URL, page.RedirectURL)
+			switch {
+			case errors.Is(err, storage.ErrPageExists):
+				skipped++
+				continue
+			case errors.Is(err, storage.ErrNoPagesFound):
+				continue
+			case err != nil:
+				return err
+			}
+
+			fixed++
+		}
+
+		text := fmt.Sprintf(msgBrokenFixedFmt, fixed)
+		if skipped > 0 {
+			text += "\n" + fmt.Sprintf(msgBrokenSkippedFmt, skipped)
+		}
+		return p.reply(req.Meta, text)
+	}
+
+	if len(dead) == 0 && len(moved) == 0 {
+		return p.reply(req.Meta, msgNoBrokenLinks)
+	}
+
+	var builder strings.Builder
+
+	if len(dead) > 0 {
+		builder.WriteString(format.HTML.Bold("Dead links:") + "\n")
+		for _, page := range dead {
+			reason := "unreachable"
+			if page.StatusCode != 0 {
+				reason = "HTTP " + strconv.Itoa(page.StatusCode)
+			}
+			fmt.Fprintf(&builder, "%d. %s — %s\n", page.ID, format.HTML.Link(listTitle(page), page.URL), reason)
+		}
+		builder.WriteString("\n")
+	}
+
+	if len(moved) > 0 {
+		builder.WriteString(format.HTML.Bold("Moved links:") + "\n")
+		for _, page := range moved {
+			fmt.Fprintf(&builder, "%d. %s → %s\n", page.ID,
+				format.HTML.Link(listTitle(page), page.URL), format.HTML.Link(shortURL(page.RedirectURL), page.RedirectURL))
+		}
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString(msgBrokenFooter)
+
+	return p.reply(req.Meta, builder.String(), telegram.WithoutPreview())
+}
+
+// sendHelp sends a help message generated from the registered commands.
+func (p *Processor) sendHelp(req *Request) error {
+	return p.reply(req.Meta, msgHelpHeader+format.HTML.Escape(p.router.Help())+msgHelpFooter)
+}
+
+// parseExportFormat parses the optional format argument of /export, JSON by default.
+func parseExportFormat(raw string) (any, error) {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return exporter.JSON, nil
+	}
+
+	f, err := exporter.ParseFormat(fields[0])
+	if err != nil {
+		return nil, &UsageError{Msg: msgExportUsage}
+	}
+
+	return f, nil
+}
+
+// brokenArgs holds the parsed arguments of /broken.
+type brokenArgs struct {
+	action string // Empty to list the links, "remove" or "fix".
+	id     int    // Page to act on, zero for all pages.
+}
+
+// parseBrokenArgs parses the optional action and page ID of /broken.
+func parseBrokenArgs(raw string) (any, error) {
+	fields := strings.Fields(strings.ToLower(raw))
+
+	var args brokenArgs
+	if len(fields) > 0 && (fields[0] == "remove" || fields[0] == "fix") {
+		args.action, fields = fields[0], fields[1:]
+	}
+
+	switch len(fields) {
+	case 0:
+		return args, nil
+	case 1:
+		id, ok := parseID(fields[0])
+		if !ok {
+			return nil, &UsageError{Msg: msgBrokenUsage}
+		}
+		args.id = id
+		return args, nil
+	default:
+		return nil, &UsageError{Msg: msgBrokenUsage}
+	}
+}
+
+// tagArgs holds the parsed arguments of /tag.
+type tagArgs struct {
+	id     int
+	add    []string
+	remove []string
+}
+
+// parseTagArgs parses the arguments of /tag: a page ID followed by tags to add
+// and tags to remove prefixed with "-". A leading "#" of a tag is ignored.
+func parseTagArgs(raw string) (any, error) {
+	fields := strings.Fields(raw)
+	usage := &UsageError{Msg: msgTagUsage}
+
+	if len(fields) < 2 {
+		return nil, usage
+	}
+
+	id, ok := parseID(fields[0])
+	if !ok {
+		return nil, usage
+	}
+
+	args := tagArgs{id: id}
+	for _, f := range fields[1:] {
+		remove := strings.HasPrefix(f, "-")
+		tag := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(f, "-"), "#"))
+		if tag == "" {
+			return nil, usage
+		}
+
+		if remove {
+			args.remove = append(args.remove, tag)
+		} else {
+			args.add = append(args.add, tag)
+		}
+	}
+
+	return args, nil
+}
+
+// randomArgs holds the parsed arguments of /random.
+type randomArgs struct {
+	count  int            // Number of pages to send.
+	filter storage.Filter // Reading time filter, nil for any page.
+}
+
+// parseRandomArgs parses the optional arguments of /random: a number of pages
+// and a reading time filter, in any order.
+func parseRandomArgs(raw string) (any, error) {
+	args := randomArgs{count: 1}
+	seenCount, seenFilter := false, false
+
+	for _, f := range strings.Fields(raw) {
+		if n, err := strconv.Atoi(f); err == nil && !seenCount {
+			if n < 1 || n > maxRandomCount {
+				return nil, &UsageError{Msg: fmt.Sprintf(msgRandomCountFmt, maxRandomCount)}
+			}
+			args.count, seenCount = n, true
+			continue
+		}
+
+		filter, err := parseReadingFilter(f)
+		if err != nil || seenFilter {
+			return nil, &UsageError{Msg: msgRandomUsage}
+		}
+		args.filter, seenFilter = filter, true
+	}
+
+	return args, nil
+}
+
+// parseReadingFilter parses a reading time filter of /random into a storage filter.
+// Pages whose reading time is not known yet match neither "short" nor "long".
+func parseReadingFilter(raw string) (storage.Filter, error) {
+	filter, err := service.ReadingFilter(raw)
+	if err != nil {
+		return nil, &UsageError{Msg: msgRandomUsage}
+	}
+
+	return filter, nil
+}
+
+// formatPicks renders several pages as a numbered list with their IDs and reading times.
+func formatPicks(pages []*storage.Page) string {
+	var builder strings.Builder
+	builder.WriteString(format.HTML.Bold(msgPicksHeader) + "\n\n")
+
+	for _, page := range pages {
+		builder.WriteString(pickLine(page))
+	}
+
+	return builder.String()
+}
+
+// pickLine renders a page as a single line of a pick list or a digest.
+func pickLine(page *storage.Page) string {
+	line := fmt.Sprintf("%d. %s", page.ID, format.HTML.Link(listTitle(page), page.URL))
+	if page.ReadingTime > 0 {
+		line += fmt.Sprintf(" ⏱️ %d min", int(page.ReadingTime.Minutes()))
+	}
+
+	return line + "\n"
+}
+
+// formatPage renders a single page with its title, site, reading time and description.
+func formatPage(page *storage.Page) string {
+	if page.Title == "" && page.ReadingTime == 0 {
+		return format.HTML.Link("", page.URL)
+	}
+
+	var builder strings.Builder
+	if page.Title != "" {
+		builder.WriteString(format.HTML.Bold(page.Title) + "\n")
+	}
+	if page.SiteName != "" {
+		builder.WriteString(format.HTML.Italic(page.SiteName) + "\n")
+	}
+	if page.ReadingTime > 0 {
+		fmt.Fprintf(&builder, msgReadingTimeFmt+"\n", int(page.ReadingTime.Minutes()), page.WordCount)
+	}
+	if page.Description != "" {
+		builder.WriteString(format.HTML.Escape(page.Description) + "\n")
+	}
+	builder.WriteString("\n" + format.HTML.Link("", page.URL))
+
+	return builder.String()
+}
+
+// listTitle returns the link text used for a page in /list.
+func listTitle(page *storage.Page) string {
+	if page.Title != "" {
+		return page.Title
+	}
+
+	return shortURL(page.URL)
+}
+
+// shortURL returns the URL without its scheme and "www." prefix, for display.
+func shortURL(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Host == "" {
+		return pageURL
+	}
+
+	return strings.TrimPrefix(u.Host, "www.") + strings.TrimSuffix(u.RequestURI(), "/")
 }
 
 // isAddCmd checks whether the given text should be treated as a "save page" command,
 // i.e. whether it is a valid URL.
 func isAddCmd(text string) bool {
-	return isURL(text)
+	return service.IsURL(text)
 }
 
-// isURL checks whether the given string is a valid URL that contains a host.
-func isURL(text string) bool {
-	u, err := url.Parse(text)
+// findURL returns the first word of the text that is a valid URL,
+// or an empty string if there is none.
+func findURL(text string) string {
+	for _, field := range strings.Fields(text) {
+		if service.IsURL(field) {
+			return field
+		}
+	}
 
-	return err == nil && u.Host != ""
+	return ""
 }
 
-// parseCmd splits the incoming message into a command and its argument.
-func parseCmd(text string) (cmd string, arg string) {
+// parseCmd splits the incoming message into a command and its arguments.
+// A bot mention suffix, as in "/list@BotName", is stripped from the command
+// and returned separately.
+func parseCmd(text string) (cmd string, arg string, mention string) {
 	fields := strings.Fields(strings.TrimSpace(text))
 
 	if len(fields) == 0 {
-		return "", ""
+		return "", "", ""
 	}
 
 	cmd = fields[0]
 
-	if len(fields) > 1 {
-		arg = fields[1]
+	if strings.HasPrefix(cmd, "/") {
+		if i := strings.Index(cmd, "@"); i > 0 {
+			cmd, mention = cmd[:i], cmd[i+1:]
+		}
 	}
 
+	arg = strings.Join(fields[1:], " ")
+
 	return
 }
@@ -1,28 +1,72 @@
 package telegram
 
 import (
+	"URLbot/pkg/blob"
 	"URLbot/pkg/clients/telegram"
 	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"fmt"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 type mockClient struct {
-	sent []string
-	err  error
+	sent    []string
+	markups []string // Reply markup of sent messages that have one.
+	file    []byte
+	member  string // Chat member status returned by GetChatMember.
+	err     error
 }
 
 func (m *mockClient) GetUpdates(offset, limit int) ([]telegram.Update, error) {
 	return nil, m.err
 }
 
-func (m *mockClient) SendMessage(chatID int, text string) error {
+func (m *mockClient) SendMessage(chatID int, text string, opts ...telegram.SendOption) error {
 	m.sent = append(m.sent, text)
+
+	q := url.Values{}
+	for _, opt := range opts {
+		opt(q)
+	}
+	if markup := q.Get("reply_markup"); markup != "" {
+		m.markups = append(m.markups, markup)
+	}
+
+	return nil
+}
+
+func (m *mockClient) EditMessageText(chatID, messageID int, text string, opts ...telegram.SendOption) error {
+	m.sent = append(m.sent, text)
+	return nil
+}
+
+func (m *mockClient) AnswerCallbackQuery(queryID, text string) error {
+	return nil
+}
+
+func (m *mockClient) GetChatMember(chatID, userID int) (*telegram.ChatMember, error) {
+	return &telegram.ChatMember{Status: m.member}, m.err
+}
+
+func (m *mockClient) GetFile(fileID string) (*telegram.File, error) {
+	return &telegram.File{FileID: fileID, FilePath: "documents/" + fileID}, m.err
+}
+
+func (m *mockClient) DownloadFile(filePath string, maxSize int64) ([]byte, error) {
+	return m.file, m.err
+}
+
+func (m *mockClient) SendDocument(chatID int, fileName string, data []byte, caption string) error {
+	m.sent = append(m.sent, fileName)
 	return nil
 }
 
 type mockStorage struct {
 	pages []*storage.Page
+	mode  storage.ListMode
 	err   error
 }
 
@@ -30,17 +74,174 @@ func (m *mockStorage) Save(p *storage.Page) error {
 	return m.err
 }
 
-func (m *mockStorage) GetRandomUnread(userName string) (*storage.Page, error) {
-	if len(m.pages) == 0 {
+func (m *mockStorage) SaveBatch(pages []*storage.Page) (int, error) {
+	m.pages = append(m.pages, pages...)
+	return len(pages), m.err
+}
+
+func (m *mockStorage) Get(userName string, id int) (*storage.Page, error) {
+	for _, p := range m.pages {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, storage.ErrNoPagesFound
+}
+
+func (m *mockStorage) PickUnread(userName string, strategy storage.Strategy, filter storage.Filter) (*storage.Page, error) {
+	for _, p := range m.pages {
+		if filter.Match(p) {
+			return p, nil
+		}
+	}
+	return nil, storage.ErrNoPagesFound
+}
+
+func (m *mockStorage) PickUnreadN(userName string, n int, strategy storage.Strategy, filter storage.Filter) ([]*storage.Page, error) {
+	var res []*storage.Page
+	for _, p := range m.pages {
+		if len(res) < n && filter.Match(p) {
+			res = append(res, p)
+		}
+	}
+	if len(res) == 0 {
 		return nil, storage.ErrNoPagesFound
 	}
-	return m.pages[0], nil
+	return res, nil
+}
+
+func (m *mockStorage) MarkServed(userName string, pageURLs []string, at time.Time) error {
+	return m.err
+}
+
+func (m *mockStorage) RecentlyServed(userName string, k int) ([]string, error) {
+	return nil, m.err
+}
+
+func (m *mockStorage) Stats(userName string, q storage.StatsQuery) (*storage.Stats, error) {
+	return &storage.Stats{Total: len(m.pages), Unread: len(m.pages)}, m.err
+}
+
+func (m *mockStorage) ReadDays(userName string, loc *time.Location) ([]time.Time, error) {
+	return nil, m.err
+}
+
+func (m *mockStorage) Trash(userName string) ([]*storage.Page, error) {
+	return nil, m.err
+}
+
+func (m *mockStorage) Restore(userName, pageURL string) (*storage.Page, error) {
+	return nil, storage.ErrNoPagesFound
+}
+
+func (m *mockStorage) PurgeTrash(before time.Time) ([]*storage.Page, error) {
+	return nil, m.err
+}
+
+func (m *mockStorage) PushUndo(userName string, a storage.Action) error {
+	return m.err
+}
+
+func (m *mockStorage) PopUndo(userName string) (*storage.Action, error) {
+	return nil, storage.ErrNoUndo
+}
+
+func (m *mockStorage) Collections(userName string) ([]*storage.Collection, error) {
+	return nil, m.err
+}
+
+func (m *mockStorage) Collection(actor, owner, name string) (*storage.Collection, error) {
+	return nil, storage.ErrNoCollection
+}
+
+func (m *mockStorage) CreateCollection(owner, name string) (*storage.Collection, error) {
+	return &storage.Collection{Owner: owner, Name: name}, m.err
+}
+
+func (m *mockStorage) UpdateCollection(actor, owner, name string, fn func(c *storage.Collection)) error {
+	return storage.ErrNoCollection
+}
+
+func (m *mockStorage) RemoveCollection(actor, owner, name string) error {
+	return storage.ErrNoCollection
+}
+
+func (m *mockStorage) NextInCollection(actor, owner, name string) (*storage.Page, error) {
+	return nil, storage.ErrNoCollection
+}
+
+func (m *mockStorage) SaveShare(s *storage.Share) error {
+	return m.err
+}
+
+func (m *mockStorage) Share(token string) (*storage.Share, error) {
+	return nil, storage.ErrNoShare
+}
+
+func (m *mockStorage) RemoveShare(token string) error {
+	return storage.ErrNoShare
+}
+
+func (m *mockStorage) SetUserChat(userName string, chatID int) error {
+	return m.err
+}
+
+func (m *mockStorage) UserChat(userName string) (int, error) {
+	return 0, storage.ErrUnknownUser
+}
+
+func (m *mockStorage) FeedToken(userName string) (string, error) {
+	return "", storage.ErrNoFeedToken
+}
+
+func (m *mockStorage) SetFeedToken(userName, tokenHash string) error {
+	return m.err
+}
+
+func (m *mockStorage) FeedOwner(tokenHash string) (string, error) {
+	return "", storage.ErrNoFeedToken
+}
+
+func (m *mockStorage) SetAPIToken(userName, tokenHash string) error {
+	return m.err
+}
+
+func (m *mockStorage) APITokenOwner(tokenHash string) (string, error) {
+	return "", storage.ErrNoAPIToken
+}
+
+func (m *mockStorage) Subscribe(s *storage.Subscription) error {
+	return m.err
+}
+
+func (m *mockStorage) Unsubscribe(owner, feedURL string) error {
+	return storage.ErrNoSubscription
+}
+
+func (m *mockStorage) Subscriptions(owner string) ([]*storage.Subscription, error) {
+	return nil, m.err
+}
+
+func (m *mockStorage) AllSubscriptions() ([]*storage.Subscription, error) {
+	return nil, m.err
+}
+
+func (m *mockStorage) UpdateSubscription(owner, feedURL string, fn func(s *storage.Subscription)) error {
+	return storage.ErrNoSubscription
 }
 
 func (m *mockStorage) MarkAsRead(p *storage.Page) error {
 	return nil
 }
 
+func (m *mockStorage) Update(userName, pageURL string, fn func(p *storage.Page)) error {
+	return m.err
+}
+
+func (m *mockStorage) ChangeURL(userName, oldURL, newURL string) error {
+	return m.err
+}
+
 func (m *mockStorage) IsExists(p *storage.Page) (bool, error) {
 	return false, nil
 }
@@ -53,6 +254,51 @@ func (m *mockStorage) List(userName string) ([]*storage.Page, error) {
 	return m.pages, nil
 }
 
+func (m *mockStorage) Digest(chatID int, owner string) (*storage.Digest, error) {
+	return nil, storage.ErrNoDigest
+}
+
+func (m *mockStorage) SaveDigest(d *storage.Digest) error {
+	return m.err
+}
+
+func (m *mockStorage) RemoveDigest(chatID int, owner string) error {
+	return storage.ErrNoDigest
+}
+
+func (m *mockStorage) DueDigests(now time.Time) ([]*storage.Digest, error) {
+	return nil, m.err
+}
+
+func (m *mockStorage) AdvanceDigest(chatID int, owner string, from, to time.Time) (bool, error) {
+	return false, m.err
+}
+
+func (m *mockStorage) DueSnoozes(now time.Time) ([]*storage.Page, error) {
+	return nil, m.err
+}
+
+func (m *mockStorage) ListAll() ([]*storage.Page, error) {
+	return m.pages, nil
+}
+
+func (m *mockStorage) Strategy(userName string) (storage.Strategy, error) {
+	return storage.Uniform, nil
+}
+
+func (m *mockStorage) SetStrategy(userName string, strategy storage.Strategy) error {
+	return m.err
+}
+
+func (m *mockStorage) ChatMode(chatID int) (storage.ListMode, error) {
+	return m.mode, nil
+}
+
+func (m *mockStorage) SetChatMode(chatID int, mode storage.ListMode) error {
+	m.mode = mode
+	return nil
+}
+
 func TestProcessor_doCmd(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -61,6 +307,7 @@ func TestProcessor_doCmd(t *testing.T) {
 		text     string
 		username string
 		chatID   int
+		group    bool
 		wantSend string
 	}{
 		{
@@ -81,6 +328,58 @@ func TestProcessor_doCmd(t *testing.T) {
 			username: "alex",
 			wantSend: msgNoSavedPages,
 		},
+		{
+			name:   "random short",
+			client: &mockClient{},
+			storage: &mockStorage{
+				pages: []*storage.Page{
+					{URL: "https://example.com/long", Title: "Long read", ReadingTime: 40 * time.Minute},
+					{URL: "https://example.com/short", Title: "Short read", ReadingTime: 3 * time.Minute, WordCount: 600},
+				},
+			},
+			text:     "/random short",
+			username: "alex",
+			wantSend: "<b>Short read</b>\n⏱️ 3 min read, 600 words",
+		},
+		{
+			name:   "random long without matching pages",
+			client: &mockClient{},
+			storage: &mockStorage{
+				pages: []*storage.Page{{URL: "https://example.com", ReadingTime: 3 * time.Minute}},
+			},
+			text:     "/random long",
+			username: "alex",
+			wantSend: msgNoMatchingPages,
+		},
+		{
+			name:     "random with invalid filter",
+			client:   &mockClient{},
+			storage:  &mockStorage{},
+			text:     "/random medium",
+			username: "alex",
+			wantSend: msgRandomUsage,
+		},
+		{
+			name:   "random several",
+			client: &mockClient{},
+			storage: &mockStorage{
+				pages: []*storage.Page{
+					{ID: 1, URL: "https://example.com/1", Title: "First", ReadingTime: 4 * time.Minute},
+					{ID: 2, URL: "https://example.com/2"},
+				},
+			},
+			text:     "/random 3",
+			username: "alex",
+			wantSend: "<b>" + msgPicksHeader + "</b>\n\n1. <a href=\"https://example.com/1\">First</a> ⏱️ 4 min\n2. ",
+		},
+		{
+			name:     "random too many",
+			client:   &mockClient{},
+			storage:  &mockStorage{},
+			text:     "/random 50",
+			username: "alex",
+			wantSend: fmt.Sprintf(msgRandomCountFmt, maxRandomCount),
+		},
 		{
 			name:     "read command without arg",
 			client:   &mockClient{},
@@ -138,7 +437,7 @@ func TestProcessor_doCmd(t *testing.T) {
 			storage:  &mockStorage{},
 			text:     "/help",
 			username: "alex",
-			wantSend: msgHelp,
+			wantSend: msgHelpHeader,
 		},
 		{
 			name:     "save link",
@@ -156,17 +455,105 @@ func TestProcessor_doCmd(t *testing.T) {
 			username: "alex",
 			wantSend: msgUnknownCommand,
 		},
+		{
+			name:   "export as csv",
+			client: &mockClient{},
+			storage: &mockStorage{
+				pages: []*storage.Page{{URL: "https://example.com"}},
+			},
+			text:     "/export csv",
+			username: "alex",
+			wantSend: ".csv",
+		},
+		{
+			name:     "export unknown format",
+			client:   &mockClient{},
+			storage:  &mockStorage{},
+			text:     "/export xml",
+			username: "alex",
+			wantSend: msgExportUsage,
+		},
+		{
+			name:     "command with bot mention",
+			client:   &mockClient{},
+			storage:  &mockStorage{},
+			text:     "/help@NamnadaBot",
+			username: "alex",
+			group:    true,
+			wantSend: msgHelpHeader,
+		},
+		{
+			name:     "command for another bot",
+			client:   &mockClient{},
+			storage:  &mockStorage{},
+			text:     "/help@OtherBot",
+			username: "alex",
+			group:    true,
+		},
+		{
+			name:     "plain text in group",
+			client:   &mockClient{},
+			storage:  &mockStorage{},
+			text:     "hello everyone",
+			username: "alex",
+			group:    true,
+		},
+		{
+			name:     "group mode outside group",
+			client:   &mockClient{},
+			storage:  &mockStorage{},
+			text:     "/groupmode shared",
+			username: "alex",
+			wantSend: msgGroupOnly,
+		},
+		{
+			name:     "switch group to shared mode",
+			client:   &mockClient{member: telegram.MemberAdministrator},
+			storage:  &mockStorage{},
+			text:     "/groupmode shared",
+			username: "alex",
+			group:    true,
+			wantSend: "👥 Done!",
+		},
+		{
+			name:     "switch group mode as a member",
+			client:   &mockClient{member: "member"},
+			storage:  &mockStorage{},
+			text:     "/groupmode shared",
+			username: "alex",
+			group:    true,
+			wantSend: msgGroupAdminOnly,
+		},
+		{
+			name:     "show group mode as a member",
+			client:   &mockClient{member: "member"},
+			storage:  &mockStorage{},
+			text:     "/groupmode",
+			username: "alex",
+			group:    true,
+			wantSend: "👥 This chat uses",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			client := tt.client
 			p := New(client, tt.storage)
+			p.SetBotName("NamnadaBot")
 
-			gotErr := p.doCmd(tt.text, tt.username, tt.chatID)
+			meta := Meta{ChatID: tt.chatID, UserName: tt.username, Group: tt.group}
+
+			gotErr := p.doCmd(tt.text, meta)
 			if gotErr != nil {
 				t.Fatalf("doCmd() failed: %v", gotErr)
 			}
 
+			if tt.wantSend == "" {
+				if len(client.sent) != 0 {
+					t.Fatalf("unexpected message sent: %v", client.sent)
+				}
+				return
+			}
+
 			if len(client.sent) == 0 {
 				t.Fatal("the message was not sent, but it should have been sent")
 			}
@@ -179,6 +566,455 @@ func TestProcessor_doCmd(t *testing.T) {
 	}
 }
 
+type mockBlobs map[string][]byte
+
+func (m mockBlobs) Put(key string, data []byte) error {
+	m[key] = data
+	return nil
+}
+
+func (m mockBlobs) Get(key string) ([]byte, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, blob.ErrNotFound
+	}
+	return data, nil
+}
+
+func (m mockBlobs) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestProcessor_sendSnapshot(t *testing.T) {
+	pages := []*storage.Page{
+		{ID: 7, URL: "https://example.com/a", SnapshotKey: "snapshots/7.html"},
+		{ID: 8, URL: "https://example.com/b"},
+		{ID: 9, URL: "https://example.com/c", SnapshotKey: "snapshots/9.html"},
+	}
+
+	tests := []struct {
+		name     string
+		blobs    mockBlobs
+		text     string
+		wantSend string
+	}{
+		{
+			name:     "snapshots disabled",
+			text:     "/snapshot 7",
+			wantSend: msgSnapshotsDisabled,
+		},
+		{
+			name:     "missing id",
+			blobs:    mockBlobs{},
+			text:     "/snapshot",
+			wantSend: msgSnapshotUsage,
+		},
+		{
+			name:     "invalid id",
+			blobs:    mockBlobs{},
+			text:     "/snapshot abc",
+			wantSend: msgSnapshotUsage,
+		},
+		{
+			name:     "unknown page",
+			blobs:    mockBlobs{},
+			text:     "/snapshot 100",
+			wantSend: msgPageNotFound,
+		},
+		{
+			name:     "page without snapshot",
+			blobs:    mockBlobs{},
+			text:     "/snapshot 8",
+			wantSend: msgNoSnapshot,
+		},
+		{
+			name:     "snapshot missing from the store",
+			blobs:    mockBlobs{},
+			text:     "/snapshot 9",
+			wantSend: msgNoSnapshot,
+		},
+		{
+			name:     "snapshot sent as a document",
+			blobs:    mockBlobs{"snapshots/7.html": []byte("<html></html>")},
+			text:     "/snapshot #7",
+			wantSend: "snapshot-7.html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockClient{}
+			p := New(client, &mockStorage{pages: pages})
+			if tt.blobs != nil {
+				p.SetSnapshots(tt.blobs)
+			}
+
+			err := p.doCmd(tt.text, Meta{ChatID: 1, UserName: "alex"})
+			if err != nil {
+				t.Fatalf("doCmd() failed: %v", err)
+			}
+
+			if len(client.sent) != 1 || client.sent[0] != tt.wantSend {
+				t.Errorf("sent %q, want %q", client.sent, tt.wantSend)
+			}
+		})
+	}
+}
+
+func TestProcessor_brokenLinks(t *testing.T) {
+	s := memory.New()
+	checked := time.Now()
+
+	for _, page := range []*storage.Page{
+		{URL: "https://alive.com", UserName: "alex"},
+		{URL: "https://dead.com", UserName: "alex", Read: true},
+		{URL: "http://old.com", UserName: "alex", Tags: []string{"go"}},
+		{URL: "http://www.alive.com", UserName: "alex"},
+	} {
+		err := s.Save(page)
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	results := map[string]func(p *storage.Page){
+		"https://alive.com": func(p *storage.Page) { p.StatusCode, p.CheckedAt = 200, checked },
+		"https://dead.com":  func(p *storage.Page) { p.StatusCode, p.CheckedAt, p.CheckFailures = 404, checked, storage.BrokenAfter },
+		"http://old.com":    func(p *storage.Page) { p.StatusCode, p.RedirectURL, p.CheckedAt = 200, "https://new.com", checked },
+		// Moved to a link that is already saved, so /broken fix leaves it alone.
+		"http://www.alive.com": func(p *storage.Page) { p.StatusCode, p.RedirectURL, p.CheckedAt = 200, "https://alive.com", checked },
+	}
+	for pageURL, fn := range results {
+		err := s.Update("alex", pageURL, fn)
+		if err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+	}
+
+	steps := []struct {
+		text      string
+		wantSend  []string
+		wantPages []string
+	}{
+		{
+			text:      "/broken 1",
+			wantSend:  []string{msgNoBrokenLinks},
+			wantPages: []string{"https://alive.com", "https://dead.com", "http://old.com", "http://www.alive.com"},
+		},
+		{
+			text:      "/broken",
+			wantSend:  []string{"Dead links:", "dead.com</a> — HTTP 404", "Moved links:", "→ <a href=\"https://new.com\">"},
+			wantPages: []string{"https://alive.com", "https://dead.com", "http://old.com", "http://www.alive.com"},
+		},
+		{
+			text:      "/broken fix",
+			wantSend:  []string{fmt.Sprintf(msgBrokenFixedFmt, 1), fmt.Sprintf(msgBrokenSkippedFmt, 1)},
+			wantPages: []string{"https://alive.com", "https://dead.com", "https://new.com", "http://www.alive.com"},
+		},
+		{
+			text:      "/broken remove",
+			wantSend:  []string{fmt.Sprintf(msgBrokenRemovedFmt, 1)},
+			wantPages: []string{"https://alive.com", "https://new.com", "http://www.alive.com"},
+		},
+		{
+			text:      "/broken remove 42",
+			wantSend:  []string{msgPageNotFound},
+			wantPages: []string{"https://alive.com", "https://new.com", "http://www.alive.com"},
+		},
+		{
+			text:      "/broken delete",
+			wantSend:  []string{msgBrokenUsage},
+			wantPages: []string{"https://alive.com", "https://new.com", "http://www.alive.com"},
+		},
+	}
+
+	old, err := s.Get("alex", 3)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+
+	client := &mockClient{}
+	p := New(client, s)
+
+	for _, step := range steps {
+		client.sent = nil
+
+		err := p.doCmd(step.text, Meta{ChatID: 1, UserName: "alex"})
+		if err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", step.text, err)
+		}
+
+		if len(client.sent) != 1 {
+			t.Fatalf("doCmd(%q) sent %d messages, want 1", step.text, len(client.sent))
+		}
+		for _, want := range step.wantSend {
+			if !strings.Contains(client.sent[0], want) {
+				t.Errorf("doCmd(%q) sent %q, want it to contain %q", step.text, client.sent[0], want)
+			}
+		}
+
+		pages, err := s.List("alex")
+		if err != nil {
+			t.Fatalf("List() failed: %v", err)
+		}
+
+		var got []string
+		for _, page := range pages {
+			got = append(got, page.URL)
+		}
+		if strings.Join(got, " ") != strings.Join(step.wantPages, " ") {
+			t.Errorf("after %q pages = %v, want %v", step.text, got, step.wantPages)
+		}
+	}
+
+	fixed, err := s.Get("alex", old.ID)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if fixed.URL != "https://new.com" || len(fixed.Tags) != 1 || fixed.CheckedAt != (time.Time{}) {
+		t.Errorf("fixed page = %+v, want the same page at the new URL with its tags kept and the check result reset", fixed)
+	}
+
+	// Removed dead links and fixed links can be brought back with /undo.
+	for _, wantURL := range []string{"https://dead.com", "http://old.com"} {
+		err = p.doCmd("/undo", Meta{ChatID: 1, UserName: "alex"})
+		if err != nil {
+			t.Fatalf("doCmd(/undo) failed: %v", err)
+		}
+
+		if exists, _ := s.IsExists(&storage.Page{URL: wantURL, UserName: "alex"}); !exists {
+			t.Errorf("/undo did not bring back %s", wantURL)
+		}
+	}
+}
+
+func TestProcessor_selectionMode(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	for _, u := range []string{"https://first.com", "https://second.com"} {
+		err := s.Save(&storage.Page{URL: u, UserName: "alex"})
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	steps := []struct {
+		text     string
+		wantSend string
+	}{
+		{text: "/mode", wantSend: fmt.Sprintf(msgModeFmt, "uniform")},
+		{text: "/mode fastest", wantSend: msgModeUsage},
+		{text: "/mode Oldest", wantSend: fmt.Sprintf(msgModeSetFmt, "oldest")},
+		{text: "/random", wantSend: `<a href="https://first.com">`},
+		{text: "/mode newest", wantSend: fmt.Sprintf(msgModeSetFmt, "newest")},
+		{text: "/random", wantSend: `<a href="https://second.com">`},
+	}
+
+	for _, step := range steps {
+		client.sent = nil
+
+		err := p.doCmd(step.text, meta)
+		if err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", step.text, err)
+		}
+
+		if len(client.sent) != 1 || !strings.HasPrefix(client.sent[0], step.wantSend) {
+			t.Errorf("doCmd(%q) sent %q, want %q", step.text, client.sent, step.wantSend)
+		}
+	}
+
+	page, err := s.Get("alex", 2)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if page.ServedCount != 1 || page.ServedAt.IsZero() {
+		t.Errorf("served page has ServedCount %d, ServedAt %v; want it recorded", page.ServedCount, page.ServedAt)
+	}
+}
+
+func TestProcessor_markAsUnread(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	err := s.Save(&storage.Page{URL: "https://example.com", UserName: "alex", Title: "Example"})
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	steps := []struct {
+		text     string
+		wantSend string
+	}{
+		{text: "/unread 1", wantSend: msgAlreadyUnread},
+		{text: "/read #1", wantSend: msgMarkedAsRead},
+		{text: "/read 7", wantSend: msgPageNotFound},
+		{text: "/unread 7", wantSend: msgPageNotFound},
+		{text: "/unread", wantSend: msgUnreadUsage},
+		{text: "/unread 1", wantSend: msgMarkedAsUnread},
+	}
+
+	var readAt time.Time
+	for _, step := range steps {
+		client.sent = nil
+
+		err := p.doCmd(step.text, meta)
+		if err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", step.text, err)
+		}
+		if len(client.sent) != 1 || client.sent[0] != step.wantSend {
+			t.Errorf("doCmd(%q) sent %q, want %q", step.text, client.sent, step.wantSend)
+		}
+
+		if page, _ := s.Get("alex", 1); page.Read {
+			readAt = page.ReadAt
+		}
+	}
+
+	page, err := s.Get("alex", 1)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if page.Read || !page.ReadAt.IsZero() || page.Title != "Example" {
+		t.Errorf("page after /unread = %+v, want unread with its metadata kept", page)
+	}
+
+	wantKinds := []storage.ActionKind{storage.ActionRead, storage.ActionUnread}
+	if len(page.History) != len(wantKinds) {
+		t.Fatalf("History = %+v, want %v", page.History, wantKinds)
+	}
+	for i, change := range page.History {
+		if change.Kind != wantKinds[i] || change.At.IsZero() {
+			t.Errorf("History[%d] = %+v, want kind %v with a time", i, change, wantKinds[i])
+		}
+	}
+
+	client.sent = nil
+	err = p.doCmd("/undo", meta)
+	if err != nil {
+		t.Fatalf("doCmd() failed: %v", err)
+	}
+
+	page, _ = s.Get("alex", 1)
+	if !page.Read || !page.ReadAt.Equal(readAt) || len(page.History) != 3 {
+		t.Errorf("page after undoing /unread = %+v, want read at %v again", page, readAt)
+	}
+}
+
+func TestProcessor_sendRandomAvoidRecent(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	p.service.SetAvoidRecent(2)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	for _, u := range []string{"https://a.com", "https://b.com", "https://c.com"} {
+		err := s.Save(&storage.Page{URL: u, UserName: "alex"})
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	var served []string
+	for i := 0; i < 6; i++ {
+		client.sent = nil
+
+		err := p.doCmd("/random", meta)
+		if err != nil {
+			t.Fatalf("doCmd() failed: %v", err)
+		}
+		if len(client.sent) != 1 {
+			t.Fatalf("doCmd() sent %d messages, want 1", len(client.sent))
+		}
+		served = append(served, client.sent[0])
+	}
+
+	// With three pages and the last two picks avoided, picks cycle without repeats.
+	for i := 2; i < len(served); i++ {
+		if served[i] == served[i-1] || served[i] == served[i-2] {
+			t.Errorf("pick %d repeats one of the last two picks: %q", i, served)
+		}
+	}
+
+	client.sent = nil
+	err := p.doCmd("/random 3", meta)
+	if err != nil {
+		t.Fatalf("doCmd() failed: %v", err)
+	}
+	for _, u := range []string{"https://a.com", "https://b.com", "https://c.com"} {
+		if len(client.sent) != 1 || strings.Count(client.sent[0], u) != 1 {
+			t.Errorf("/random 3 sent %q, want %s exactly once", client.sent, u)
+		}
+	}
+}
+
+func TestParseRandomArgs(t *testing.T) {
+	tests := []struct {
+		raw        string
+		wantCount  int
+		wantFilter bool
+		wantErr    bool
+	}{
+		{raw: "", wantCount: 1},
+		{raw: "3", wantCount: 3},
+		{raw: "short", wantCount: 1, wantFilter: true},
+		{raw: "long 5", wantCount: 5, wantFilter: true},
+		{raw: "2 short", wantCount: 2, wantFilter: true},
+		{raw: "0", wantErr: true},
+		{raw: "11", wantErr: true},
+		{raw: "2 3", wantErr: true},
+		{raw: "short long", wantErr: true},
+		{raw: "medium", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseRandomArgs(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRandomArgs(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			args := got.(randomArgs)
+			if args.count != tt.wantCount || (args.filter != nil) != tt.wantFilter {
+				t.Errorf("parseRandomArgs(%q) = count %d, filter %v; want %d, %v",
+					tt.raw, args.count, args.filter != nil, tt.wantCount, tt.wantFilter)
+			}
+		})
+	}
+}
+
+func TestParseCmd(t *testing.T) {
+	tests := []struct {
+		text        string
+		wantCmd     string
+		wantArg     string
+		wantMention string
+	}{
+		{text: "/list", wantCmd: "/list"},
+		{text: "/list@NamnadaBot", wantCmd: "/list", wantMention: "NamnadaBot"},
+		{text: "/read@NamnadaBot https://a.com", wantCmd: "/read", wantArg: "https://a.com", wantMention: "NamnadaBot"},
+		{text: "https://user@example.com", wantCmd: "https://user@example.com"},
+		{text: "  ", wantCmd: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			cmd, arg, mention := parseCmd(tt.text)
+			if cmd != tt.wantCmd || arg != tt.wantArg || mention != tt.wantMention {
+				t.Errorf("parseCmd(%q) = %q, %q, %q; want %q, %q, %q",
+					tt.text, cmd, arg, mention, tt.wantCmd, tt.wantArg, tt.wantMention)
+			}
+		})
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,247 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"URLbot/pkg/format"
+	"URLbot/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDigestCount = 5  // Pages per digest when no count is given.
+	maxDigestCount     = 20 // Largest number of pages per digest.
+
+	// digestGrace is how late a digest may still be sent. Digests missed by
+	// more, e.g. while the bot was down, are skipped until their next time.
+	digestGrace = time.Hour
+)
+
+// digestArgs holds the parsed arguments of /digest.
+type digestArgs struct {
+	off    bool            // Turn the digest off.
+	digest *storage.Digest // New schedule, nil to show the current one.
+}
+
+// digest shows, sets or turns off the digest of the owner in the current chat.
+func (p *Processor) digest(req *Request) error {
+	args := req.Args.(digestArgs)
+	chatID, owner := req.Meta.ChatID, req.Owner
+
+	if args.off {
+		err := p.storage.RemoveDigest(chatID, owner)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoDigest) {
+				return p.reply(req.Meta, msgDigestNotSet)
+			}
+
+			return fmt.Errorf("failed to remove digest: %v", err)
+		}
+
+		return p.reply(req.Meta, msgDigestOff)
+	}
+
+	if args.digest == nil {
+		d, err := p.storage.Digest(chatID, owner)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoDigest) {
+				return p.reply(req.Meta, msgDigestNotSet)
+			}
+
+			return fmt.Errorf("failed to get digest: %v", err)
+		}
+
+		return p.reply(req.Meta, fmt.Sprintf(msgDigestFmt, describeDigest(d)))
+	}
+
+	d := args.digest
+	d.ChatID, d.Owner = chatID, owner
+	d.NextAt = d.Next(time.Now())
+
+	err := p.storage.SaveDigest(d)
+	if err != nil {
+		return fmt.Errorf("failed to save digest: %v", err)
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgDigestSetFmt, describeDigest(d)))
+}
+
+// SendDueDigests sends every digest that is due at now. It is meant to be run
+// by the scheduler. Each send is claimed in storage by moving the digest to its
+// next time before the message goes out, so overlapping runs never send the
+// same digest twice.
+func (p *Processor) SendDueDigests(ctx context.Context, now time.Time) error {
+	digests, err := p.storage.DueDigests(now)
+	if err != nil {
+		return fmt.Errorf("failed to get due digests: %v", err)
+	}
+
+	for _, d := range digests {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		claimed, err := p.storage.AdvanceDigest(d.ChatID, d.Owner, d.NextAt, d.Next(now))
+		if err != nil {
+			slog.Error("Failed to claim digest", "chat_id", d.ChatID, "owner", d.Owner, "err", err)
+			continue
+		}
+
+		if !claimed {
+			continue
+		}
+
+		if now.Sub(d.NextAt) > digestGrace {
+			slog.Info("Skipping missed digest", "chat_id", d.ChatID, "owner", d.Owner, "due", d.NextAt)
+			continue
+		}
+
+		err = p.sendDigest(d)
+		if err != nil {
+			slog.Error("Failed to send digest", "chat_id", d.ChatID, "owner", d.Owner, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// sendDigest sends up to d.Count random unread pages of the owner to the digest's chat.
+// Snoozed pages are left out. Nothing is sent if there are no unread pages.
+func (p *Processor) sendDigest(d *storage.Digest) error {
+	pages, err := p.storage.PickUnreadN(d.Owner, d.Count, storage.Uniform, nil)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to pick unread pages: %v", err)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(format.HTML.Bold(msgDigestHeader) + "\n\n")
+
+	for _, page := range pages {
+		builder.WriteString(pickLine(page))
+	}
+
+	err = p.client.SendMessage(d.ChatID, builder.String(),
+		telegram.WithParseMode(telegram.ParseModeHTML), telegram.WithoutPreview())
+	if err != nil {
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+
+	return nil
+}
+
+// location returns the time zone of the owner's digest in the chat, or UTC
+// if there is none. It is used wherever dates are shown to or read from users.
+func (p *Processor) location(chatID int, owner string) (*time.Location, error) {
+	d, err := p.storage.Digest(chatID, owner)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoDigest) {
+			return time.UTC, nil
+		}
+
+		return nil, fmt.Errorf("failed to get digest: %v", err)
+	}
+
+	return d.Location(), nil
+}
+
+// parseDigestArgs parses the arguments of /digest:
+//
+//	/digest                                  show the current digest
+//	/digest off                              turn the digest off
+//	/digest [daily] 09:00 [time zone] [count]
+//	/digest weekly mon 09:00 [time zone] [count]
+func parseDigestArgs(raw string) (any, error) {
+	fields := strings.Fields(raw)
+	usage := &UsageError{Msg: msgDigestUsage}
+
+	switch {
+	case len(fields) == 0:
+		return digestArgs{}, nil
+	case len(fields) == 1 && strings.EqualFold(fields[0], "off"):
+		return digestArgs{off: true}, nil
+	}
+
+	d := &storage.Digest{TimeZone: "UTC", Count: defaultDigestCount}
+
+	switch strings.ToLower(fields[0]) {
+	case "daily":
+		fields = fields[1:]
+	case "weekly":
+		if len(fields) < 2 {
+			return nil, usage
+		}
+
+		day, ok := parseWeekday(fields[1])
+		if !ok {
+			return nil, usage
+		}
+
+		d.Weekly, d.Weekday = true, day
+		fields = fields[2:]
+	}
+
+	if len(fields) == 0 {
+		return nil, usage
+	}
+
+	clock, err := time.Parse("15:04", fields[0])
+	if err != nil {
+		return nil, usage
+	}
+	d.Hour, d.Minute = clock.Hour(), clock.Minute()
+
+	for _, f := range fields[1:] {
+		if n, err := strconv.Atoi(f); err == nil {
+			if n < 1 || n > maxDigestCount {
+				return nil, &UsageError{Msg: fmt.Sprintf(msgDigestCountFmt, maxDigestCount)}
+			}
+			d.Count = n
+			continue
+		}
+
+		if _, err := time.LoadLocation(f); err != nil || strings.EqualFold(f, "local") {
+			return nil, &UsageError{Msg: msgDigestTimeZone}
+		}
+		d.TimeZone = f
+	}
+
+	return digestArgs{digest: d}, nil
+}
+
+// parseWeekday parses a day of the week such as "mon" or "Monday".
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(s)
+	if len(s) < 3 {
+		return 0, false
+	}
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.HasPrefix(strings.ToLower(day.String()), s) {
+			return day, true
+		}
+	}
+
+	return 0, false
+}
+
+// describeDigest renders the schedule of a digest and its next send time for the user.
+func describeDigest(d *storage.Digest) string {
+	when := "every day"
+	if d.Weekly {
+		when = "every " + d.Weekday.String()
+	}
+
+	next := d.NextAt.In(d.Location()).Format("Mon, 02 Jan 15:04")
+
+	return fmt.Sprintf("%s at %02d:%02d (%s), %d articles. Next: %s",
+		when, d.Hour, d.Minute, format.HTML.Escape(d.TimeZone), d.Count, next)
+}
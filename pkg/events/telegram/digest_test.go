@@ -0,0 +1,171 @@
+package telegram
+
+import (
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDigestArgs(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    digestArgs
+		wantErr string
+	}{
+		{raw: "", want: digestArgs{}},
+		{raw: "OFF", want: digestArgs{off: true}},
+		{
+			raw:  "9:30",
+			want: digestArgs{digest: &storage.Digest{Hour: 9, Minute: 30, TimeZone: "UTC", Count: defaultDigestCount}},
+		},
+		{
+			raw:  "daily 21:00 Europe/Berlin 3",
+			want: digestArgs{digest: &storage.Digest{Hour: 21, TimeZone: "Europe/Berlin", Count: 3}},
+		},
+		{
+			raw:  "weekly Monday 08:15 7 Asia/Tokyo",
+			want: digestArgs{digest: &storage.Digest{Weekly: true, Weekday: time.Monday, Hour: 8, Minute: 15, TimeZone: "Asia/Tokyo", Count: 7}},
+		},
+		{raw: "weekly 08:15", wantErr: msgDigestUsage},
+		{raw: "weekly mo 08:15", wantErr: msgDigestUsage},
+		{raw: "daily", wantErr: msgDigestUsage},
+		{raw: "25:00", wantErr: msgDigestUsage},
+		{raw: "09:00 Mars/Olympus", wantErr: msgDigestTimeZone},
+		{raw: "09:00 Local", wantErr: msgDigestTimeZone},
+		{raw: "09:00 100", wantErr: "between 1 and 20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseDigestArgs(tt.raw)
+
+			if tt.wantErr != "" {
+				var usageErr *UsageError
+				if !errors.As(err, &usageErr) || !strings.Contains(usageErr.Msg, tt.wantErr) {
+					t.Fatalf("parseDigestArgs(%q) error = %v, want %q", tt.raw, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseDigestArgs(%q) failed: %v", tt.raw, err)
+			}
+
+			args := got.(digestArgs)
+			if args.off != tt.want.off || (args.digest == nil) != (tt.want.digest == nil) ||
+				args.digest != nil && *args.digest != *tt.want.digest {
+				t.Errorf("parseDigestArgs(%q) = %+v, want %+v", tt.raw, args.digest, tt.want.digest)
+			}
+		})
+	}
+}
+
+func TestProcessor_digest(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	steps := []struct {
+		text     string
+		wantSend string
+	}{
+		{text: "/digest", wantSend: msgDigestNotSet},
+		{text: "/digest off", wantSend: msgDigestNotSet},
+		{text: "/digest weekly fri 18:00 Europe/Berlin 3", wantSend: "📬 Done! Your digest: every Friday at 18:00 (Europe/Berlin), 3 articles. Next: Fri"},
+		{text: "/digest", wantSend: "📬 Your digest: every Friday at 18:00"},
+		{text: "/digest off", wantSend: msgDigestOff},
+		{text: "/digest", wantSend: msgDigestNotSet},
+	}
+
+	for _, step := range steps {
+		client.sent = nil
+
+		err := p.doCmd(step.text, meta)
+		if err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", step.text, err)
+		}
+
+		if len(client.sent) != 1 || !strings.HasPrefix(client.sent[0], step.wantSend) {
+			t.Errorf("doCmd(%q) sent %q, want %q", step.text, client.sent, step.wantSend)
+		}
+	}
+}
+
+func TestProcessor_SendDueDigests(t *testing.T) {
+	s := memory.New()
+	now := time.Date(2026, 3, 4, 9, 0, 30, 0, time.UTC)
+
+	for _, page := range []*storage.Page{
+		{URL: "https://a.com", UserName: "alex"},
+		{URL: "https://b.com", UserName: "alex", ReadingTime: 4 * time.Minute},
+		{URL: "https://c.com", UserName: "alex", Read: true},
+		{URL: "https://d.com", UserName: "bob"},
+	} {
+		err := s.Save(page)
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	for _, d := range []*storage.Digest{
+		// Due half a minute ago.
+		{ChatID: 1, Owner: "alex", Hour: 9, TimeZone: "UTC", Count: 5, NextAt: now.Add(-30 * time.Second)},
+		// Missed while the bot was down.
+		{ChatID: 2, Owner: "bob", Hour: 6, TimeZone: "UTC", Count: 5, NextAt: now.Add(-3 * time.Hour)},
+		// Not due yet.
+		{ChatID: 3, Owner: "bob", Hour: 10, TimeZone: "UTC", Count: 5, NextAt: now.Add(time.Hour)},
+	} {
+		err := s.SaveDigest(d)
+		if err != nil {
+			t.Fatalf("SaveDigest() failed: %v", err)
+		}
+	}
+
+	client := &mockClient{}
+	p := New(client, s)
+
+	err := p.SendDueDigests(context.Background(), now)
+	if err != nil {
+		t.Fatalf("SendDueDigests() failed: %v", err)
+	}
+
+	if len(client.sent) != 1 {
+		t.Fatalf("sent %d digests, want 1: %q", len(client.sent), client.sent)
+	}
+
+	digest := client.sent[0]
+	for _, want := range []string{msgDigestHeader, "a.com", "b.com</a> ⏱️ 4 min"} {
+		if !strings.Contains(digest, want) {
+			t.Errorf("digest %q does not contain %q", digest, want)
+		}
+	}
+	if strings.Contains(digest, "c.com") {
+		t.Errorf("digest %q contains a read page", digest)
+	}
+
+	// A second run must not send anything again.
+	err = p.SendDueDigests(context.Background(), now.Add(10*time.Second))
+	if err != nil {
+		t.Fatalf("SendDueDigests() failed: %v", err)
+	}
+
+	if len(client.sent) != 1 {
+		t.Errorf("second run sent %q, want nothing", client.sent[1:])
+	}
+
+	for chatID, owner := range map[int]string{1: "alex", 2: "bob"} {
+		d, err := s.Digest(chatID, owner)
+		if err != nil {
+			t.Fatalf("Digest() failed: %v", err)
+		}
+
+		if !d.NextAt.After(now) {
+			t.Errorf("digest of chat %d was not moved to its next time: %v", chatID, d.NextAt)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"URLbot/pkg/feed"
+	"URLbot/pkg/format"
+	"URLbot/pkg/storage"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// feedTokenSize is the number of random bytes in a feed token. Feed tokens
+// are long-lived and the only protection of a feed, so they are longer than share tokens.
+const feedTokenSize = 16
+
+// feedNames are the labels of the feed formats in /feed replies.
+var feedNames = map[feed.Format]string{
+	feed.Atom: "Atom",
+	feed.RSS:  "RSS",
+	feed.JSON: "JSON Feed",
+}
+
+// sendFeeds replies with the feed links of the owner, creating a token on
+// first use. With "rotate" a new token replaces the old one, so leaked links
+// stop working. Only a hash of the token is stored, so the links are shown
+// once. Personal feeds are only sent in private chats; in a shared group the
+// feed of the group list is sent to the group.
+func (p *Processor) sendFeeds(req *Request) error {
+	if p.feedURL == "" {
+		return p.reply(req.Meta, msgFeedsDisabled)
+	}
+
+	if req.Meta.Group && req.Owner != chatOwner(req.Meta.ChatID) {
+		return p.reply(req.Meta, msgFeedPrivate)
+	}
+
+	rotate := req.Args.(bool)
+
+	_, err := p.storage.FeedToken(req.Owner)
+	switch {
+	case err == nil && !rotate:
+		return p.reply(req.Meta, msgFeedExists)
+	case err != nil && !errors.Is(err, storage.ErrNoFeedToken):
+		return fmt.Errorf("failed to get feed token: %v", err)
+	}
+
+	token, err := newToken(feedTokenSize)
+	if err != nil {
+		return err
+	}
+
+	err = p.storage.SetFeedToken(req.Owner, storage.HashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to save feed token: %v", err)
+	}
+
+	header := msgFeedHeader
+	if rotate {
+		header = msgFeedRotated
+	}
+
+	var builder strings.Builder
+	builder.WriteString(header + "\n\n")
+
+	for _, f := range feed.Formats {
+		fmt.Fprintf(&builder, "%s: %s\n", feedNames[f], format.HTML.Escape(feed.URL(p.feedURL, token, f, "")))
+	}
+
+	builder.WriteString("\n" + msgFeedFooter)
+
+	return p.reply(req.Meta, builder.String(), telegram.WithoutPreview())
+}
+
+// parseFeedArgs parses the arguments of /feed. It reports whether the token should be rotated.
+func parseFeedArgs(raw string) (any, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "":
+		return false, nil
+	case "rotate":
+		return true, nil
+	default:
+		return nil, &UsageError{Msg: msgFeedUsage}
+	}
+}
@@ -0,0 +1,104 @@
+package telegram
+
+import (
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestProcessor_sendFeeds(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	run := func(text string, meta Meta) string {
+		t.Helper()
+
+		client.sent = nil
+		if err := p.doCmd(text, meta); err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", text, err)
+		}
+		if len(client.sent) != 1 {
+			t.Fatalf("doCmd(%q) sent %q, want a single message", text, client.sent)
+		}
+
+		return client.sent[0]
+	}
+
+	tokenRe := regexp.MustCompile(`/feeds/([0-9a-f]+)/atom`)
+	sentToken := func(text string) string {
+		t.Helper()
+
+		m := tokenRe.FindStringSubmatch(text)
+		if m == nil {
+			t.Fatalf("no feed link in %q", text)
+		}
+
+		return m[1]
+	}
+
+	if got := run("/feed", meta); got != msgFeedsDisabled {
+		t.Errorf("/feed without a feed server sent %q, want %q", got, msgFeedsDisabled)
+	}
+
+	p.SetFeedURL("https://bot.example/")
+
+	first := run("/feed", meta)
+	token := sentToken(first)
+	if len(token) != 2*feedTokenSize {
+		t.Errorf("feed token %q has %d characters, want %d", token, len(token), 2*feedTokenSize)
+	}
+	for _, want := range []string{msgFeedHeader, "Atom: https://bot.example/feeds/" + token + "/atom", "JSON Feed: https://bot.example/feeds/" + token + "/json"} {
+		if !strings.Contains(first, want) {
+			t.Errorf("/feed sent %q, want it to contain %q", first, want)
+		}
+	}
+
+	stored, err := s.FeedToken("alex")
+	if err != nil {
+		t.Fatalf("FeedToken() after /feed failed: %v", err)
+	}
+	if stored == token || stored != storage.HashToken(token) {
+		t.Errorf("stored feed token %q, want the hash of the sent token", stored)
+	}
+
+	if again := run("/feed", meta); again != msgFeedExists {
+		t.Errorf("second /feed sent %q, want %q", again, msgFeedExists)
+	}
+
+	rotated := run("/feed rotate", meta)
+	newToken := sentToken(rotated)
+	if newToken == token || !strings.HasPrefix(rotated, msgFeedRotated) {
+		t.Errorf("/feed rotate sent %q, want new links", rotated)
+	}
+	if owner, err := s.FeedOwner(storage.HashToken(newToken)); err != nil || owner != "alex" {
+		t.Errorf("FeedOwner() of the rotated token = %q, %v; want alex", owner, err)
+	}
+
+	if got := run("/feed now", meta); got != msgFeedUsage {
+		t.Errorf("/feed now sent %q, want %q", got, msgFeedUsage)
+	}
+
+	group := Meta{ChatID: -100, UserName: "alex", MessageID: 7, Group: true}
+
+	for _, text := range []string{"/feed", "/feed rotate"} {
+		if got := run(text, group); got != msgFeedPrivate {
+			t.Errorf("%s in a personal group sent %q, want %q", text, got, msgFeedPrivate)
+		}
+	}
+	if stored, _ := s.FeedToken("alex"); stored != storage.HashToken(newToken) {
+		t.Error("/feed rotate in a group changed the personal feed token")
+	}
+
+	if err := s.SetChatMode(group.ChatID, storage.Shared); err != nil {
+		t.Fatalf("SetChatMode() failed: %v", err)
+	}
+
+	shared := sentToken(run("/feed", group))
+	if owner, err := s.FeedOwner(storage.HashToken(shared)); err != nil || owner != chatOwner(group.ChatID) {
+		t.Errorf("FeedOwner() of the group feed token = %q, %v; want the group list", owner, err)
+	}
+}
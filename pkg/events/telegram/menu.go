@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// CommandMenu abstracts the Telegram API methods that manage the bot's command menu.
+type CommandMenu interface {
+	GetMyCommands(scope telegram.BotCommandScope, languageCode string) ([]telegram.BotCommand, error)
+	SetMyCommands(commands []telegram.BotCommand, scope telegram.BotCommandScope, languageCode string) error
+}
+
+// menuScopes maps the Telegram command scopes to the kinds of commands offered in them.
+var menuScopes = []struct {
+	scope   string
+	exclude ChatScope
+}{
+	{scope: telegram.ScopeAllPrivateChats, exclude: GroupOnly},
+	{scope: telegram.ScopeAllGroupChats, exclude: PrivateOnly},
+}
+
+// SyncCommands publishes the command menu for private and group chats in the default
+// language and in each of the given languages. Menus that are already up to date
+// are left unchanged.
+func SyncCommands(menu CommandMenu, commands []Command, languages ...string) error {
+	languages = append([]string{""}, languages...)
+
+	for _, s := range menuScopes {
+		scope := telegram.BotCommandScope{Type: s.scope}
+
+		for _, lang := range languages {
+			want := botCommands(commands, s.exclude, lang)
+
+			got, err := menu.GetMyCommands(scope, lang)
+			if err != nil {
+				return fmt.Errorf("failed to get commands for %s/%q: %v", s.scope, lang, err)
+			}
+
+			if reflect.DeepEqual(got, want) {
+				slog.Debug("SyncCommands: menu is up to date", "scope", s.scope, "lang", lang)
+				continue
+			}
+
+			err = menu.SetMyCommands(want, scope, lang)
+			if err != nil {
+				return fmt.Errorf("failed to set commands for %s/%q: %v", s.scope, lang, err)
+			}
+
+			slog.Info("Command menu updated", "scope", s.scope, "lang", lang, "commands", len(want))
+		}
+	}
+
+	return nil
+}
+
+// botCommands converts registered commands to Telegram menu entries,
+// skipping the commands limited to the excluded kind of chats.
+func botCommands(commands []Command, exclude ChatScope, languageCode string) []telegram.BotCommand {
+	res := make([]telegram.BotCommand, 0, len(commands))
+
+	for _, cmd := range commands {
+		if cmd.Scope == exclude {
+			continue
+		}
+
+		res = append(res, telegram.BotCommand{
+			Command:     strings.TrimPrefix(cmd.Name, "/"),
+			Description: cmd.DescriptionFor(languageCode),
+		})
+	}
+
+	return res
+}
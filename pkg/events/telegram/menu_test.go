@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"testing"
+)
+
+type mockMenu struct {
+	menus map[string][]telegram.BotCommand
+	sets  int
+}
+
+func (m *mockMenu) GetMyCommands(scope telegram.BotCommandScope, languageCode string) ([]telegram.BotCommand, error) {
+	return m.menus[scope.Type+"/"+languageCode], nil
+}
+
+func (m *mockMenu) SetMyCommands(commands []telegram.BotCommand, scope telegram.BotCommandScope, languageCode string) error {
+	m.menus[scope.Type+"/"+languageCode] = commands
+	m.sets++
+	return nil
+}
+
+func TestSyncCommands(t *testing.T) {
+	commands := New(&mockClient{}, &mockStorage{}).Commands()
+	menu := &mockMenu{menus: make(map[string][]telegram.BotCommand)}
+
+	err := SyncCommands(menu, commands, "ru")
+	if err != nil {
+		t.Fatalf("SyncCommands() failed: %v", err)
+	}
+
+	if menu.sets != 4 {
+		t.Errorf("first sync made %d updates, want 4", menu.sets)
+	}
+
+	for _, c := range menu.menus[telegram.ScopeAllPrivateChats+"/"] {
+		if c.Command == "groupmode" {
+			t.Error("group-only command is offered in private chats")
+		}
+	}
+
+	if got := menu.menus[telegram.ScopeAllGroupChats+"/ru"][0].Description; got != "Показать приветствие" {
+		t.Errorf("unexpected translated description %q", got)
+	}
+
+	err = SyncCommands(menu, commands, "ru")
+	if err != nil {
+		t.Fatalf("SyncCommands() failed: %v", err)
+	}
+
+	if menu.sets != 4 {
+		t.Errorf("second sync made %d extra updates, want 0", menu.sets-4)
+	}
+}
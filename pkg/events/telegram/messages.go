@@ -1,6 +1,9 @@
 package telegram
 
-const msgHello = `🪩 Welcome to NAMNADA LINK - your personal reading list assistant!
+// Messages are sent with the HTML parse mode, so any user-supplied text
+// must be escaped with the format package before being inserted.
+
+const msgHello = `🪩 Welcome to <b>NAMNADA LINK</b> - your personal reading list assistant!
 
 Just send me a link - I'll save it.
 
@@ -14,23 +17,164 @@ You can also manage your links 🔗
 
 Happy reading! 📬`
 
-const msgHelp = `🧿 NAMNADA LINK can help you save and manage links to read later.
+const msgHelpHeader = `🧿 <b>NAMNADA LINK</b> can help you save and manage links to read later.
 Here’s what you can do:
 
-/random - Get a random unread article  
-/read - Mark an article as read  
-/remove - Delete an article  
-/list - Show all saved articles  
-/help - Show this help message
+`
 
+const msgHelpFooter = `
 Just send me any link, and I’ll save it automatically! 💾`
 
+const msgModeList = `
+
+uniform - any unread article
+oldest - the article saved first
+newest - the article saved last
+weighted - random, older articles more often
+short - the shortest read first
+spaced - brings articles back at growing intervals`
+
 const (
-	msgSaved          = "💾 Saved to your reading list!"
-	msgNoSavedPages   = "🕰️ You have no saved pages yet.\nJust send me a link to get started!"
-	msgAlreadyExists  = "📰 This page is already in your list"
-	msgMarkedAsRead   = "🧮 Marked as read!"
-	msgRemoved        = "🗑️ Page removed!"
-	msgUnknownCommand = "🥡 I didn't understand that command.\nTry /help to see what I can do!"
-	msgURLRequired    = "🔗 Please provide a valid URL"
+	msgSaved             = "💾 Saved to your reading list!"
+	msgNoSavedPages      = "🕰️ You have no saved pages yet.\nJust send me a link to get started!"
+	msgNoMatchingPages   = "🕰️ No unread articles of this length yet"
+	msgRandomUsage       = "🎲 Usage: /random [count] [short|long]"
+	msgRandomCountFmt    = "🎲 You can get from 1 to %d articles at once"
+	msgPicksHeader       = "🎲 Your random picks"
+	msgReadingTimeFmt    = "⏱️ %d min read, %d words"
+	msgAlreadyExists     = "📰 This page is already in your list"
+	msgMarkedAsRead      = "🧮 Marked as read!"
+	msgMarkedAsUnread    = "📖 Marked as unread, it's back in /random"
+	msgAlreadyUnread     = "📖 This article is not read yet"
+	msgUnreadUsage       = "📖 Usage: /unread &lt;id&gt;"
+	msgRemoved           = "🗑️ Moved to the trash. Changed your mind? Use /undo"
+	msgUnknownCommand    = "🥡 I didn't understand that command.\nTry /help to see what I can do!"
+	msgURLRequired       = "🔗 Please provide a valid URL"
+	msgUpdated           = "✏️ Link updated in your reading list!"
+	msgNoLinkInForward   = "🔗 I couldn't find a link in the forwarded message"
+	msgGroupOnly         = "👥 This command works only in group chats"
+	msgGroupModeUsage    = "👥 Usage: /groupmode personal|shared"
+	msgGroupModeFmt      = "👥 This chat uses %s reading lists"
+	msgGroupModeSetFmt   = "👥 Done! This chat now uses %s reading lists"
+	msgGroupAdminOnly    = "👥 Only chat administrators can change the list mode"
+	msgNotAllowed        = "⛔ Sorry, you are not allowed to use this bot"
+	msgTooManyRequests   = "⏳ Too many requests, please slow down a bit"
+	msgExportUsage       = "📦 Usage: /export json|csv|html|md"
+	msgExportFmt         = "📦 Your reading list: %d links"
+	msgImportUsage       = "📥 Send a file (Pocket CSV, browser bookmarks or a text file with links) with the /import caption"
+	msgImportTooLarge    = "📥 This file is too large to import"
+	msgImportFailed      = "📥 I couldn't find any links in this file"
+	msgImportDoneFmt     = "📥 Import finished!\nImported: %d\nSkipped (already saved): %d\nInvalid: %d"
+	msgPageNotFound      = "🔎 There is no article with this ID in your list.\nUse /list to see the IDs"
+	msgSnapshotUsage     = "🗄️ Usage: /snapshot &lt;id&gt;"
+	msgSnapshotsDisabled = "🗄️ Offline copies are not enabled on this bot"
+	msgNoSnapshot        = "🗄️ There is no offline copy of this article yet"
+	msgBrokenUsage       = "🩺 Usage: /broken [remove|fix] [id]"
+	msgNoBrokenLinks     = "🩺 All checked links are alive"
+	msgBrokenFooter      = "Use /broken remove to delete dead links or /broken fix to replace moved links with their new address"
+	msgBrokenRemovedFmt  = "🗑️ Dead links removed: %d"
+	msgBrokenFixedFmt    = "🔀 Moved links updated: %d"
+	msgBrokenSkippedFmt  = "Skipped because the new link is already saved: %d"
+	msgDigestHeader      = "📬 Your reading digest"
+	msgDigestUsage       = "📬 Usage:\n/digest 09:00 Europe/Berlin 5 - every day\n/digest weekly mon 09:00 Europe/Berlin 5 - every week\n/digest off - stop the digest"
+	msgDigestNotSet      = "📬 You have no digest in this chat yet.\nTry /digest 09:00 Europe/Berlin 5"
+	msgDigestFmt         = "📬 Your digest: %s"
+	msgDigestSetFmt      = "📬 Done! Your digest: %s"
+	msgDigestOff         = "📬 Digest turned off"
+	msgDigestCountFmt    = "📬 The number of articles must be between 1 and %d"
+	msgDigestTimeZone    = "📬 Unknown time zone, use a name like Europe/Berlin or UTC"
+	msgSnoozeUsage       = "💤 Usage: /snooze &lt;id&gt; 3d|2w|12h|2026-12-01|off"
+	msgSnoozeRange       = "💤 Please pick a time in the future, at most two years from now"
+	msgSnoozedFmt        = "💤 Snoozed until %s. I'll remind you then!"
+	msgSnoozeOff         = "💤 Snooze cancelled"
+	msgReminder          = "⏰ Time to get back to this article:\n\n"
+	msgModeFmt           = "🎲 /random uses the <b>%s</b> mode"
+	msgModeSetFmt        = "🎲 Done! /random now uses the <b>%s</b> mode"
+	msgModeUsage         = "🎲 Usage: /mode uniform|oldest|newest|weighted|short|spaced"
+	msgStatsHeader       = "📊 Your reading stats"
+	msgStatsTotalFmt     = "Saved: %d (%d read, %d unread)"
+	msgStatsWeekFmt      = "This week: %d saved, %d read"
+	msgStatsMonthFmt     = "This month: %d saved, %d read"
+	msgStatsAvgFmt       = "Average time to read: %s"
+	msgStatsStreakFmt    = "Reading streak: %s 🔥"
+	msgStatsNoStreak     = "Reading streak: none yet, read something today!"
+	msgStatsTopDomains   = "Top sites:"
+	msgTagUsage          = "🏷️ Usage: /tag &lt;id&gt; tag -tag ..."
+	msgTagsFmt           = "🏷️ Tags of #%d: %s"
+	msgNoTagsFmt         = "🏷️ #%d has no tags now"
+	msgNothingToUndo     = "↩️ Nothing to undo"
+	msgUndoneFmt         = "↩️ Undone: %s %s"
+	msgUndoGone          = "↩️ This change can't be undone anymore, the article has changed since"
+	msgTrashHeaderFmt    = "🗑️ Trash (articles are deleted for good after %d days)"
+	msgTrashEmpty        = "🗑️ The trash is empty"
+	msgTrashFooter       = "Use /trash restore &lt;id&gt; to bring an article back"
+	msgTrashUsage        = "🗑️ Usage: /trash [restore &lt;id&gt;]"
+	msgNotInTrash        = "🗑️ There is no article with this ID in the trash"
+	msgRestoredFmt       = "♻️ Restored: %s"
+	msgRestoreExists     = "♻️ This link is already in your list again"
+	msgCollUsage         = "🗂️ Usage:\n/collection - list your collections\n/collection new|delete|show \"Deep Work\"\n/collection rename \"Deep Work\" Focus\n/collection add Focus &lt;id&gt; [position]\n/collection remove Focus &lt;id&gt;\n/collection order Focus &lt;id&gt; &lt;position&gt;\n/collection move &lt;id&gt; Focus Weekend\nCollections shared with you are named like @alex/Focus"
+	msgCollNameFmt       = "🗂️ Collection names can be up to %d characters long"
+	msgNoCollections     = "🗂️ You have no collections yet.\nCreate one with /collection new \"Deep Work\""
+	msgCollsHeader       = "🗂️ Your collections"
+	msgCollFooter        = "Use /collection show &lt;name&gt; to see one or /next &lt;name&gt; to read it in order"
+	msgCollExists        = "🗂️ You already have a collection with this name"
+	msgNoCollFmt         = "🗂️ There is no collection named %s. See /collection"
+	msgCollEmptyFmt      = "🗂️ %s is empty. Add articles with /collection add"
+	msgCollCreatedFmt    = "🗂️ Collection %s created"
+	msgCollRenamedFmt    = "🗂️ Collection renamed to %s"
+	msgCollDeletedFmt    = "🗂️ Collection %s deleted, its articles are kept"
+	msgCollPosFmt        = "🗂️ #%d is at position %d in %s"
+	msgCollRemovedFmt    = "🗂️ Removed #%d from %s"
+	msgCollMovedFmt      = "🗂️ Moved #%d from %s to %s"
+	msgNotInCollFmt      = "🗂️ #%d is not in %s"
+	msgCollDoneFmt       = "🗂️ Nothing left to read in %s 🎉"
+	msgNextUsage         = "🗂️ Usage: /next &lt;collection&gt;"
+	msgCollForbidden     = "🔒 You don't have permission to do this with this collection"
+	msgCollMoveOwners    = "🗂️ Articles can only be moved between collections of the same owner"
+	msgCollAtName        = "🗂️ Collection names can't start with @"
+	msgShareUsage        = "📤 Usage:\n/share &lt;id&gt; [@user] - share an article\n/share collection &lt;name&gt; [@user] [read|edit] - share a collection\nWithout @user you get a link that anyone can open"
+	msgShareSelf         = "📤 You can't share with yourself"
+	msgShareSentFmt      = "📤 Sent to @%s, they'll be asked to accept it"
+	msgShareLinkFmt      = "📤 Anyone who opens this link can accept your share:\n%s"
+	msgShareUnknownFmt   = "📤 @%s hasn't started this bot yet, so I can't message them. Send them this link instead:\n%s"
+	msgShareGone         = "📥 This share is no longer available"
+	msgShareNotYours     = "📥 This share was meant for someone else"
+	msgShareOwn          = "📥 This is already yours"
+	msgSharePageFmt      = "📥 %s shared an article with you:\n%s"
+	msgShareCollFmt      = "📥 %s invited you to the collection %s (%s)"
+	msgShareAccept       = "✅ Accept"
+	msgShareDecline      = "✖️ Decline"
+	msgShareDeclined     = "✖️ Declined"
+	msgShareSavedFmt     = "✅ Saved to your list: %s"
+	msgShareJoinedFmt    = "✅ You joined %s (%s). Read it with /next %s"
+	msgUnshareUsage      = "👥 Usage: /unshare &lt;collection&gt; @user"
+	msgUnsharedFmt       = "👥 @%s no longer has access to %s"
+	msgNotMemberFmt      = "👥 @%s is not a member of %s"
+	msgFeedUsage         = "📡 Usage: /feed [rotate]"
+	msgFeedsDisabled     = "📡 Feeds are not enabled on this bot"
+	msgFeedHeader        = "📡 Your feeds, keep these links private:"
+	msgFeedRotated       = "📡 New feed links created, the old ones no longer work:"
+	msgFeedExists        = "📡 Your feed links were already sent and cannot be shown again. Use /feed rotate to get new ones, the old ones will stop working"
+	msgFeedPrivate       = "📡 Your personal feed links are only sent in a private chat with me"
+	msgFeedFooter        = "Add ?collection=&lt;name&gt; to a link to follow a single collection. Use /feed rotate if a link leaks"
+	msgSubscribeUsage    = "📰 Usage: /subscribe &lt;feed-url&gt; [tag]"
+	msgUnsubscribeUsage  = "📰 Usage: /unsubscribe &lt;feed-url|n&gt;"
+	msgSubsDisabled      = "📰 Subscriptions are not enabled on this bot"
+	msgSubExists         = "📰 You already follow this feed"
+	msgSubNotAFeed       = "📰 This link is not an RSS or Atom feed"
+	msgSubFailedFmt      = "📰 Couldn't read the feed: %s"
+	msgSubscribedFmt     = "📰 Subscribed to %s. New articles will be saved to your list"
+	msgSubTagFmt         = " with #%s"
+	msgNoSubs            = "📰 You don't follow any feeds. Add one with /subscribe &lt;feed-url&gt;"
+	msgSubsHeader        = "📰 <b>Your subscriptions:</b>"
+	msgSubsFooter        = "Stop following a feed with /unsubscribe &lt;n&gt;"
+	msgNoSub             = "📰 No such subscription, see /subscriptions"
+	msgUnsubscribedFmt   = "📰 Unsubscribed from %s"
+	msgSubNewFmt         = "📰 <b>%d new from your subscriptions:</b>"
+	msgSubMoreFmt        = "…and %d more, see /list"
+	msgTokenUsage        = "🔑 Usage: /token [revoke]"
+	msgAPIDisabled       = "🔑 The HTTP API is not enabled on this bot"
+	msgTokenPrivate      = "🔑 API tokens are only sent in a private chat with me"
+	msgTokenRevoked      = "🔑 Your API token no longer works"
+	msgTokenFmt          = "🔑 Your new API token, keep it secret. Any older token no longer works:\n%s\n\nSend it as <code>Authorization: Bearer &lt;token&gt;</code> to %s. Use /token revoke if it leaks"
 )
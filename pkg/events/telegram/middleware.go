@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Sender sends text replies to a chat. Client satisfies it.
+type Sender interface {
+	SendMessage(chatID int, text string, opts ...telegram.SendOption) error
+}
+
+// Logging logs every handled request with its duration and result.
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			start := time.Now()
+
+			err := next(req)
+
+			attrs := []any{
+				"cmd", req.Cmd,
+				"username", req.Meta.UserName,
+				"chat_id", req.Meta.ChatID,
+				"duration", time.Since(start),
+			}
+			var usageErr *UsageError
+			if err != nil && !errors.As(err, &usageErr) {
+				slog.Error("command failed", append(attrs, "err", err)...)
+			} else {
+				slog.Info("command handled", attrs...)
+			}
+
+			return err
+		}
+	}
+}
+
+// Recover converts a panic in a handler into an error, so one broken command
+// cannot bring down the whole process.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("command panicked", "cmd", req.Cmd, "panic", r, "stack", string(debug.Stack()))
+					err = fmt.Errorf("command %s panicked: %v", req.Cmd, r)
+				}
+			}()
+
+			return next(req)
+		}
+	}
+}
+
+// AllowUsers restricts the bot to the given usernames. Everyone else gets
+// a refusal message. An empty list allows all users.
+func AllowUsers(sender Sender, users ...string) Middleware {
+	allowed := make(map[string]struct{}, len(users))
+	for _, u := range users {
+		allowed[u] = struct{}{}
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			if len(allowed) == 0 {
+				return next(req)
+			}
+
+			if _, ok := allowed[req.Meta.UserName]; !ok {
+				slog.Warn("command from unauthorized user", "username", req.Meta.UserName, "cmd", req.Cmd)
+				return sender.SendMessage(req.Meta.ChatID, msgNotAllowed)
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// RateLimit allows each user at most limit requests per interval.
+// Requests over the limit are answered with a warning and are not executed.
+// Users are told apart by ID, since not everyone has a username; channel posts,
+// which have no user, are limited per channel. Expired windows are dropped
+// at most once per interval, so only users active in the last two intervals
+// are kept in memory.
+func RateLimit(sender Sender, limit int, interval time.Duration) Middleware {
+	var mu sync.Mutex
+	windows := make(map[int]*rateWindow)
+	lastPrune := time.Now()
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			now := time.Now()
+
+			key := req.Meta.UserID
+			if key == 0 {
+				key = req.Meta.ChatID
+			}
+
+			mu.Lock()
+			if now.Sub(lastPrune) >= interval {
+				for k, w := range windows {
+					if now.Sub(w.start) >= interval {
+						delete(windows, k)
+					}
+				}
+				lastPrune = now
+			}
+
+			w, ok := windows[key]
+			if !ok || now.Sub(w.start) >= interval {
+				w = &rateWindow{start: now}
+				windows[key] = w
+			}
+			w.count++
+			exceeded, warn := w.count > limit, w.count == limit+1
+			mu.Unlock()
+
+			if warn {
+				return sender.SendMessage(req.Meta.ChatID, msgTooManyRequests)
+			}
+			if exceeded {
+				return nil
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// rateWindow counts requests of a single user in a fixed time window.
+type rateWindow struct {
+	start time.Time
+	count int
+}
@@ -0,0 +1,199 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownCommand is returned by Router.Dispatch for commands that are not registered.
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Request describes a single command invocation passed through the middleware chain.
+// Args holds the value returned by the command's argument parser.
+type Request struct {
+	Cmd   string
+	Raw   string
+	Args  any
+	Owner string
+	Meta  Meta
+}
+
+// HandlerFunc handles a single command request.
+type HandlerFunc func(req *Request) error
+
+// Middleware wraps a handler with additional behavior such as logging or rate limiting.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// ArgParser converts the raw argument string of a command into a value for its handler.
+// Errors returned by a parser are shown to the user, so they should be UsageError values.
+type ArgParser func(raw string) (any, error)
+
+// UsageError reports invalid command arguments. Its message is sent to the user as is.
+type UsageError struct {
+	Msg string
+}
+
+// Error implements the error interface.
+func (e *UsageError) Error() string {
+	return e.Msg
+}
+
+// ChatScope limits the kinds of chats a command is offered in.
+type ChatScope int
+
+const (
+	AnyChat     ChatScope = iota // Command works everywhere.
+	PrivateOnly                  // Command is meant for private chats with the bot.
+	GroupOnly                    // Command is meant for group chats.
+)
+
+// Command describes a single bot command registered in a Router.
+type Command struct {
+	Name         string            // Command name including the slash, e.g. "/read".
+	Usage        string            // Argument synopsis shown in help, e.g. "<url>".
+	Description  string            // Short description shown in help and the Telegram menu.
+	Translations map[string]string // Menu descriptions by language code, e.g. "ru".
+	Scope        ChatScope         // Kinds of chats the command is offered in.
+	Parse        ArgParser         // Optional argument parser; the raw string is passed when nil.
+	Handler      HandlerFunc       // Function that executes the command.
+}
+
+// DescriptionFor returns the command description in the given language,
+// falling back to the default description.
+func (c Command) DescriptionFor(languageCode string) string {
+	if d, ok := c.Translations[languageCode]; ok {
+		return d
+	}
+
+	return c.Description
+}
+
+// Router keeps the registry of bot commands and the middleware chain applied to them.
+type Router struct {
+	commands   map[string]*Command
+	order      []string
+	middleware []Middleware
+}
+
+// NewRouter creates an empty Router.
+func NewRouter() *Router {
+	return &Router{
+		commands: make(map[string]*Command),
+	}
+}
+
+// Register adds a command to the registry. It panics if the command is
+// malformed or already registered, since that is a programming error.
+func (r *Router) Register(cmd Command) {
+	if !strings.HasPrefix(cmd.Name, "/") || cmd.Handler == nil {
+		panic(fmt.Sprintf("telegram: invalid command %q", cmd.Name))
+	}
+
+	if _, ok := r.commands[cmd.Name]; ok {
+		panic(fmt.Sprintf("telegram: command %q registered twice", cmd.Name))
+	}
+
+	r.commands[cmd.Name] = &cmd
+	r.order = append(r.order, cmd.Name)
+}
+
+// Use appends middleware to the chain. Middleware registered first runs outermost.
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Commands returns all registered commands in registration order.
+func (r *Router) Commands() []Command {
+	res := make([]Command, 0, len(r.order))
+	for _, name := range r.order {
+		res = append(res, *r.commands[name])
+	}
+
+	return res
+}
+
+// Dispatch parses the arguments of the requested command and runs its handler
+// through the middleware chain. It returns ErrUnknownCommand if there is no such command.
+func (r *Router) Dispatch(req *Request) error {
+	cmd, ok := r.commands[req.Cmd]
+	if !ok {
+		return ErrUnknownCommand
+	}
+
+	return r.Handle(req, func(req *Request) error {
+		req.Args = req.Raw
+		if cmd.Parse != nil {
+			args, err := cmd.Parse(req.Raw)
+			if err != nil {
+				return err
+			}
+			req.Args = args
+		}
+
+		return cmd.Handler(req)
+	})
+}
+
+// Handle runs an arbitrary handler through the middleware chain.
+func (r *Router) Handle(req *Request, h HandlerFunc) error {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+
+	return h(req)
+}
+
+// Help renders the list of registered commands, one per line.
+func (r *Router) Help() string {
+	var builder strings.Builder
+
+	for _, cmd := range r.Commands() {
+		builder.WriteString(cmd.Name)
+		if cmd.Usage != "" {
+			builder.WriteString(" " + cmd.Usage)
+		}
+		builder.WriteString(" - " + cmd.Description + "\n")
+	}
+
+	return builder.String()
+}
+
+// requireArg is an ArgParser for commands that need a single argument.
+// It returns the first word of the raw string, or a UsageError with msg if there is none.
+func requireArg(msg string) ArgParser {
+	return func(raw string) (any, error) {
+		fields := strings.Fields(raw)
+		if len(fields) == 0 {
+			return nil, &UsageError{Msg: msg}
+		}
+
+		return fields[0], nil
+	}
+}
+
+// requireID is an ArgParser for commands that take a page ID.
+// It returns the ID as an int, or a UsageError with msg if it is missing or invalid.
+func requireID(msg string) ArgParser {
+	return func(raw string) (any, error) {
+		fields := strings.Fields(raw)
+		if len(fields) == 0 {
+			return nil, &UsageError{Msg: msg}
+		}
+
+		id, ok := parseID(fields[0])
+		if !ok {
+			return nil, &UsageError{Msg: msg}
+		}
+
+		return id, nil
+	}
+}
+
+// parseID parses a page ID such as "12" or "#12".
+func parseID(s string) (int, bool) {
+	id, err := strconv.Atoi(strings.TrimPrefix(s, "#"))
+
+	return id, err == nil && id > 0
+}
@@ -0,0 +1,176 @@
+package telegram
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouter_Dispatch(t *testing.T) {
+	var got any
+
+	r := NewRouter()
+	r.Register(Command{
+		Name:        "/echo",
+		Usage:       "<text>",
+		Description: "Echo the argument",
+		Parse:       requireArg("text required"),
+		Handler: func(req *Request) error {
+			got = req.Args
+			return nil
+		},
+	})
+	r.Register(Command{
+		Name:        "/panic",
+		Description: "Always panics",
+		Handler: func(req *Request) error {
+			panic("boom")
+		},
+	})
+	r.Use(Recover())
+
+	tests := []struct {
+		name    string
+		req     *Request
+		wantArg any
+		wantErr error
+	}{
+		{
+			name:    "command with argument",
+			req:     &Request{Cmd: "/echo", Raw: "hello world"},
+			wantArg: "hello",
+		},
+		{
+			name:    "missing argument",
+			req:     &Request{Cmd: "/echo"},
+			wantErr: &UsageError{Msg: "text required"},
+		},
+		{
+			name:    "unknown command",
+			req:     &Request{Cmd: "/nope"},
+			wantErr: ErrUnknownCommand,
+		},
+		{
+			name:    "panicking handler",
+			req:     &Request{Cmd: "/panic"},
+			wantErr: errors.New("command /panic panicked: boom"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+
+			err := r.Dispatch(tt.req)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("Dispatch() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Dispatch() failed: %v", err)
+			}
+
+			if got != tt.wantArg {
+				t.Errorf("handler got args %v, want %v", got, tt.wantArg)
+			}
+		})
+	}
+
+	help := r.Help()
+	if !strings.Contains(help, "/echo <text> - Echo the argument") {
+		t.Errorf("unexpected help: %q", help)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		mw       func(s Sender) Middleware
+		user     string
+		calls    int
+		wantRuns int
+		wantSent int
+	}{
+		{
+			name:     "allowed user",
+			mw:       func(s Sender) Middleware { return AllowUsers(s, "alex") },
+			user:     "alex",
+			calls:    1,
+			wantRuns: 1,
+		},
+		{
+			name:     "forbidden user",
+			mw:       func(s Sender) Middleware { return AllowUsers(s, "alex") },
+			user:     "bob",
+			calls:    1,
+			wantSent: 1,
+		},
+		{
+			name:     "empty allow list",
+			mw:       func(s Sender) Middleware { return AllowUsers(s) },
+			user:     "bob",
+			calls:    1,
+			wantRuns: 1,
+		},
+		{
+			name:     "rate limit exceeded",
+			mw:       func(s Sender) Middleware { return RateLimit(s, 2, time.Minute) },
+			user:     "alex",
+			calls:    5,
+			wantRuns: 2,
+			wantSent: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockClient{}
+			runs := 0
+
+			h := tt.mw(client)(func(req *Request) error {
+				runs++
+				return nil
+			})
+
+			for i := 0; i < tt.calls; i++ {
+				err := h(&Request{Meta: Meta{UserID: 1, UserName: tt.user}})
+				if err != nil {
+					t.Fatalf("handler failed: %v", err)
+				}
+			}
+
+			if runs != tt.wantRuns {
+				t.Errorf("handler ran %d times, want %d", runs, tt.wantRuns)
+			}
+			if len(client.sent) != tt.wantSent {
+				t.Errorf("sent %d messages, want %d", len(client.sent), tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestRateLimit_usersWithoutUsername(t *testing.T) {
+	client := &mockClient{}
+	runs := 0
+
+	h := RateLimit(client, 1, time.Minute)(func(req *Request) error {
+		runs++
+		return nil
+	})
+
+	// Users without a username are still limited one by one.
+	for _, id := range []int{1, 2, 1} {
+		err := h(&Request{Meta: Meta{ChatID: id, UserID: id}})
+		if err != nil {
+			t.Fatalf("handler failed: %v", err)
+		}
+	}
+
+	if runs != 2 {
+		t.Errorf("handler ran %d times, want once per user", runs)
+	}
+	if len(client.sent) != 1 {
+		t.Errorf("sent %d messages, want one warning", len(client.sent))
+	}
+}
@@ -0,0 +1,61 @@
+package telegram
+
+import "container/list"
+
+// maxSavedMessages is how many messages that saved a link are remembered,
+// so that edits of older messages are ignored.
+const maxSavedMessages = 10000
+
+// savedLinks remembers the link saved from each message, up to a fixed number
+// of messages. When it is full, the least recently used message is forgotten.
+// It is not safe for concurrent use.
+type savedLinks struct {
+	size  int
+	order *list.List // Of *savedLink, most recently used first.
+	links map[messageKey]*list.Element
+}
+
+// savedLink is the link saved from a message.
+type savedLink struct {
+	key     messageKey
+	pageURL string
+}
+
+// newSavedLinks creates a savedLinks that remembers up to size messages.
+func newSavedLinks(size int) *savedLinks {
+	return &savedLinks{
+		size:  size,
+		order: list.New(),
+		links: make(map[messageKey]*list.Element),
+	}
+}
+
+// put records the link saved from a message, forgetting the least recently
+// used message if there are too many.
+func (s *savedLinks) put(key messageKey, pageURL string) {
+	if el, ok := s.links[key]; ok {
+		el.Value.(*savedLink).pageURL = pageURL
+		s.order.MoveToFront(el)
+		return
+	}
+
+	s.links[key] = s.order.PushFront(&savedLink{key: key, pageURL: pageURL})
+
+	if s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.links, oldest.Value.(*savedLink).key)
+	}
+}
+
+// get returns the link saved from a message, or "" if it is not remembered.
+func (s *savedLinks) get(key messageKey) string {
+	el, ok := s.links[key]
+	if !ok {
+		return ""
+	}
+
+	s.order.MoveToFront(el)
+
+	return el.Value.(*savedLink).pageURL
+}
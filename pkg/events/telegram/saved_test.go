@@ -0,0 +1,37 @@
+package telegram
+
+import "testing"
+
+func TestSavedLinks(t *testing.T) {
+	s := newSavedLinks(2)
+
+	first := messageKey{chatID: 1, messageID: 1}
+	second := messageKey{chatID: 1, messageID: 2}
+	third := messageKey{chatID: 2, messageID: 1}
+
+	s.put(first, "https://one.example.com")
+	s.put(second, "https://two.example.com")
+
+	// Reading the first message makes the second one the least recently used.
+	if got := s.get(first); got != "https://one.example.com" {
+		t.Errorf("get(first) = %q, want https://one.example.com", got)
+	}
+
+	s.put(third, "https://three.example.com")
+
+	if s.order.Len() != 2 {
+		t.Errorf("remembered %d messages, want 2", s.order.Len())
+	}
+	if got := s.get(second); got != "" {
+		t.Errorf("get(second) after eviction = %q, want it forgotten", got)
+	}
+	if got := s.get(first); got != "https://one.example.com" {
+		t.Errorf("get(first) = %q, want https://one.example.com", got)
+	}
+
+	s.put(third, "https://four.example.com")
+
+	if got := s.get(third); got != "https://four.example.com" || s.order.Len() != 2 {
+		t.Errorf("get(third) after update = %q with %d messages, want https://four.example.com with 2", got, s.order.Len())
+	}
+}
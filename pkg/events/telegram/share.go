@@ -0,0 +1,403 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"URLbot/pkg/format"
+	"URLbot/pkg/storage"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Callback data of the buttons under a share offer, followed by the share token.
+const (
+	acceptSharePrefix  = "share:accept:"
+	declineSharePrefix = "share:decline:"
+)
+
+// shareTokenSize is the number of random bytes in a share token.
+const shareTokenSize = 8
+
+// permissionNames describe collection permissions to the user.
+var permissionNames = map[storage.Permission]string{
+	storage.ReadOnly:     "read-only",
+	storage.Collaborator: "can edit",
+	storage.FullAccess:   "owner",
+}
+
+// shareArgs holds the parsed arguments of /share.
+type shareArgs struct {
+	id         int                // Shared page, zero for collection shares.
+	collection string             // Shared collection.
+	to         string             // Recipient without the "@", empty for a link anyone can open.
+	perm       storage.Permission // Access granted to a collection.
+}
+
+// unshareArgs holds the parsed arguments of /unshare.
+type unshareArgs struct {
+	collection string
+	user       string
+}
+
+// share offers a page or a collection to another user. With a recipient who
+// has started the bot the offer is sent to them right away, otherwise the
+// user gets a link to pass on.
+func (p *Processor) share(req *Request) error {
+	args := req.Args.(shareArgs)
+
+	if strings.EqualFold(args.to, req.Meta.UserName) {
+		return p.reply(req.Meta, msgShareSelf)
+	}
+
+	token, err := newToken(shareTokenSize)
+	if err != nil {
+		return err
+	}
+
+	share := &storage.Share{
+		Token:     token,
+		From:      req.Owner,
+		SharedBy:  req.Meta.UserName,
+		To:        args.to,
+		CreatedAt: time.Now(),
+	}
+
+	if args.collection != "" {
+		c, err := p.storage.Collection(req.Owner, req.Owner, args.collection)
+		if err != nil {
+			return p.collectionError(req, err, args.collection)
+		}
+
+		share.Collection = c.Name
+		share.Permission = args.perm
+	} else {
+		page, err := p.storage.Get(req.Owner, args.id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoPagesFound) {
+				return p.reply(req.Meta, msgPageNotFound)
+			}
+
+			return fmt.Errorf("failed to get page: %v", err)
+		}
+
+		share.URL = page.URL
+		share.Title = page.Title
+	}
+
+	err = p.storage.SaveShare(share)
+	if err != nil {
+		return fmt.Errorf("failed to save share: %v", err)
+	}
+
+	link := format.HTML.Escape(p.shareLink(token))
+
+	if args.to == "" {
+		return p.reply(req.Meta, fmt.Sprintf(msgShareLinkFmt, link))
+	}
+
+	chatID, err := p.storage.UserChat(args.to)
+	if err != nil {
+		if errors.Is(err, storage.ErrUnknownUser) {
+			return p.reply(req.Meta, fmt.Sprintf(msgShareUnknownFmt, format.HTML.Escape(args.to), link))
+		}
+
+		return fmt.Errorf("failed to get chat of recipient: %v", err)
+	}
+
+	err = p.sendShareOffer(chatID, share)
+	if err != nil {
+		return err
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgShareSentFmt, format.HTML.Escape(args.to)))
+}
+
+// openShare shows the offer behind a share link opened with /start <token>.
+func (p *Processor) openShare(req *Request, token string) error {
+	share, err := p.storage.Share(token)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoShare) {
+			return p.reply(req.Meta, msgShareGone)
+		}
+
+		return fmt.Errorf("failed to get share: %v", err)
+	}
+
+	if share.To != "" && !strings.EqualFold(share.To, req.Meta.UserName) {
+		return p.reply(req.Meta, msgShareNotYours)
+	}
+
+	return p.sendShareOffer(req.Meta.ChatID, share)
+}
+
+// sendShareOffer sends a share to a chat with buttons to accept or decline it.
+func (p *Processor) sendShareOffer(chatID int, share *storage.Share) error {
+	from := format.HTML.Bold("@" + share.SharedBy)
+
+	text := fmt.Sprintf(msgSharePageFmt, from, format.HTML.Link(shareTitle(share), share.URL))
+	if share.Collection != "" {
+		text = fmt.Sprintf(msgShareCollFmt, from, format.HTML.Bold(share.Collection), permissionNames[share.Permission])
+	}
+
+	err := p.client.SendMessage(chatID, text,
+		telegram.WithParseMode(telegram.ParseModeHTML),
+		telegram.WithInlineKeyboard([]telegram.InlineKeyboardButton{
+			{Text: msgShareAccept, CallbackData: acceptSharePrefix + share.Token},
+			{Text: msgShareDecline, CallbackData: declineSharePrefix + share.Token},
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to send share offer: %v", err)
+	}
+
+	return nil
+}
+
+// answerShare handles a press of the accept or decline button under a share
+// offer. The offer is replaced with the outcome, and direct shares are removed
+// once answered; links stay valid for everyone they were passed to.
+func (p *Processor) answerShare(req *Request) error {
+	token, accept := strings.CutPrefix(req.Cmd, acceptSharePrefix)
+	if !accept {
+		token = strings.TrimPrefix(req.Cmd, declineSharePrefix)
+	}
+
+	share, err := p.storage.Share(token)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoShare) {
+			return p.answer(req.Meta, msgShareGone)
+		}
+
+		return fmt.Errorf("failed to get share: %v", err)
+	}
+
+	if share.To != "" && !strings.EqualFold(share.To, req.Meta.UserName) {
+		return p.answer(req.Meta, msgShareNotYours)
+	}
+
+	text := msgShareDeclined
+	if accept {
+		text, err = p.acceptShare(req, share)
+		if err != nil {
+			return err
+		}
+	}
+
+	if share.To != "" {
+		err = p.storage.RemoveShare(share.Token)
+		if err != nil && !errors.Is(err, storage.ErrNoShare) {
+			return fmt.Errorf("failed to remove share: %v", err)
+		}
+	}
+
+	return p.answer(req.Meta, text)
+}
+
+// acceptShare saves a shared page to the owner's list or makes the owner
+// a member of a shared collection, and returns the message for the user.
+func (p *Processor) acceptShare(req *Request, share *storage.Share) (string, error) {
+	if share.From == req.Owner {
+		return msgShareOwn, nil
+	}
+
+	if share.Collection != "" {
+		err := p.storage.UpdateCollection(share.From, share.From, share.Collection, func(c *storage.Collection) {
+			if c.Members == nil {
+				c.Members = make(map[string]storage.Permission)
+			}
+			c.Members[req.Owner] = share.Permission
+		})
+		if err != nil {
+			if errors.Is(err, storage.ErrNoCollection) {
+				return msgShareGone, nil
+			}
+
+			return "", fmt.Errorf("failed to add collection member: %v", err)
+		}
+
+		ref := "@" + share.From + "/" + share.Collection
+		return fmt.Sprintf(msgShareJoinedFmt, format.HTML.Bold(ref), permissionNames[share.Permission],
+			format.HTML.Escape(quoteName(ref))), nil
+	}
+
+	page := &storage.Page{
+		URL:      share.URL,
+		UserName: req.Owner,
+		AddedBy:  share.SharedBy,
+		Title:    share.Title,
+	}
+
+	exists, err := p.storage.IsExists(page)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if page exists: %v", err)
+	}
+	if exists {
+		return msgAlreadyExists, nil
+	}
+
+	err = p.storage.Save(page)
+	if err != nil {
+		return "", fmt.Errorf("failed to save page: %v", err)
+	}
+
+	p.service.Enqueue(page)
+	p.service.PushUndo(req.Owner, storage.Action{Kind: storage.ActionSave, URL: page.URL})
+
+	return fmt.Sprintf(msgShareSavedFmt, format.HTML.Link(shareTitle(share), share.URL)), nil
+}
+
+// answer replaces the message with the pressed buttons with text
+// and dismisses the button's loading indicator.
+func (p *Processor) answer(meta Meta, text string) error {
+	err := p.client.AnswerCallbackQuery(meta.CallbackID, "")
+	if err != nil {
+		return fmt.Errorf("failed to answer callback: %v", err)
+	}
+
+	err = p.client.EditMessageText(meta.ChatID, meta.MessageID, text, telegram.WithParseMode(telegram.ParseModeHTML))
+	if err != nil {
+		return fmt.Errorf("failed to edit message: %v", err)
+	}
+
+	return nil
+}
+
+// unshare takes away a member's access to one of the owner's collections.
+func (p *Processor) unshare(req *Request) error {
+	args := req.Args.(unshareArgs)
+
+	found := false
+	err := p.storage.UpdateCollection(req.Owner, req.Owner, args.collection, func(c *storage.Collection) {
+		for member := range c.Members {
+			if strings.EqualFold(member, args.user) {
+				delete(c.Members, member)
+				found = true
+			}
+		}
+	})
+	if err != nil {
+		return p.collectionError(req, err, args.collection)
+	}
+
+	user, name := format.HTML.Escape(args.user), format.HTML.Bold(args.collection)
+	if !found {
+		return p.reply(req.Meta, fmt.Sprintf(msgNotMemberFmt, user, name))
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgUnsharedFmt, user, name))
+}
+
+// shareLink returns what a recipient opens to see a share: a t.me deep link
+// when the bot's name is known, the matching /start command otherwise.
+func (p *Processor) shareLink(token string) string {
+	if p.botName == "" {
+		return StartCmd + " " + token
+	}
+
+	return "https://t.me/" + p.botName + "?start=" + token
+}
+
+// shareTitle returns the title of a shared page, falling back to its URL.
+func shareTitle(share *storage.Share) string {
+	if share.Title != "" {
+		return share.Title
+	}
+
+	return share.URL
+}
+
+// quoteName puts a collection name in double quotes if it contains spaces,
+// so it can be pasted into a command.
+func quoteName(name string) string {
+	if strings.Contains(name, " ") {
+		return `"` + name + `"`
+	}
+
+	return name
+}
+
+// newToken returns a random hex token made of size random bytes.
+// Tokens only use characters allowed in t.me deep links and URL paths.
+func newToken(size int) (string, error) {
+	buf := make([]byte, size)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// parseShareArgs parses the arguments of /share:
+//
+//	/share <id> [@user]
+//	/share collection <name> [@user] [read|edit]
+//
+// Collections are shared read-only unless "edit" is given.
+func parseShareArgs(raw string) (any, error) {
+	fields := splitQuoted(raw)
+	usage := &UsageError{Msg: msgShareUsage}
+
+	if len(fields) == 0 {
+		return nil, usage
+	}
+
+	args := shareArgs{perm: storage.ReadOnly}
+
+	if !strings.EqualFold(fields[0], "collection") {
+		var ok bool
+		if args.id, ok = parseID(fields[0]); !ok || len(fields) > 2 {
+			return nil, usage
+		}
+		if len(fields) == 2 {
+			if args.to, ok = strings.CutPrefix(fields[1], "@"); !ok || args.to == "" {
+				return nil, usage
+			}
+		}
+
+		return args, nil
+	}
+
+	fields = fields[1:]
+	for len(fields) > 1 {
+		last := fields[len(fields)-1]
+		switch {
+		case strings.EqualFold(last, "read"):
+			args.perm = storage.ReadOnly
+		case strings.EqualFold(last, "edit"):
+			args.perm = storage.Collaborator
+		case strings.HasPrefix(last, "@") && args.to == "":
+			args.to = strings.TrimPrefix(last, "@")
+		default:
+			args.collection = strings.Join(fields, " ")
+			return args, nil
+		}
+		fields = fields[:len(fields)-1]
+	}
+
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "@") {
+		return nil, usage
+	}
+	args.collection = fields[0]
+
+	return args, nil
+}
+
+// parseUnshareArgs parses "/unshare <collection> @user".
+func parseUnshareArgs(raw string) (any, error) {
+	fields := splitQuoted(raw)
+	if len(fields) < 2 {
+		return nil, &UsageError{Msg: msgUnshareUsage}
+	}
+
+	user, ok := strings.CutPrefix(fields[len(fields)-1], "@")
+	if !ok || user == "" {
+		return nil, &UsageError{Msg: msgUnshareUsage}
+	}
+
+	return unshareArgs{collection: strings.Join(fields[:len(fields)-1], " "), user: user}, nil
+}
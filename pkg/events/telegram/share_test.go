@@ -0,0 +1,214 @@
+package telegram
+
+import (
+	"URLbot/pkg/events"
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// callbackRe extracts the callback data of the accept button from a reply markup.
+var callbackRe = regexp.MustCompile(`"callback_data":"(share:accept:[0-9a-f]+)"`)
+
+func TestProcessor_share(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	p.SetBotName("LinkBot")
+
+	alex := Meta{ChatID: 1, UserName: "alex"}
+	bob := Meta{ChatID: 2, UserName: "bob"}
+	kate := Meta{ChatID: 3, UserName: "kate"}
+
+	page := &storage.Page{URL: "https://example.com/go", UserName: "alex", Title: "Go"}
+	if err := s.Save(page); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	if err := s.Save(&storage.Page{URL: "https://example.com/rust", UserName: "bob"}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	// Bob has talked to the bot before, Kate has not.
+	if err := s.SetUserChat("bob", bob.ChatID); err != nil {
+		t.Fatalf("SetUserChat() failed: %v", err)
+	}
+
+	// press runs the last offer's accept button as the given user.
+	press := func(meta Meta) {
+		t.Helper()
+
+		if len(client.markups) == 0 {
+			t.Fatal("no share offer was sent")
+		}
+		m := callbackRe.FindStringSubmatch(client.markups[len(client.markups)-1])
+		if m == nil {
+			t.Fatalf("no accept button in %s", client.markups[len(client.markups)-1])
+		}
+
+		meta.CallbackID = "cb"
+		meta.MessageID = 10
+		err := p.Process(events.Event{Type: events.Callback, Text: m[1], Meta: meta})
+		if err != nil {
+			t.Fatalf("accepting the share failed: %v", err)
+		}
+	}
+
+	steps := []struct {
+		meta     Meta
+		text     string // Command to run, or "" to press the last accept button.
+		wantSend []string
+	}{
+		{meta: alex, text: "/share 1 @alex", wantSend: []string{msgShareSelf}},
+		{meta: alex, text: "/share 9 @bob", wantSend: []string{msgPageNotFound}},
+		{
+			meta: alex, text: "/share 1 @bob",
+			wantSend: []string{
+				fmt.Sprintf(msgSharePageFmt, "<b>@alex</b>", `<a href="https://example.com/go">Go</a>`),
+				fmt.Sprintf(msgShareSentFmt, "bob"),
+			},
+		},
+		{meta: kate, wantSend: []string{msgShareNotYours}},
+		{meta: bob, wantSend: []string{fmt.Sprintf(msgShareSavedFmt, `<a href="https://example.com/go">Go</a>`)}},
+		{meta: bob, wantSend: []string{msgShareGone}},
+		{meta: alex, text: "/share 1", wantSend: []string{"📤 Anyone who opens this link"}},
+		{meta: alex, text: "/start " + "0000", wantSend: []string{msgShareGone}},
+		{meta: alex, text: "/collection new Team", wantSend: []string{fmt.Sprintf(msgCollCreatedFmt, "<b>Team</b>")}},
+		{meta: alex, text: "/collection add Team 1", wantSend: []string{fmt.Sprintf(msgCollPosFmt, 1, 1, "<b>Team</b>")}},
+		{meta: alex, text: "/share collection Nope @bob", wantSend: []string{fmt.Sprintf(msgNoCollFmt, "<b>Nope</b>")}},
+		{
+			meta: alex, text: "/share collection team @kate",
+			wantSend: []string{fmt.Sprintf(msgShareUnknownFmt, "kate", "https://t.me/LinkBot?start=")},
+		},
+		{
+			meta: alex, text: "/share collection team @bob edit",
+			wantSend: []string{
+				fmt.Sprintf(msgShareCollFmt, "<b>@alex</b>", "<b>Team</b>", "can edit"),
+				fmt.Sprintf(msgShareSentFmt, "bob"),
+			},
+		},
+		{meta: bob, wantSend: []string{fmt.Sprintf(msgShareJoinedFmt, "<b>@alex/Team</b>", "can edit", "@alex/Team")}},
+		// Bob's page 2 is copied to Alex's list as page 4.
+		{meta: bob, text: "/collection add @alex/Team 2", wantSend: []string{fmt.Sprintf(msgCollPosFmt, 4, 2, "<b>@alex/Team</b>")}},
+		{meta: bob, text: "/collection", wantSend: []string{"<b>" + msgCollsHeader + "</b>\n\n• @alex/Team — 2 (can edit)\n"}},
+		{meta: alex, text: "/collection", wantSend: []string{"<b>" + msgCollsHeader + "</b>\n\n• Team — 2 👥 1\n"}},
+		{meta: bob, text: "/next @alex/Team", wantSend: []string{"<b>Go</b>"}},
+		{meta: bob, text: "/collection rename @alex/Team Mine", wantSend: []string{msgCollForbidden}},
+		{meta: bob, text: "/collection delete @alex/Team", wantSend: []string{msgCollForbidden}},
+		{meta: alex, text: "/unshare Team @bob", wantSend: []string{fmt.Sprintf(msgUnsharedFmt, "bob", "<b>Team</b>")}},
+		{meta: alex, text: "/unshare Team @bob", wantSend: []string{fmt.Sprintf(msgNotMemberFmt, "bob", "<b>Team</b>")}},
+		{meta: bob, text: "/next @alex/Team", wantSend: []string{fmt.Sprintf(msgNoCollFmt, "<b>@alex/Team</b>")}},
+	}
+
+	for _, step := range steps {
+		client.sent = nil
+
+		if step.text == "" {
+			press(step.meta)
+		} else if err := p.doCmd(step.text, step.meta); err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", step.text, err)
+		}
+
+		if len(client.sent) != len(step.wantSend) {
+			t.Fatalf("%q as %s sent %q, want %q", step.text, step.meta.UserName, client.sent, step.wantSend)
+		}
+		for i, want := range step.wantSend {
+			if !strings.HasPrefix(client.sent[i], want) {
+				t.Errorf("%q as %s sent %q, want %q", step.text, step.meta.UserName, client.sent[i], want)
+			}
+		}
+	}
+
+	shared, err := s.Get("bob", 3)
+	if err != nil || shared.URL != page.URL || shared.AddedBy != "alex" {
+		t.Errorf("Get() of the accepted page = %+v, %v; want the shared page added by alex", shared, err)
+	}
+
+	copied, err := s.Get("alex", 4)
+	if err != nil || copied.URL != "https://example.com/rust" || copied.AddedBy != "bob" {
+		t.Errorf("Get() of the page added by bob = %+v, %v; want a copy added by bob", copied, err)
+	}
+}
+
+func TestProcessor_shareLink(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+
+	if err := s.Save(&storage.Page{URL: "https://example.com/go", UserName: "alex"}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if err := s.Save(&storage.Page{URL: "https://example.com/go", UserName: "kate"}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	if err := p.doCmd("/share 1", Meta{ChatID: 1, UserName: "alex"}); err != nil {
+		t.Fatalf("doCmd() failed: %v", err)
+	}
+
+	start := strings.Index(client.sent[0], StartCmd)
+	if start < 0 {
+		t.Fatalf("share reply %q has no /start command", client.sent[0])
+	}
+	link := client.sent[0][start:]
+
+	for _, tt := range []struct {
+		meta Meta
+		want string
+	}{
+		{meta: Meta{ChatID: 2, UserName: "bob"}, want: "✅ Saved to your list"},
+		{meta: Meta{ChatID: 3, UserName: "kate"}, want: msgAlreadyExists},
+	} {
+		client.sent = nil
+
+		if err := p.doCmd(link, tt.meta); err != nil {
+			t.Fatalf("opening the link failed: %v", err)
+		}
+
+		m := callbackRe.FindStringSubmatch(client.markups[len(client.markups)-1])
+		meta := tt.meta
+		meta.CallbackID = "cb"
+		if err := p.Process(events.Event{Type: events.Callback, Text: m[1], Meta: meta}); err != nil {
+			t.Fatalf("accepting the share failed: %v", err)
+		}
+
+		if len(client.sent) != 2 || !strings.HasPrefix(client.sent[1], tt.want) {
+			t.Errorf("%s accepting the link got %q, want %q", tt.meta.UserName, client.sent, tt.want)
+		}
+	}
+}
+
+func TestParseShareArgs(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    shareArgs
+		wantErr bool
+	}{
+		{raw: "3", want: shareArgs{id: 3, perm: storage.ReadOnly}},
+		{raw: "#3 @bob", want: shareArgs{id: 3, to: "bob", perm: storage.ReadOnly}},
+		{raw: "collection Team", want: shareArgs{collection: "Team", perm: storage.ReadOnly}},
+		{raw: "collection Deep Work @bob edit", want: shareArgs{collection: "Deep Work", to: "bob", perm: storage.Collaborator}},
+		{raw: `collection "Deep Work" read @bob`, want: shareArgs{collection: "Deep Work", to: "bob", perm: storage.ReadOnly}},
+		{raw: "", wantErr: true},
+		{raw: "3 bob", wantErr: true},
+		{raw: "3 @bob now", wantErr: true},
+		{raw: "collection", wantErr: true},
+		{raw: "collection @bob", wantErr: true},
+		{raw: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseShareArgs(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseShareArgs(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseShareArgs(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"URLbot/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	snoozeHour = 9                        // Local hour a page snoozed until a date is brought back.
+	maxSnooze  = 2 * 365 * 24 * time.Hour // Longest allowed snooze.
+)
+
+// snoozeDelayRe matches short delays such as "30m", "12h", "3d" or "2w".
+var snoozeDelayRe = regexp.MustCompile(`^(\d+)([mhdw])$`)
+
+// snoozeArgs holds the parsed arguments of /snooze.
+type snoozeArgs struct {
+	id    int
+	off   bool          // Cancel the snooze.
+	delay time.Duration // Snooze for this long, if set.
+	date  time.Time     // Otherwise snooze until this date, in UTC at midnight.
+}
+
+// snooze hides a page from random picks and digests until the requested time.
+// Dates are interpreted in the time zone of the owner's digest in this chat, or in UTC.
+func (p *Processor) snooze(req *Request) error {
+	args := req.Args.(snoozeArgs)
+
+	page, err := p.storage.Get(req.Owner, args.id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return p.reply(req.Meta, msgPageNotFound)
+		}
+
+		return fmt.Errorf("failed to get page: %v", err)
+	}
+
+	loc, err := p.location(req.Meta.ChatID, req.Owner)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	var until time.Time
+	switch {
+	case args.off:
+	case args.delay > 0:
+		until = now.Add(args.delay)
+	default:
+		y, m, day := args.date.Date()
+		until = time.Date(y, m, day, snoozeHour, 0, 0, 0, loc)
+	}
+
+	if !args.off && (!until.After(now) || until.Sub(now) > maxSnooze) {
+		return p.reply(req.Meta, msgSnoozeRange)
+	}
+
+	err = p.storage.Update(page.UserName, page.URL, func(stored *storage.Page) {
+		stored.SnoozedUntil = until
+		stored.SnoozeChatID = req.Meta.ChatID
+	})
+	if err != nil {
+		return fmt.Errorf("failed to snooze page: %v", err)
+	}
+
+	if args.off {
+		return p.reply(req.Meta, msgSnoozeOff)
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgSnoozedFmt, until.In(loc).Format("Mon, 02 Jan 2006 15:04 MST")))
+}
+
+// SendDueReminders brings back every page whose snooze has ended by now and
+// reminds its owner about it. It is meant to be run by the scheduler. The snooze
+// is cleared before the reminder is sent, so a reminder is never sent twice.
+func (p *Processor) SendDueReminders(ctx context.Context, now time.Time) error {
+	pages, err := p.storage.DueSnoozes(now)
+	if err != nil {
+		return fmt.Errorf("failed to get due snoozes: %v", err)
+	}
+
+	for _, page := range pages {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		claimed := false
+		err := p.storage.Update(page.UserName, page.URL, func(stored *storage.Page) {
+			if stored.SnoozedUntil.Equal(page.SnoozedUntil) {
+				stored.SnoozedUntil, stored.SnoozeChatID = time.Time{}, 0
+				claimed = true
+			}
+		})
+		if err != nil {
+			if !errors.Is(err, storage.ErrNoPagesFound) {
+				slog.Error("Failed to clear snooze", "url", page.URL, "owner", page.UserName, "err", err)
+			}
+			continue
+		}
+
+		if !claimed || page.SnoozeChatID == 0 {
+			continue
+		}
+
+		err = p.client.SendMessage(page.SnoozeChatID, msgReminder+formatPage(page),
+			telegram.WithParseMode(telegram.ParseModeHTML))
+		if err != nil {
+			slog.Error("Failed to send reminder", "chat_id", page.SnoozeChatID, "url", page.URL, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// parseSnoozeArgs parses the arguments of /snooze: a page ID followed by
+// a delay ("3d", "2w", "1h30m"), a date ("2026-12-01") or "off".
+func parseSnoozeArgs(raw string) (any, error) {
+	fields := strings.Fields(raw)
+	usage := &UsageError{Msg: msgSnoozeUsage}
+
+	if len(fields) != 2 {
+		return nil, usage
+	}
+
+	id, ok := parseID(fields[0])
+	if !ok {
+		return nil, usage
+	}
+
+	args := snoozeArgs{id: id}
+	when := strings.ToLower(fields[1])
+
+	if when == "off" {
+		args.off = true
+		return args, nil
+	}
+
+	if m := snoozeDelayRe.FindStringSubmatch(when); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n <= 0 || n > 1000 {
+			return nil, usage
+		}
+
+		unit := map[string]time.Duration{
+			"m": time.Minute,
+			"h": time.Hour,
+			"d": 24 * time.Hour,
+			"w": 7 * 24 * time.Hour,
+		}[m[2]]
+
+		args.delay = time.Duration(n) * unit
+		return args, nil
+	}
+
+	if d, err := time.ParseDuration(when); err == nil {
+		if d <= 0 {
+			return nil, usage
+		}
+
+		args.delay = d
+		return args, nil
+	}
+
+	date, err := time.Parse(time.DateOnly, when)
+	if err != nil {
+		return nil, usage
+	}
+
+	args.date = date
+	return args, nil
+}
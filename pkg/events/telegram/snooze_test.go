@@ -0,0 +1,135 @@
+package telegram
+
+import (
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSnoozeArgs(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    snoozeArgs
+		wantErr bool
+	}{
+		{raw: "3 3d", want: snoozeArgs{id: 3, delay: 3 * 24 * time.Hour}},
+		{raw: "#3 2W", want: snoozeArgs{id: 3, delay: 14 * 24 * time.Hour}},
+		{raw: "3 45m", want: snoozeArgs{id: 3, delay: 45 * time.Minute}},
+		{raw: "3 1h30m", want: snoozeArgs{id: 3, delay: 90 * time.Minute}},
+		{raw: "3 2026-12-01", want: snoozeArgs{id: 3, date: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)}},
+		{raw: "3 off", want: snoozeArgs{id: 3, off: true}},
+		{raw: "", wantErr: true},
+		{raw: "3", wantErr: true},
+		{raw: "abc 3d", wantErr: true},
+		{raw: "3 0d", wantErr: true},
+		{raw: "3 -1h", wantErr: true},
+		{raw: "3 soon", wantErr: true},
+		{raw: "3 2026-13-01", wantErr: true},
+		{raw: "3 3d extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseSnoozeArgs(tt.raw)
+
+			if tt.wantErr {
+				var usageErr *UsageError
+				if !errors.As(err, &usageErr) {
+					t.Fatalf("parseSnoozeArgs(%q) error = %v, want a usage error", tt.raw, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseSnoozeArgs(%q) failed: %v", tt.raw, err)
+			}
+
+			if got.(snoozeArgs) != tt.want {
+				t.Errorf("parseSnoozeArgs(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessor_snooze(t *testing.T) {
+	s := memory.New()
+
+	page := &storage.Page{URL: "https://example.com", UserName: "alex", Title: "Later"}
+	err := s.Save(page)
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 42, UserName: "alex"}
+
+	steps := []struct {
+		text     string
+		wantSend string
+	}{
+		{text: "/snooze 100 3d", wantSend: msgPageNotFound},
+		{text: "/snooze 1 2020-01-01", wantSend: msgSnoozeRange},
+		{text: "/snooze 1 3d", wantSend: "💤 Snoozed until"},
+		{text: "/random", wantSend: msgNoSavedPages},
+		{text: "/list", wantSend: "<b>Your saved articles:</b>\n\n1. 💤"},
+	}
+
+	for _, step := range steps {
+		client.sent = nil
+
+		err := p.doCmd(step.text, meta)
+		if err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", step.text, err)
+		}
+
+		if len(client.sent) != 1 || !strings.HasPrefix(client.sent[0], step.wantSend) {
+			t.Errorf("doCmd(%q) sent %q, want %q", step.text, client.sent, step.wantSend)
+		}
+	}
+
+	stored, err := s.Get("alex", page.ID)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+
+	if stored.SnoozeChatID != 42 || !stored.Snoozed(time.Now().Add(71*time.Hour)) {
+		t.Fatalf("page snoozed until %v in chat %d, want in 3 days in chat 42", stored.SnoozedUntil, stored.SnoozeChatID)
+	}
+
+	// Nothing is due before the snooze ends.
+	client.sent = nil
+
+	err = p.SendDueReminders(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("SendDueReminders() failed: %v", err)
+	}
+	if len(client.sent) != 0 {
+		t.Fatalf("reminder sent too early: %q", client.sent)
+	}
+
+	// Once it has ended, exactly one reminder is sent and the page is back.
+	later := stored.SnoozedUntil.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		err = p.SendDueReminders(context.Background(), later)
+		if err != nil {
+			t.Fatalf("SendDueReminders() failed: %v", err)
+		}
+	}
+
+	if len(client.sent) != 1 || !strings.HasPrefix(client.sent[0], msgReminder+"<b>Later</b>") {
+		t.Fatalf("sent %q, want one reminder", client.sent)
+	}
+
+	stored, err = s.Get("alex", page.ID)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if !stored.SnoozedUntil.IsZero() {
+		t.Errorf("snooze was not cleared: %v", stored.SnoozedUntil)
+	}
+}
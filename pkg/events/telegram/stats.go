@@ -0,0 +1,100 @@
+package telegram
+
+import (
+	"URLbot/pkg/format"
+	"URLbot/pkg/storage"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// statsTopDomains is the number of most saved sites shown by /stats.
+const statsTopDomains = 5
+
+// stats sends the reading statistics of the owner. Weeks start on Monday and
+// days are counted in the time zone of the owner's digest in this chat, or in UTC.
+func (p *Processor) stats(req *Request) error {
+	loc, err := p.location(req.Meta.ChatID, req.Owner)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	st, err := p.storage.Stats(req.Owner, storage.StatsQuery{
+		Week:       weekStart(today),
+		Month:      time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc),
+		TopDomains: statsTopDomains,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %v", err)
+	}
+
+	if st.Total == 0 {
+		return p.reply(req.Meta, msgNoSavedPages)
+	}
+
+	days, err := p.storage.ReadDays(req.Owner, loc)
+	if err != nil {
+		return fmt.Errorf("failed to get read days: %v", err)
+	}
+
+	return p.reply(req.Meta, formatStats(st, storage.Streak(days, today)))
+}
+
+// formatStats renders the statistics and the reading streak for the user.
+func formatStats(st *storage.Stats, streak int) string {
+	var builder strings.Builder
+	builder.WriteString(format.HTML.Bold(msgStatsHeader) + "\n\n")
+
+	fmt.Fprintf(&builder, msgStatsTotalFmt+"\n", st.Total, st.Read, st.Unread)
+	fmt.Fprintf(&builder, msgStatsWeekFmt+"\n", st.SavedThisWeek, st.ReadThisWeek)
+	fmt.Fprintf(&builder, msgStatsMonthFmt+"\n", st.SavedThisMonth, st.ReadThisMonth)
+
+	if st.AvgTimeToRead > 0 {
+		fmt.Fprintf(&builder, msgStatsAvgFmt+"\n", formatSpan(st.AvgTimeToRead))
+	}
+
+	if streak > 0 {
+		fmt.Fprintf(&builder, msgStatsStreakFmt+"\n", formatDays(streak))
+	} else {
+		builder.WriteString(msgStatsNoStreak + "\n")
+	}
+
+	if len(st.TopDomains) > 0 {
+		builder.WriteString("\n" + msgStatsTopDomains + "\n")
+		for i, d := range st.TopDomains {
+			fmt.Fprintf(&builder, "%d. %s — %d\n", i+1, format.HTML.Escape(d.Domain), d.Count)
+		}
+	}
+
+	return builder.String()
+}
+
+// weekStart returns the Monday of the week that contains day.
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// formatSpan renders a duration roughly, in minutes, hours or days.
+func formatSpan(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%d min", max(int(d.Minutes()), 1))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d h", int(d.Hours()))
+	default:
+		return formatDays(int(d.Hours() / 24))
+	}
+}
+
+// formatDays renders a number of days.
+func formatDays(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+
+	return fmt.Sprintf("%d days", n)
+}
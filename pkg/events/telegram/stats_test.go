@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessor_stats(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	err := p.doCmd("/stats", meta)
+	if err != nil {
+		t.Fatalf("doCmd() failed: %v", err)
+	}
+	if len(client.sent) != 1 || client.sent[0] != msgNoSavedPages {
+		t.Errorf("/stats with no pages sent %q, want %q", client.sent, msgNoSavedPages)
+	}
+
+	now := time.Now()
+	pages := []*storage.Page{
+		{URL: "https://example.com/a", CreatedAt: now.Add(-2 * time.Hour), Read: true, ReadAt: now},
+		{URL: "https://www.example.com/b", CreatedAt: now.Add(-time.Hour)},
+		{URL: "https://other.org/<c>", CreatedAt: now.Add(-time.Hour)},
+	}
+	for _, page := range pages {
+		page.UserName = "alex"
+		err := s.Save(page)
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	client.sent = nil
+	err = p.doCmd("/stats", meta)
+	if err != nil {
+		t.Fatalf("doCmd() failed: %v", err)
+	}
+	if len(client.sent) != 1 {
+		t.Fatalf("/stats sent %d messages, want 1", len(client.sent))
+	}
+
+	for _, want := range []string{
+		fmt.Sprintf(msgStatsTotalFmt, 3, 1, 2),
+		fmt.Sprintf(msgStatsAvgFmt, "2 h"),
+		fmt.Sprintf(msgStatsStreakFmt, "1 day"),
+		"1. example.com — 2\n2. other.org — 1",
+	} {
+		if !strings.Contains(client.sent[0], want) {
+			t.Errorf("/stats sent %q, want it to contain %q", client.sent[0], want)
+		}
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	monday := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+
+	for d := 0; d < 7; d++ {
+		day := monday.AddDate(0, 0, d)
+		if got := weekStart(day); !got.Equal(monday) {
+			t.Errorf("weekStart(%s) = %s, want %s", day.Weekday(), got, monday)
+		}
+	}
+}
+
+func TestFormatSpan(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 10 * time.Second, want: "1 min"},
+		{d: 45 * time.Minute, want: "45 min"},
+		{d: 30 * time.Hour, want: "30 h"},
+		{d: 80 * time.Hour, want: "3 days"},
+		{d: 49 * time.Hour, want: "2 days"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSpan(tt.d); got != tt.want {
+			t.Errorf("formatSpan(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
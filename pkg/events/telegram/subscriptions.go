@@ -0,0 +1,218 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"URLbot/pkg/feedpoll"
+	"URLbot/pkg/format"
+	"URLbot/pkg/service"
+	"URLbot/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxNotifyItems is the largest number of new items listed in a single
+// subscription notification. The rest are only counted.
+const maxNotifyItems = 10
+
+// Subscriber checks a feed and stores a new subscription to it.
+// feedpoll.Poller satisfies it.
+type Subscriber interface {
+	Subscribe(ctx context.Context, sub *storage.Subscription) error
+}
+
+// SetSubscriber sets what follows subscribed feeds, enabling /subscribe.
+func (p *Processor) SetSubscriber(s Subscriber) {
+	p.subscriber = s
+}
+
+// subscribeArgs holds the parsed arguments of /subscribe.
+type subscribeArgs struct {
+	feedURL string
+	tag     string
+}
+
+// subscribe follows a feed, so its new items are saved to the owner's list
+// and announced in this chat.
+func (p *Processor) subscribe(req *Request) error {
+	if p.subscriber == nil {
+		return p.reply(req.Meta, msgSubsDisabled)
+	}
+
+	args := req.Args.(subscribeArgs)
+
+	sub := &storage.Subscription{
+		Owner:   req.Owner,
+		ChatID:  req.Meta.ChatID,
+		FeedURL: args.feedURL,
+		Tag:     args.tag,
+	}
+
+	err := p.subscriber.Subscribe(context.Background(), sub)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSubscriptionExists):
+			return p.reply(req.Meta, msgSubExists)
+		case errors.Is(err, feedpoll.ErrNotAFeed):
+			return p.reply(req.Meta, msgSubNotAFeed)
+		default:
+			return p.reply(req.Meta, fmt.Sprintf(msgSubFailedFmt, format.HTML.Escape(err.Error())))
+		}
+	}
+
+	text := fmt.Sprintf(msgSubscribedFmt, format.HTML.Link(subTitle(sub), sub.FeedURL))
+	if sub.Tag != "" {
+		text += fmt.Sprintf(msgSubTagFmt, format.HTML.Escape(sub.Tag))
+	}
+
+	return p.reply(req.Meta, text, telegram.WithoutPreview())
+}
+
+// sendSubscriptions lists the owner's subscriptions, numbered for /unsubscribe.
+func (p *Processor) sendSubscriptions(req *Request) error {
+	subs, err := p.storage.Subscriptions(req.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions: %v", err)
+	}
+
+	if len(subs) == 0 {
+		return p.reply(req.Meta, msgNoSubs)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(msgSubsHeader + "\n\n")
+
+	for i, sub := range subs {
+		fmt.Fprintf(&builder, "%d. %s", i+1, format.HTML.Link(subTitle(sub), sub.FeedURL))
+		if sub.Tag != "" {
+			builder.WriteString(" " + format.HTML.Escape("#"+sub.Tag))
+		}
+		if sub.LastError != "" {
+			builder.WriteString(" " + format.HTML.Italic("⚠️ "+sub.LastError))
+		}
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("\n" + msgSubsFooter)
+
+	return p.reply(req.Meta, builder.String(), telegram.WithoutPreview())
+}
+
+// unsubscribeArgs holds the parsed argument of /unsubscribe: either the number
+// of a subscription in /subscriptions or a feed URL.
+type unsubscribeArgs struct {
+	n       int
+	feedURL string
+}
+
+// unsubscribe stops following a feed, given by its URL or its number in /subscriptions.
+func (p *Processor) unsubscribe(req *Request) error {
+	args := req.Args.(unsubscribeArgs)
+	feedURL := args.feedURL
+
+	if args.n > 0 {
+		subs, err := p.storage.Subscriptions(req.Owner)
+		if err != nil {
+			return fmt.Errorf("failed to get subscriptions: %v", err)
+		}
+
+		if args.n > len(subs) {
+			return p.reply(req.Meta, msgNoSub)
+		}
+
+		feedURL = subs[args.n-1].FeedURL
+	}
+
+	err := p.storage.Unsubscribe(req.Owner, feedURL)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoSubscription) {
+			return p.reply(req.Meta, msgNoSub)
+		}
+
+		return fmt.Errorf("failed to unsubscribe: %v", err)
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgUnsubscribedFmt, format.HTML.Escape(shortURL(feedURL))))
+}
+
+// NotifyNewItems tells the owner about pages saved from their subscriptions
+// in a single message. It satisfies feedpoll.Notifier.
+func (p *Processor) NotifyNewItems(chatID int, owner string, pages []*storage.Page) error {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, msgSubNewFmt+"\n\n", len(pages))
+
+	for i, page := range pages {
+		if i == maxNotifyItems {
+			fmt.Fprintf(&builder, msgSubMoreFmt+"\n", len(pages)-maxNotifyItems)
+			break
+		}
+
+		builder.WriteString(pickLine(page))
+	}
+
+	err := p.client.SendMessage(chatID, builder.String(),
+		telegram.WithParseMode(telegram.ParseModeHTML), telegram.WithoutPreview())
+	if err != nil {
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+
+	return nil
+}
+
+// subTitle returns the title of a subscribed feed, falling back to its URL.
+func subTitle(sub *storage.Subscription) string {
+	if sub.Title != "" {
+		return sub.Title
+	}
+
+	return shortURL(sub.FeedURL)
+}
+
+// parseSubscribeArgs parses the arguments of /subscribe: a feed URL
+// and an optional tag for the saved items. A leading "#" of the tag is ignored.
+func parseSubscribeArgs(raw string) (any, error) {
+	fields := strings.Fields(raw)
+	usage := &UsageError{Msg: msgSubscribeUsage}
+
+	if len(fields) == 0 || len(fields) > 2 || !isFeedURL(fields[0]) {
+		return nil, usage
+	}
+
+	args := subscribeArgs{feedURL: fields[0]}
+	if len(fields) == 2 {
+		args.tag = strings.ToLower(strings.TrimPrefix(fields[1], "#"))
+		if args.tag == "" {
+			return nil, usage
+		}
+	}
+
+	return args, nil
+}
+
+// parseUnsubscribeArgs parses the argument of /unsubscribe: a feed URL
+// or the number of a subscription in /subscriptions.
+func parseUnsubscribeArgs(raw string) (any, error) {
+	fields := strings.Fields(raw)
+	if len(fields) != 1 {
+		return nil, &UsageError{Msg: msgUnsubscribeUsage}
+	}
+
+	if n, ok := parseID(fields[0]); ok {
+		return unsubscribeArgs{n: n}, nil
+	}
+
+	if !service.IsURL(fields[0]) {
+		return nil, &UsageError{Msg: msgUnsubscribeUsage}
+	}
+
+	return unsubscribeArgs{feedURL: fields[0]}, nil
+}
+
+// isFeedURL reports whether text is an http or https URL the poller can fetch.
+func isFeedURL(text string) bool {
+	u, err := url.Parse(text)
+
+	return err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https")
+}
@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"URLbot/pkg/feedpoll"
+	"URLbot/pkg/safehttp"
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessor_subscriptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed":
+			_, _ = w.Write([]byte(`<rss version="2.0"><channel><title>Go &amp; more</title><item><link>https://go.dev/blog/1</link></item></channel></rss>`))
+		case "/page":
+			_, _ = w.Write([]byte(`<html><body>not a feed</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	run := func(text string) string {
+		t.Helper()
+
+		client.sent = nil
+		if err := p.doCmd(text, meta); err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", text, err)
+		}
+		if len(client.sent) != 1 {
+			t.Fatalf("doCmd(%q) sent %q, want a single message", text, client.sent)
+		}
+
+		return client.sent[0]
+	}
+
+	feedURL := server.URL + "/feed"
+
+	if got := run("/subscribe " + feedURL); got != msgSubsDisabled {
+		t.Errorf("/subscribe without a poller sent %q, want %q", got, msgSubsDisabled)
+	}
+
+	fetcher := feedpoll.NewFetcher(time.Second, 1<<20)
+	fetcher.SetTransport(safehttp.NewTransport(netip.MustParsePrefix("127.0.0.0/8")))
+	p.SetSubscriber(feedpoll.NewPoller(fetcher, s, time.Hour))
+
+	steps := []struct {
+		text string
+		want string // Expected substring of the reply.
+	}{
+		{text: "/subscribe " + feedURL + " #News", want: "Go &amp; more</a>. New articles will be saved to your list with #news"},
+		{text: "/subscribe " + feedURL, want: msgSubExists},
+		{text: "/subscribe " + server.URL + "/page", want: msgSubNotAFeed},
+		{text: "/subscribe " + server.URL + "/missing", want: "Couldn't read the feed: unexpected status 404"},
+		{text: "/subscriptions", want: "1. <a href=\"" + feedURL + "\">Go &amp; more</a> #news\n"},
+		{text: "/unsubscribe 2", want: msgNoSub},
+		{text: "/unsubscribe 1", want: "Unsubscribed from 127.0.0.1"},
+		{text: "/unsubscribe " + feedURL, want: msgNoSub},
+		{text: "/subscriptions", want: msgNoSubs},
+	}
+
+	for _, step := range steps {
+		if got := run(step.text); !strings.Contains(got, step.want) {
+			t.Errorf("%s sent %q, want it to contain %q", step.text, got, step.want)
+		}
+	}
+
+	pages, err := s.List("alex")
+	if err == nil {
+		t.Errorf("List() after subscribing = %d pages, want the items already in the feed skipped", len(pages))
+	}
+}
+
+func TestProcessor_NotifyNewItems(t *testing.T) {
+	client := &mockClient{}
+	p := New(client, memory.New())
+
+	var pages []*storage.Page
+	for i := 1; i <= maxNotifyItems+2; i++ {
+		pages = append(pages, &storage.Page{ID: i, URL: fmt.Sprintf("https://example.com/%d", i), Title: fmt.Sprintf("Post %d", i)})
+	}
+
+	if err := p.NotifyNewItems(7, "alex", pages); err != nil {
+		t.Fatalf("NotifyNewItems() failed: %v", err)
+	}
+
+	if len(client.sent) != 1 {
+		t.Fatalf("NotifyNewItems() sent %q, want a single message", client.sent)
+	}
+
+	got := client.sent[0]
+	for _, want := range []string{fmt.Sprintf(msgSubNewFmt, 12), "10. <a href=\"https://example.com/10\">Post 10</a>", fmt.Sprintf(msgSubMoreFmt, 2)} {
+		if !strings.Contains(got, want) {
+			t.Errorf("NotifyNewItems() sent %q, want it to contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "Post 11") {
+		t.Errorf("NotifyNewItems() sent %q, want at most %d items listed", got, maxNotifyItems)
+	}
+}
+
+func TestParseSubscribeArgs(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    any
+		wantErr bool
+	}{
+		{raw: "https://go.dev/blog/feed.atom", want: subscribeArgs{feedURL: "https://go.dev/blog/feed.atom"}},
+		{raw: "https://go.dev/blog/feed.atom #Go", want: subscribeArgs{feedURL: "https://go.dev/blog/feed.atom", tag: "go"}},
+		{raw: "", wantErr: true},
+		{raw: "ftp://go.dev/feed", wantErr: true},
+		{raw: "https://go.dev/feed #", wantErr: true},
+		{raw: "https://go.dev/feed go news", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseSubscribeArgs(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSubscribeArgs(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSubscribeArgs(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package telegram
 
 import (
+	"URLbot/pkg/blob"
 	"URLbot/pkg/clients/telegram"
 	"URLbot/pkg/events"
+	"URLbot/pkg/service"
 	"URLbot/pkg/storage"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
@@ -20,26 +25,119 @@ type Processor struct {
 	client  Client
 	offset  int
 	storage storage.Storage
+	botName string
+	router  *Router
+	service *service.Service
+	blobs   blob.Store
+
+	// trashRetention is how long removed pages stay in the trash.
+	trashRetention time.Duration
+	// feedURL is the public address of the feed server, empty if feeds are off.
+	feedURL string
+	// subscriber follows feeds for /subscribe, nil if subscriptions are off.
+	subscriber Subscriber
+	// apiURL is the public address of the HTTP API, empty if the API is off.
+	apiURL string
+
+	mu    sync.Mutex
+	saved *savedLinks
+	chats map[string]int // Private chats of users already recorded in the storage.
 }
 
 // Meta contains metadata extracted from an event, such as chat ID and username.
+// Forwarded is set for messages forwarded from another chat or user, Channel
+// for posts made in a channel and Group for messages sent in a group chat.
+// FileID refers to the document attached to the message, if any.
+// CallbackID is set for presses of inline keyboard buttons, whose
+// MessageID is the message with the keyboard.
 type Meta struct {
-	ChatID   int
-	UserName string
+	ChatID    int
+	UserID    int
+	UserName  string
+	MessageID int
+	Forwarded bool
+	Channel   bool
+	Group     bool
+	FileID    string
+	FileSize  int64
+
+	CallbackID string
+}
+
+// messageKey identifies a single Telegram message across all chats.
+type messageKey struct {
+	chatID    int
+	messageID int
 }
 
 // Client abstracts Telegram API operations used by the bot.
 type Client interface {
 	GetUpdates(offset, limit int) ([]telegram.Update, error)
-	SendMessage(chatID int, text string) error
+	SendMessage(chatID int, text string, opts ...telegram.SendOption) error
+	SendDocument(chatID int, fileName string, data []byte, caption string) error
+	GetFile(fileID string) (*telegram.File, error)
+	DownloadFile(filePath string, maxSize int64) ([]byte, error)
+	EditMessageText(chatID, messageID int, text string, opts ...telegram.SendOption) error
+	AnswerCallbackQuery(queryID, text string) error
+	GetChatMember(chatID, userID int) (*telegram.ChatMember, error)
 }
 
 // New creates a new Processor with the given Telegram client and storage.
+// All supported commands are registered, with panic recovery and logging middleware.
 func New(client Client, storage storage.Storage) *Processor {
-	return &Processor{
+	p := &Processor{
 		client:  client,
 		storage: storage,
+		router:  NewRouter(),
+		service: service.New(storage),
+		saved:   newSavedLinks(maxSavedMessages),
+		chats:   make(map[string]int),
+
+		trashRetention: defaultTrashRetention,
 	}
+
+	p.router.Use(Recover(), Logging())
+	p.registerCommands()
+
+	return p
+}
+
+// Use appends middleware, such as AllowUsers or RateLimit, to the command chain.
+func (p *Processor) Use(mw ...Middleware) {
+	p.router.Use(mw...)
+}
+
+// Commands returns the commands supported by the bot.
+func (p *Processor) Commands() []Command {
+	return p.router.Commands()
+}
+
+// SetBotName sets the bot's own username. It is used to tell commands
+// addressed to this bot ("/list@name") from commands meant for other bots.
+func (p *Processor) SetBotName(name string) {
+	p.botName = strings.TrimPrefix(name, "@")
+}
+
+// SetService sets the service that performs reading list operations, so the
+// bot shares it, and its settings, with the HTTP API. By default the processor
+// uses a service of its own over the same storage.
+func (p *Processor) SetService(s *service.Service) {
+	p.service = s
+}
+
+// SetSnapshots sets the blob store that keeps page snapshots, enabling /snapshot.
+func (p *Processor) SetSnapshots(store blob.Store) {
+	p.blobs = store
+}
+
+// SetTrashRetention sets how long removed pages stay in the trash before they are purged.
+func (p *Processor) SetTrashRetention(d time.Duration) {
+	p.trashRetention = d
+}
+
+// SetFeedURL sets the public address of the feed server, enabling /feed.
+func (p *Processor) SetFeedURL(baseURL string) {
+	p.feedURL = baseURL
 }
 
 // Fetch retrieves a batch of updates from Telegram, converts them to Event format,
@@ -67,24 +165,42 @@ func (p *Processor) Fetch(limit int) ([]events.Event, error) {
 }
 
 // Process handles a single event by delegating to the appropriate handler
-// based on the event type. Currently supports only Message events.
+// based on the event type. Unknown events are skipped and are not reported as errors.
 func (p *Processor) Process(event events.Event) error {
 	switch event.Type {
 	case events.Message:
 		return p.processMessage(event)
+	case events.EditedMessage:
+		return p.processEdit(event)
+	case events.ChannelPost:
+		return p.processChannelPost(event)
+	case events.Callback:
+		return p.processCallback(event)
+	case events.Unknown:
+		slog.Debug("Process: skipping unsupported update")
+		return nil
 	default:
 		return ErrUnknownEventType
 	}
 }
 
 // processMessage extracts metadata from the event and processes the message command.
+// Forwarded messages never run commands, only the links they contain are saved.
 func (p *Processor) processMessage(event events.Event) error {
 	meta, err := meta(event)
 	if err != nil {
 		return fmt.Errorf("failed to procces message: %v", err)
 	}
 
-	err = p.doCmd(event.Text, meta.UserName, meta.ChatID)
+	if !meta.Group {
+		p.rememberChat(meta)
+	}
+
+	if meta.Forwarded {
+		err = p.saveForwarded(event.Text, meta)
+	} else {
+		err = p.doCmd(strings.TrimSpace(event.Text), meta)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to procces message: %v", err)
 	}
@@ -92,6 +208,224 @@ func (p *Processor) processMessage(event events.Event) error {
 	return nil
 }
 
+// processCallback handles a press of an inline keyboard button.
+// Buttons of unknown kinds, such as those of older bot versions, are only acknowledged.
+func (p *Processor) processCallback(event events.Event) error {
+	meta, err := meta(event)
+	if err != nil {
+		return fmt.Errorf("failed to procces callback: %v", err)
+	}
+
+	if !strings.HasPrefix(event.Text, acceptSharePrefix) && !strings.HasPrefix(event.Text, declineSharePrefix) {
+		err = p.client.AnswerCallbackQuery(meta.CallbackID, "")
+		if err != nil {
+			return fmt.Errorf("failed to procces callback: %v", err)
+		}
+		return nil
+	}
+
+	owner, err := p.owner(meta)
+	if err != nil {
+		return fmt.Errorf("failed to procces callback: %v", err)
+	}
+
+	req := &Request{
+		Cmd:   event.Text,
+		Owner: owner,
+		Meta:  meta,
+	}
+
+	err = p.router.Handle(req, p.answerShare)
+	if err != nil {
+		return fmt.Errorf("failed to procces callback: %v", err)
+	}
+
+	return nil
+}
+
+// processChannelPost silently saves links posted to a channel where the bot is an admin.
+// Pages are owned by the channel itself, and commands posted to the channel are ignored.
+func (p *Processor) processChannelPost(event events.Event) error {
+	meta, err := meta(event)
+	if err != nil {
+		return fmt.Errorf("failed to procces channel post: %v", err)
+	}
+
+	pageURL := findURL(event.Text)
+	if pageURL == "" {
+		return nil
+	}
+
+	req := &Request{
+		Cmd:   pageURL,
+		Owner: meta.UserName,
+		Meta:  meta,
+	}
+
+	err = p.router.Handle(req, p.saveChannelPost)
+	if err != nil {
+		return fmt.Errorf("failed to procces channel post: %v", err)
+	}
+
+	return nil
+}
+
+// saveChannelPost saves the link of a channel post. Links the channel has
+// already saved are skipped without a reply, like every other channel post.
+func (p *Processor) saveChannelPost(req *Request) error {
+	_, err := p.service.Add(req.Owner, "", req.Cmd, nil)
+	if err != nil {
+		if errors.Is(err, storage.ErrPageExists) {
+			return nil
+		}
+
+		return err
+	}
+
+	p.remember(req.Meta, req.Cmd)
+
+	return nil
+}
+
+// processEdit handles an edited message or channel post. If the original message
+// saved a link, the stored page is replaced with the link from the edited text.
+// Edits of any other message, such as a command, are ignored.
+func (p *Processor) processEdit(event events.Event) error {
+	meta, err := meta(event)
+	if err != nil {
+		return fmt.Errorf("failed to procces edited message: %v", err)
+	}
+
+	oldURL := p.recall(meta)
+	if oldURL == "" {
+		return nil
+	}
+
+	newURL := findURL(event.Text)
+	if newURL == "" || newURL == oldURL {
+		return nil
+	}
+
+	owner, err := p.owner(meta)
+	if err != nil {
+		return fmt.Errorf("failed to procces edited message: %v", err)
+	}
+
+	req := &Request{
+		Cmd:   newURL,
+		Args:  oldURL,
+		Owner: owner,
+		Meta:  meta,
+	}
+
+	err = p.router.Handle(req, p.replaceEdited)
+	if err != nil {
+		return fmt.Errorf("failed to procces edited message: %v", err)
+	}
+
+	return nil
+}
+
+// replaceEdited saves the link of an edited message (req.Cmd) and moves the
+// link it used to have (req.Args) to the trash. Both changes can be undone.
+// If the new link is already saved, the old page is kept.
+func (p *Processor) replaceEdited(req *Request) error {
+	addedBy := req.Meta.UserName
+	if req.Meta.Channel {
+		addedBy = ""
+	}
+
+	_, err := p.service.Add(req.Owner, addedBy, req.Cmd, nil)
+	if err != nil {
+		if !errors.Is(err, storage.ErrPageExists) {
+			return err
+		}
+
+		if req.Meta.Channel {
+			return nil
+		}
+		return p.reply(req.Meta, msgAlreadyExists)
+	}
+
+	err = p.service.Remove(req.Owner, req.Args.(string))
+	if err != nil && !errors.Is(err, storage.ErrNoPagesFound) {
+		return err
+	}
+
+	p.remember(req.Meta, req.Cmd)
+
+	if req.Meta.Channel {
+		return nil
+	}
+
+	return p.reply(req.Meta, msgUpdated)
+}
+
+// saveForwarded saves the first link found in a forwarded message.
+func (p *Processor) saveForwarded(text string, meta Meta) error {
+	pageURL := findURL(text)
+	if pageURL == "" {
+		return p.reply(meta, msgNoLinkInForward)
+	}
+
+	owner, err := p.owner(meta)
+	if err != nil {
+		return err
+	}
+
+	req := &Request{
+		Cmd:   pageURL,
+		Owner: owner,
+		Meta:  meta,
+	}
+
+	return p.router.Handle(req, p.savePage)
+}
+
+// remember records which link was saved from the given message,
+// so that a later edit of that message can replace it. Only messages that
+// created a page are remembered, so an edit never removes a page saved earlier.
+func (p *Processor) remember(meta Meta, pageURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.saved.put(messageKey{chatID: meta.ChatID, messageID: meta.MessageID}, pageURL)
+}
+
+// rememberChat records the private chat of a user, so that others can
+// share pages with them. The storage is only written when the chat is new.
+func (p *Processor) rememberChat(meta Meta) {
+	if meta.UserName == "" {
+		return
+	}
+
+	p.mu.Lock()
+	known := p.chats[meta.UserName] == meta.ChatID
+	p.mu.Unlock()
+
+	if known {
+		return
+	}
+
+	err := p.storage.SetUserChat(meta.UserName, meta.ChatID)
+	if err != nil {
+		slog.Warn("Failed to record user chat", "username", meta.UserName, "err", err)
+		return
+	}
+
+	p.mu.Lock()
+	p.chats[meta.UserName] = meta.ChatID
+	p.mu.Unlock()
+}
+
+// recall returns the link saved from the given message, if any.
+func (p *Processor) recall(meta Meta) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.saved.get(messageKey{chatID: meta.ChatID, messageID: meta.MessageID})
+}
+
 // meta extracts Meta information from the event and validates its type.
 func meta(event events.Event) (Meta, error) {
 	res, ok := event.Meta.(Meta)
@@ -105,38 +439,110 @@ func meta(event events.Event) (Meta, error) {
 // event converts a Telegram update to an internal Event type.
 func event(upd telegram.Update) events.Event {
 	updType := fetchType(upd)
+	msg := fetchMessage(upd)
 
 	res := events.Event{
 		Type: updType,
-		Text: fetchText(upd),
+		Text: fetchText(msg),
+	}
+
+	if updType == events.Unknown {
+		return res
 	}
 
-	if updType == events.Message {
+	if updType == events.Callback {
+		res.Text = upd.CallbackQuery.Data
 		res.Meta = Meta{
-			ChatID:   upd.Message.Chat.ID,
-			UserName: upd.Message.From.Username,
+			ChatID:     msg.Chat.ID,
+			UserID:     upd.CallbackQuery.From.ID,
+			UserName:   upd.CallbackQuery.From.Username,
+			MessageID:  msg.ID,
+			Group:      isGroup(msg.Chat),
+			CallbackID: upd.CallbackQuery.ID,
 		}
+		return res
 	}
 
+	channel := upd.ChannelPost != nil || upd.EditedChannelPost != nil
+
+	userName := msg.From.Username
+	if channel {
+		userName = chatOwner(msg.Chat.ID)
+	}
+
+	meta := Meta{
+		ChatID:    msg.Chat.ID,
+		UserID:    msg.From.ID,
+		UserName:  userName,
+		MessageID: msg.ID,
+		Forwarded: msg.ForwardOrigin != nil,
+		Channel:   channel,
+		Group:     isGroup(msg.Chat),
+	}
+
+	if msg.Document != nil {
+		meta.FileID = msg.Document.FileID
+		meta.FileSize = msg.Document.FileSize
+	}
+
+	res.Meta = meta
+
 	return res
 }
 
 // fetchType determines the type of the event based on the update content.
 func fetchType(upd telegram.Update) events.Type {
-	if upd.Message == nil {
-		slog.Warn("fetchType: incoming message are nil")
+	switch {
+	case upd.Message != nil:
+		return events.Message
+	case upd.EditedMessage != nil, upd.EditedChannelPost != nil:
+		return events.EditedMessage
+	case upd.ChannelPost != nil:
+		return events.ChannelPost
+	case upd.CallbackQuery != nil && upd.CallbackQuery.Message != nil:
+		return events.Callback
+	default:
+		slog.Debug("fetchType: unsupported update kind", "update_id", upd.ID)
 		return events.Unknown
 	}
+}
 
-	return events.Message
+// fetchMessage returns the message carried by the update, whatever its kind.
+func fetchMessage(upd telegram.Update) *telegram.Message {
+	switch {
+	case upd.Message != nil:
+		return upd.Message
+	case upd.EditedMessage != nil:
+		return upd.EditedMessage
+	case upd.ChannelPost != nil:
+		return upd.ChannelPost
+	case upd.CallbackQuery != nil:
+		return upd.CallbackQuery.Message
+	default:
+		return upd.EditedChannelPost
+	}
 }
 
-// fetchText extracts the message text from a Telegram update.
-func fetchText(upd telegram.Update) string {
-	if upd.Message == nil {
-		slog.Warn("fetchText: incoming message are nil")
+// fetchText extracts the message text, falling back to the media caption.
+func fetchText(msg *telegram.Message) string {
+	if msg == nil {
 		return ""
 	}
 
-	return upd.Message.Text
+	if msg.Text == "" {
+		return msg.Caption
+	}
+
+	return msg.Text
+}
+
+// isGroup reports whether the chat is a group or a supergroup.
+func isGroup(chat telegram.Chat) bool {
+	return chat.Type == telegram.ChatGroup || chat.Type == telegram.ChatSupergroup
+}
+
+// chatOwner returns the storage owner name used for pages that belong to a chat
+// rather than to a single user.
+func chatOwner(chatID int) string {
+	return fmt.Sprintf("chat:%d", chatID)
 }
@@ -4,8 +4,14 @@ import (
 	"URLbot/pkg/clients/telegram"
 	"URLbot/pkg/events"
 	tg "URLbot/pkg/events/telegram"
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -18,7 +24,31 @@ func (m *mockTelegramClient) GetUpdates(offset, limit int) ([]telegram.Update, e
 	return m.updates, m.err
 }
 
-func (m *mockTelegramClient) SendMessage(chatID int, text string) error {
+func (m *mockTelegramClient) SendMessage(chatID int, text string, opts ...telegram.SendOption) error {
+	return nil
+}
+
+func (m *mockTelegramClient) EditMessageText(chatID, messageID int, text string, opts ...telegram.SendOption) error {
+	return nil
+}
+
+func (m *mockTelegramClient) AnswerCallbackQuery(queryID, text string) error {
+	return nil
+}
+
+func (m *mockTelegramClient) GetChatMember(chatID, userID int) (*telegram.ChatMember, error) {
+	return &telegram.ChatMember{}, m.err
+}
+
+func (m *mockTelegramClient) GetFile(fileID string) (*telegram.File, error) {
+	return nil, m.err
+}
+
+func (m *mockTelegramClient) DownloadFile(filePath string, maxSize int64) ([]byte, error) {
+	return nil, m.err
+}
+
+func (m *mockTelegramClient) SendDocument(chatID int, fileName string, data []byte, caption string) error {
 	return nil
 }
 
@@ -73,6 +103,98 @@ func TestProcessor_Fetch(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "edited message, channel post, callback and unknown update",
+			client: &mockTelegramClient{
+				updates: []telegram.Update{
+					{
+						ID: 1,
+						EditedMessage: &telegram.Message{
+							ID:   7,
+							Text: "https://example.com",
+							From: telegram.From{Username: "User 1"},
+							Chat: telegram.Chat{ID: 10},
+						},
+					},
+					{
+						ID: 2,
+						ChannelPost: &telegram.Message{
+							ID:   8,
+							Text: "https://example.org",
+							Chat: telegram.Chat{ID: -100, Type: "channel"},
+						},
+					},
+					{
+						ID: 3,
+						Message: &telegram.Message{
+							ID:            9,
+							Caption:       "look https://example.net",
+							From:          telegram.From{Username: "User 2"},
+							Chat:          telegram.Chat{ID: 20},
+							ForwardOrigin: &telegram.MessageOrigin{Type: telegram.OriginChannel},
+						},
+					},
+					{
+						ID: 4,
+						CallbackQuery: &telegram.CallbackQuery{
+							ID:      "cb",
+							From:    telegram.From{Username: "User 3"},
+							Message: &telegram.Message{ID: 11, Chat: telegram.Chat{ID: 30}},
+							Data:    "share:accept:abc",
+						},
+					},
+					{
+						ID: 5,
+					},
+				},
+			},
+			limit: 10,
+			want: []events.Event{
+				{
+					Type: events.EditedMessage,
+					Text: "https://example.com",
+					Meta: tg.Meta{
+						ChatID:    10,
+						UserName:  "User 1",
+						MessageID: 7,
+					},
+				},
+				{
+					Type: events.ChannelPost,
+					Text: "https://example.org",
+					Meta: tg.Meta{
+						ChatID:    -100,
+						UserName:  "chat:-100",
+						MessageID: 8,
+						Channel:   true,
+					},
+				},
+				{
+					Type: events.Message,
+					Text: "look https://example.net",
+					Meta: tg.Meta{
+						ChatID:    20,
+						UserName:  "User 2",
+						MessageID: 9,
+						Forwarded: true,
+					},
+				},
+				{
+					Type: events.Callback,
+					Text: "share:accept:abc",
+					Meta: tg.Meta{
+						ChatID:     30,
+						UserName:   "User 3",
+						MessageID:  11,
+						CallbackID: "cb",
+					},
+				},
+				{
+					Type: events.Unknown,
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "empty updates",
 			client: &mockTelegramClient{
@@ -145,17 +267,23 @@ func TestProcessor_Process(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "unknown event type",
+			name: "unknown event is skipped",
 			client: &mockTelegramClient{
 				updates: []telegram.Update{},
 			},
 			event:   events.Event{},
+			wantErr: false,
+		},
+		{
+			name:    "unsupported event type",
+			client:  &mockTelegramClient{},
+			event:   events.Event{Type: events.Type(100)},
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			p := tg.New(tt.client, nil)
+			p := tg.New(tt.client, memory.New())
 			gotErr := p.Process(tt.event)
 			if gotErr != nil {
 				if !tt.wantErr {
@@ -169,3 +297,225 @@ func TestProcessor_Process(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessor_Process_edit(t *testing.T) {
+	s := memory.New()
+	p := tg.New(&mockTelegramClient{}, s)
+
+	meta := tg.Meta{ChatID: 10, UserName: "Alex", MessageID: 1}
+
+	err := p.Process(events.Event{Type: events.Message, Text: "https://old.example.com", Meta: meta})
+	if err != nil {
+		t.Fatalf("Process() failed: %v", err)
+	}
+
+	err = p.Process(events.Event{Type: events.EditedMessage, Text: "https://new.example.com", Meta: meta})
+	if err != nil {
+		t.Fatalf("Process() failed: %v", err)
+	}
+
+	pages, err := s.List("Alex")
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+
+	if len(pages) != 1 || pages[0].URL != "https://new.example.com" || pages[0].AddedBy != "Alex" {
+		t.Errorf("pages after edit = %+v, want the edited link only", pages)
+	}
+}
+
+func TestProcessor_Process_editUndo(t *testing.T) {
+	s := memory.New()
+	p := tg.New(&mockTelegramClient{}, s)
+
+	meta := tg.Meta{ChatID: 10, UserName: "Alex", MessageID: 1}
+
+	for _, ev := range []events.Event{
+		{Type: events.Message, Text: "https://old.example.com", Meta: meta},
+		{Type: events.EditedMessage, Text: "https://new.example.com", Meta: meta},
+		{Type: events.Message, Text: "/undo", Meta: tg.Meta{ChatID: 10, UserName: "Alex", MessageID: 2}},
+	} {
+		if err := p.Process(ev); err != nil {
+			t.Fatalf("Process(%q) failed: %v", ev.Text, err)
+		}
+	}
+
+	pages, err := s.List("Alex")
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+
+	urls := make(map[string]bool)
+	for _, page := range pages {
+		urls[page.URL] = true
+	}
+	if want := map[string]bool{"https://old.example.com": true, "https://new.example.com": true}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("pages after undoing an edit = %v, want %v", urls, want)
+	}
+}
+
+func TestProcessor_Process_editDuplicate(t *testing.T) {
+	s := memory.New()
+	p := tg.New(&mockTelegramClient{}, s)
+
+	first := tg.Meta{ChatID: 10, UserName: "Alex", MessageID: 1}
+	second := tg.Meta{ChatID: 10, UserName: "Alex", MessageID: 2}
+
+	// The second message saves nothing, so editing it must not touch the
+	// page saved by the first one.
+	for _, ev := range []events.Event{
+		{Type: events.Message, Text: "https://example.com", Meta: first},
+		{Type: events.Message, Text: "https://example.com", Meta: second},
+		{Type: events.EditedMessage, Text: "https://other.example.com", Meta: second},
+	} {
+		if err := p.Process(ev); err != nil {
+			t.Fatalf("Process(%q) failed: %v", ev.Text, err)
+		}
+	}
+
+	pages, err := s.List("Alex")
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(pages) != 1 || pages[0].URL != "https://example.com" {
+		t.Errorf("pages after editing a duplicate = %+v, want the first link only", pages)
+	}
+
+	if trash, _ := s.Trash("Alex"); len(trash) != 0 {
+		t.Errorf("trash after editing a duplicate = %+v, want it empty", trash)
+	}
+}
+
+func TestProcessor_Process_channelPostDuplicate(t *testing.T) {
+	s := memory.New()
+	p := tg.New(&mockTelegramClient{}, s)
+
+	owner := "chat:-100"
+	for i := 1; i <= 2; i++ {
+		meta := tg.Meta{ChatID: -100, UserName: owner, MessageID: i, Channel: true}
+
+		err := p.Process(events.Event{Type: events.ChannelPost, Text: "new post https://example.com", Meta: meta})
+		if err != nil {
+			t.Fatalf("Process() failed: %v", err)
+		}
+	}
+
+	// Editing the duplicate post is ignored, since it saved nothing.
+	meta := tg.Meta{ChatID: -100, UserName: owner, MessageID: 2, Channel: true}
+	err := p.Process(events.Event{Type: events.EditedMessage, Text: "https://other.example.com", Meta: meta})
+	if err != nil {
+		t.Fatalf("Process() failed: %v", err)
+	}
+
+	pages, err := s.List(owner)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(pages) != 1 || pages[0].URL != "https://example.com" {
+		t.Errorf("channel pages = %+v, want a single page", pages)
+	}
+}
+
+func TestProcessor_Process_editUnknownMessage(t *testing.T) {
+	s := memory.New()
+	client := &mockTelegramClient{}
+	p := tg.New(client, s)
+
+	// Edits of messages that did not save a link, such as commands or
+	// messages sent before the bot started, are ignored.
+	for i, text := range []string{"/remove https://example.com", "see https://example.com"} {
+		meta := tg.Meta{ChatID: -20, UserName: "Alex", MessageID: i + 1, Group: true}
+
+		err := p.Process(events.Event{Type: events.EditedMessage, Text: text, Meta: meta})
+		if err != nil {
+			t.Fatalf("Process() failed: %v", err)
+		}
+	}
+
+	if pages, err := s.List("Alex"); !errors.Is(err, storage.ErrNoPagesFound) {
+		t.Errorf("List() after edits = %+v, %v; want no pages", pages, err)
+	}
+}
+
+func TestProcessor_Process_rememberChat(t *testing.T) {
+	s := memory.New()
+	p := tg.New(&mockTelegramClient{}, s)
+
+	for _, meta := range []tg.Meta{
+		{ChatID: 10, UserName: "Alex", MessageID: 1},
+		{ChatID: -20, UserName: "Bob", MessageID: 2, Group: true},
+	} {
+		err := p.Process(events.Event{Type: events.Message, Text: "/help", Meta: meta})
+		if err != nil {
+			t.Fatalf("Process() failed: %v", err)
+		}
+	}
+
+	if chatID, err := s.UserChat("Alex"); err != nil || chatID != 10 {
+		t.Errorf("UserChat() after a private message = %d, %v; want 10", chatID, err)
+	}
+	if _, err := s.UserChat("Bob"); !errors.Is(err, storage.ErrUnknownUser) {
+		t.Errorf("UserChat() after a group message error = %v, want %v", err, storage.ErrUnknownUser)
+	}
+}
+
+func TestProcessor_Process_import(t *testing.T) {
+	const pocketCSV = `title,url,time_added,tags,status
+Go blog,https://go.dev/blog,1700000000,go|blog,unread
+Saved,https://example.com,1700000001,,archive
+Broken,not a link,1700000002,,unread
+`
+
+	var sent []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bottest-token/getFile":
+			_, _ = w.Write([]byte(`{"ok": true, "result": {"file_id": "f1", "file_path": "documents/file_1.csv"}}`))
+		case "/file/bottest-token/documents/file_1.csv":
+			_, _ = w.Write([]byte(pocketCSV))
+		case "/bottest-token/sendMessage":
+			sent = append(sent, r.URL.Query().Get("text"))
+			_, _ = w.Write([]byte(`{"ok": true}`))
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse failed: %v", err)
+	}
+
+	s := memory.New()
+	err = s.Save(&storage.Page{URL: "https://example.com", UserName: "Alex"})
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	p := tg.New(telegram.NewClient(u.Scheme, u.Host, "test-token"), s)
+
+	err = p.Process(events.Event{
+		Type: events.Message,
+		Text: "/import",
+		Meta: tg.Meta{ChatID: 10, UserName: "Alex", FileID: "f1"},
+	})
+	if err != nil {
+		t.Fatalf("Process() failed: %v", err)
+	}
+
+	pages, err := s.List("Alex")
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+
+	if len(pages) != 2 || pages[1].URL != "https://go.dev/blog" || !reflect.DeepEqual(pages[1].Tags, []string{"go", "blog"}) {
+		t.Errorf("unexpected pages after import: %+v", pages)
+	}
+
+	if len(sent) != 1 || !strings.Contains(sent[0], "Imported: 1\nSkipped (already saved): 1\nInvalid: 1") {
+		t.Errorf("unexpected report: %q", sent)
+	}
+}
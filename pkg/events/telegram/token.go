@@ -0,0 +1,53 @@
+package telegram
+
+import (
+	"URLbot/pkg/format"
+	"fmt"
+	"strings"
+)
+
+// SetAPIURL sets the public address of the HTTP API, enabling /token.
+func (p *Processor) SetAPIURL(baseURL string) {
+	p.apiURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// token issues a new API token for the owner, replacing the old one.
+// With "revoke" the token is removed instead. Tokens give full access to the
+// list, so they are only handed out in private chats.
+func (p *Processor) token(req *Request) error {
+	if p.apiURL == "" {
+		return p.reply(req.Meta, msgAPIDisabled)
+	}
+
+	if req.Meta.Group || req.Meta.Channel {
+		return p.reply(req.Meta, msgTokenPrivate)
+	}
+
+	if req.Args.(bool) {
+		err := p.service.RevokeToken(req.Owner)
+		if err != nil {
+			return err
+		}
+
+		return p.reply(req.Meta, msgTokenRevoked)
+	}
+
+	token, err := p.service.IssueToken(req.Owner)
+	if err != nil {
+		return err
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgTokenFmt, format.HTML.Code(token), format.HTML.Code(p.apiURL+"/api/v1/pages")))
+}
+
+// parseTokenArgs parses the arguments of /token. It reports whether the token should be revoked.
+func parseTokenArgs(raw string) (any, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "":
+		return false, nil
+	case "revoke":
+		return true, nil
+	default:
+		return nil, &UsageError{Msg: msgTokenUsage}
+	}
+}
@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestProcessor_token(t *testing.T) {
+	client := &mockClient{}
+	p := New(client, memory.New())
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	run := func(text string, meta Meta) string {
+		t.Helper()
+
+		client.sent = nil
+		if err := p.doCmd(text, meta); err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", text, err)
+		}
+		if len(client.sent) != 1 {
+			t.Fatalf("doCmd(%q) sent %q, want a single message", text, client.sent)
+		}
+
+		return client.sent[0]
+	}
+
+	if got := run("/token", meta); got != msgAPIDisabled {
+		t.Errorf("/token without an API sent %q, want %q", got, msgAPIDisabled)
+	}
+
+	p.SetAPIURL("https://bot.example/")
+
+	if got := run("/token", Meta{ChatID: -100, UserName: "alex", Group: true}); got != msgTokenPrivate {
+		t.Errorf("/token in a group sent %q, want %q", got, msgTokenPrivate)
+	}
+
+	reply := run("/token", meta)
+	m := regexp.MustCompile(`<code>([0-9a-f]{64})</code>`).FindStringSubmatch(reply)
+	if m == nil {
+		t.Fatalf("/token sent %q, want a token", reply)
+	}
+	if want := "<code>https://bot.example/api/v1/pages</code>"; !strings.Contains(reply, want) {
+		t.Errorf("/token sent %q, want it to contain %q", reply, want)
+	}
+	if owner, err := p.service.TokenOwner(m[1]); err != nil || owner != "alex" {
+		t.Errorf("TokenOwner() of the sent token = %q, %v; want alex", owner, err)
+	}
+
+	if got := run("/token revoke", meta); got != msgTokenRevoked {
+		t.Errorf("/token revoke sent %q, want %q", got, msgTokenRevoked)
+	}
+	if _, err := p.service.TokenOwner(m[1]); !errors.Is(err, storage.ErrNoAPIToken) {
+		t.Errorf("TokenOwner() after /token revoke error = %v, want %v", err, storage.ErrNoAPIToken)
+	}
+
+	if got := run("/token now", meta); got != msgTokenUsage {
+		t.Errorf("/token now sent %q, want %q", got, msgTokenUsage)
+	}
+}
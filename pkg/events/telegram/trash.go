@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"URLbot/pkg/blob"
+	"URLbot/pkg/clients/telegram"
+	"URLbot/pkg/format"
+	"URLbot/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// defaultTrashRetention is how long removed pages stay in the trash.
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// trashArgs holds the parsed arguments of /trash.
+type trashArgs struct {
+	restore int // ID of the page to restore, zero to list the trash.
+}
+
+// trash lists the owner's removed pages or restores one of them.
+func (p *Processor) trash(req *Request) error {
+	args := req.Args.(trashArgs)
+
+	pages, err := p.storage.Trash(req.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to get trash: %v", err)
+	}
+
+	if args.restore != 0 {
+		return p.restore(req, pages, args.restore)
+	}
+
+	if len(pages) == 0 {
+		return p.reply(req.Meta, msgTrashEmpty)
+	}
+
+	var builder strings.Builder
+	days := int(p.trashRetention.Hours() / 24)
+	builder.WriteString(format.HTML.Bold(fmt.Sprintf(msgTrashHeaderFmt, days)) + "\n\n")
+
+	for _, page := range pages {
+		fmt.Fprintf(&builder, "%d. %s — removed %s\n", page.ID,
+			format.HTML.Link(listTitle(page), page.URL), page.DeletedAt.UTC().Format("02 Jan"))
+	}
+
+	builder.WriteString("\n" + msgTrashFooter)
+
+	return p.reply(req.Meta, builder.String(), telegram.WithoutPreview())
+}
+
+// restore moves the trashed page with the given ID back to the owner's list.
+func (p *Processor) restore(req *Request, trashed []*storage.Page, id int) error {
+	var page *storage.Page
+	for _, trashedPage := range trashed {
+		if trashedPage.ID == id {
+			page = trashedPage
+			break
+		}
+	}
+
+	if page == nil {
+		return p.reply(req.Meta, msgNotInTrash)
+	}
+
+	_, err := p.storage.Restore(req.Owner, page.URL)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPageExists):
+			return p.reply(req.Meta, msgRestoreExists)
+		case errors.Is(err, storage.ErrNoPagesFound):
+			return p.reply(req.Meta, msgNotInTrash)
+		}
+
+		return fmt.Errorf("failed to restore page: %v", err)
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgRestoredFmt, format.HTML.Link(listTitle(page), page.URL)),
+		telegram.WithoutPreview())
+}
+
+// PurgeTrash permanently deletes pages that have been in the trash for longer
+// than the retention period, together with their snapshots. It is meant to be
+// run by the scheduler.
+func (p *Processor) PurgeTrash(ctx context.Context, now time.Time) error {
+	pages, err := p.storage.PurgeTrash(now.Add(-p.trashRetention))
+	if err != nil {
+		return fmt.Errorf("failed to purge trash: %v", err)
+	}
+
+	if len(pages) == 0 {
+		return nil
+	}
+
+	for _, page := range pages {
+		if page.SnapshotKey == "" || p.blobs == nil {
+			continue
+		}
+
+		err := p.blobs.Delete(page.SnapshotKey)
+		if err != nil && !errors.Is(err, blob.ErrNotFound) {
+			slog.Error("Failed to delete snapshot", "key", page.SnapshotKey, "url", page.URL, "err", err)
+		}
+	}
+
+	slog.Info("Purged trash", "pages", len(pages))
+
+	return nil
+}
+
+// parseTrashArgs parses the arguments of /trash: nothing or "restore <id>".
+func parseTrashArgs(raw string) (any, error) {
+	fields := strings.Fields(raw)
+
+	switch {
+	case len(fields) == 0:
+		return trashArgs{}, nil
+	case len(fields) == 2 && strings.EqualFold(fields[0], "restore"):
+		id, ok := parseID(fields[1])
+		if !ok {
+			break
+		}
+
+		return trashArgs{restore: id}, nil
+	}
+
+	return nil, &UsageError{Msg: msgTrashUsage}
+}
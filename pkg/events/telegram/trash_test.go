@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessor_trash(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	steps := []struct {
+		text     string
+		wantSend string
+	}{
+		{text: "/trash", wantSend: msgTrashEmpty},
+		{text: "https://example.com/a", wantSend: msgSaved},
+		{text: "https://example.com/b", wantSend: msgSaved},
+		{text: "/remove https://example.com/a", wantSend: msgRemoved},
+		{text: "/trash", wantSend: "<b>" + fmt.Sprintf(msgTrashHeaderFmt, 30) + "</b>\n\n1. "},
+		{text: "/trash restore 2", wantSend: msgNotInTrash},
+		{text: "/trash restore", wantSend: msgTrashUsage},
+		{text: "https://example.com/a", wantSend: msgSaved},
+		{text: "/trash restore 1", wantSend: msgRestoreExists},
+		{text: "/remove https://example.com/a", wantSend: msgRemoved},
+		{text: "/trash restore #3", wantSend: fmt.Sprintf(msgRestoredFmt, "")},
+		{text: "/trash", wantSend: msgTrashEmpty},
+	}
+
+	for _, step := range steps {
+		client.sent = nil
+
+		err := p.doCmd(step.text, meta)
+		if err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", step.text, err)
+		}
+
+		if len(client.sent) != 1 || !strings.HasPrefix(client.sent[0], step.wantSend) {
+			t.Errorf("doCmd(%q) sent %q, want %q", step.text, client.sent, step.wantSend)
+		}
+	}
+
+	pages, _ := s.List("alex")
+	if len(pages) != 2 {
+		t.Errorf("List() returned %d pages, want 2", len(pages))
+	}
+}
+
+func TestProcessor_PurgeTrash(t *testing.T) {
+	s := memory.New()
+	blobs := mockBlobs{"snapshots/1.html": []byte("a"), "snapshots/2.html": []byte("b")}
+	p := New(&mockClient{}, s)
+	p.SetSnapshots(blobs)
+	p.SetTrashRetention(24 * time.Hour)
+
+	for i := 1; i <= 2; i++ {
+		page := &storage.Page{
+			URL:         fmt.Sprintf("https://example.com/%d", i),
+			UserName:    "alex",
+			SnapshotKey: fmt.Sprintf("snapshots/%d.html", i),
+		}
+
+		err := s.Save(page)
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	err := s.Remove(&storage.Page{URL: "https://example.com/1", UserName: "alex"})
+	if err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+
+	err = p.PurgeTrash(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("PurgeTrash() failed: %v", err)
+	}
+	if trash, _ := s.Trash("alex"); len(trash) != 1 {
+		t.Fatalf("trash has %d pages before the retention ended, want 1", len(trash))
+	}
+
+	err = p.PurgeTrash(context.Background(), time.Now().Add(25*time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeTrash() failed: %v", err)
+	}
+
+	if trash, _ := s.Trash("alex"); len(trash) != 0 {
+		t.Errorf("trash has %d pages after the retention ended, want 0", len(trash))
+	}
+	if _, ok := blobs["snapshots/1.html"]; ok {
+		t.Error("snapshot of the purged page was not deleted")
+	}
+	if _, ok := blobs["snapshots/2.html"]; !ok {
+		t.Error("snapshot of a kept page was deleted")
+	}
+}
@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"URLbot/pkg/clients/telegram"
+	"URLbot/pkg/format"
+	"URLbot/pkg/storage"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// undoNames describe the undone actions to the user.
+var undoNames = map[storage.ActionKind]string{
+	storage.ActionSave:   "saving",
+	storage.ActionRead:   "marking as read",
+	storage.ActionRemove: "removing",
+	storage.ActionTag:    "tagging",
+	storage.ActionUnread: "marking as unread",
+	storage.ActionMove:   "fixing the link of",
+}
+
+// undo reverses the last save, read, unread, remove, tag or link fix of the owner.
+// An undone save moves the page to the trash, so it can still be restored.
+func (p *Processor) undo(req *Request) error {
+	a, err := p.storage.PopUndo(req.Owner)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoUndo) {
+			return p.reply(req.Meta, msgNothingToUndo)
+		}
+
+		return fmt.Errorf("failed to get last action: %v", err)
+	}
+
+	switch a.Kind {
+	case storage.ActionSave:
+		err = p.storage.Remove(&storage.Page{URL: a.URL, UserName: req.Owner})
+	case storage.ActionRead:
+		_, _, err = p.service.SetRead(req.Owner, a.URL, false, time.Time{})
+	case storage.ActionUnread:
+		_, _, err = p.service.SetRead(req.Owner, a.URL, true, a.ReadAt)
+	case storage.ActionRemove:
+		_, err = p.storage.Restore(req.Owner, a.URL)
+	case storage.ActionMove:
+		err = p.storage.ChangeURL(req.Owner, a.URL, a.OldURL)
+	case storage.ActionTag:
+		err = p.storage.Update(req.Owner, a.URL, func(stored *storage.Page) {
+			stored.Tags = a.Tags
+		})
+	default:
+		return fmt.Errorf("unknown action kind %d", a.Kind)
+	}
+
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) || errors.Is(err, storage.ErrPageExists) {
+			return p.reply(req.Meta, msgUndoGone)
+		}
+
+		return fmt.Errorf("failed to undo %s: %v", undoNames[a.Kind], err)
+	}
+
+	return p.reply(req.Meta, fmt.Sprintf(msgUndoneFmt, undoNames[a.Kind], format.HTML.Link(shortURL(a.URL), a.URL)),
+		telegram.WithoutPreview())
+}
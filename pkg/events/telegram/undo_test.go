@@ -0,0 +1,150 @@
+package telegram
+
+import (
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessor_undo(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+	const pageURL = "https://example.com/a"
+
+	do := func(text, wantSend string) {
+		t.Helper()
+		client.sent = nil
+
+		err := p.doCmd(text, meta)
+		if err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", text, err)
+		}
+		if len(client.sent) != 1 || !strings.HasPrefix(client.sent[0], wantSend) {
+			t.Fatalf("doCmd(%q) sent %q, want %q", text, client.sent, wantSend)
+		}
+	}
+
+	do(pageURL, msgSaved)
+	do("/tag 1 go", fmt.Sprintf(msgTagsFmt, 1, "#go"))
+	do("/tag 1 #News -go", fmt.Sprintf(msgTagsFmt, 1, "#news"))
+	do("/read "+pageURL, msgMarkedAsRead)
+	do("/read "+pageURL, msgMarkedAsRead) // Already read: nothing to undo.
+	do("/remove "+pageURL, msgRemoved)
+
+	if _, err := s.Get("alex", 1); err == nil {
+		t.Fatal("removed page is still in the list")
+	}
+
+	do("/undo", fmt.Sprintf(msgUndoneFmt, "removing", ""))
+	page, err := s.Get("alex", 1)
+	if err != nil {
+		t.Fatalf("Get() after undoing remove failed: %v", err)
+	}
+	if !page.Read {
+		t.Error("restored page lost its read status")
+	}
+
+	do("/undo", fmt.Sprintf(msgUndoneFmt, "marking as read", ""))
+	page, _ = s.Get("alex", 1)
+	if page.Read || !page.ReadAt.IsZero() {
+		t.Errorf("page after undoing read: Read %v, ReadAt %v; want unread", page.Read, page.ReadAt)
+	}
+
+	do("/undo", fmt.Sprintf(msgUndoneFmt, "tagging", ""))
+	page, _ = s.Get("alex", 1)
+	if !reflect.DeepEqual(page.Tags, []string{"go"}) {
+		t.Errorf("tags after undoing a tag = %v, want [go]", page.Tags)
+	}
+
+	do("/undo", fmt.Sprintf(msgUndoneFmt, "tagging", ""))
+	do("/undo", fmt.Sprintf(msgUndoneFmt, "saving", ""))
+
+	if _, err := s.Get("alex", 1); err == nil {
+		t.Error("page is still in the list after undoing its save")
+	}
+	trash, _ := s.Trash("alex")
+	if len(trash) != 1 || trash[0].URL != pageURL {
+		t.Errorf("trash after undoing a save = %v, want the page", trash)
+	}
+
+	do("/undo", msgNothingToUndo)
+}
+
+func TestProcessor_undoGone(t *testing.T) {
+	s := memory.New()
+	client := &mockClient{}
+	p := New(client, s)
+	meta := Meta{ChatID: 1, UserName: "alex"}
+
+	for _, text := range []string{"https://example.com", "/remove https://example.com", "https://example.com"} {
+		err := p.doCmd(text, meta)
+		if err != nil {
+			t.Fatalf("doCmd(%q) failed: %v", text, err)
+		}
+	}
+
+	// The save is undone first, then the removal can be undone as well.
+	for _, want := range []string{"saving", "removing"} {
+		client.sent = nil
+
+		err := p.doCmd("/undo", meta)
+		if err != nil {
+			t.Fatalf("doCmd() failed: %v", err)
+		}
+		if len(client.sent) != 1 || !strings.Contains(client.sent[0], want) {
+			t.Errorf("/undo sent %q, want it to undo %s", client.sent, want)
+		}
+	}
+
+	// Both copies were in the trash; the undone save replaced the removed one.
+	pages, _ := s.List("alex")
+	if len(pages) != 1 || pages[0].ID != 2 {
+		t.Errorf("list after undo = %v, want the page saved second", pages)
+	}
+
+	err := s.PushUndo("alex", storage.Action{Kind: storage.ActionRemove, URL: "https://gone.com"})
+	if err != nil {
+		t.Fatalf("PushUndo() failed: %v", err)
+	}
+
+	client.sent = nil
+	err = p.doCmd("/undo", meta)
+	if err != nil {
+		t.Fatalf("doCmd() failed: %v", err)
+	}
+	if len(client.sent) != 1 || client.sent[0] != msgUndoGone {
+		t.Errorf("/undo of a purged page sent %q, want %q", client.sent, msgUndoGone)
+	}
+}
+
+func TestParseTagArgs(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    tagArgs
+		wantErr bool
+	}{
+		{raw: "3 go", want: tagArgs{id: 3, add: []string{"go"}}},
+		{raw: "#3 #Go -news", want: tagArgs{id: 3, add: []string{"go"}, remove: []string{"news"}}},
+		{raw: "3 -#news", want: tagArgs{id: 3, remove: []string{"news"}}},
+		{raw: "3", wantErr: true},
+		{raw: "go 3", wantErr: true},
+		{raw: "3 -", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseTagArgs(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTagArgs(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTagArgs(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
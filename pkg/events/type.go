@@ -16,6 +16,9 @@ type Type int
 const (
 	Unknown Type = iota
 	Message
+	EditedMessage
+	ChannelPost
+	Callback
 )
 
 // Event represents a single event in the system, such as a user message.
@@ -0,0 +1,216 @@
+// Package exporter writes a reading list in one of the supported file formats.
+package exporter
+
+import (
+	"URLbot/pkg/storage"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Format is a file format supported by Export.
+type Format string
+
+// Supported export formats.
+const (
+	JSON     Format = "json"
+	CSV      Format = "csv"
+	HTML     Format = "html"
+	Markdown Format = "md"
+)
+
+// Formats lists all supported formats in the order they are offered to users.
+var Formats = []Format{JSON, CSV, HTML, Markdown}
+
+// ParseFormat converts a user-supplied format name into a Format.
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	if name == "markdown" {
+		name = string(Markdown)
+	}
+
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+
+	return "", ErrUnknownFormat
+}
+
+// FileName returns the name of the export file for the given date.
+func (f Format) FileName(now time.Time) string {
+	return "namnada-link-" + now.Format("2006-01-02") + "." + string(f)
+}
+
+// Export writes the pages to w in the given format.
+func Export(w io.Writer, f Format, pages []*storage.Page) error {
+	switch f {
+	case JSON:
+		return exportJSON(w, pages)
+	case CSV:
+		return exportCSV(w, pages)
+	case HTML:
+		return exportHTML(w, pages)
+	case Markdown:
+		return exportMarkdown(w, pages)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// jsonPage is the JSON representation of a page.
+type jsonPage struct {
+	URL       string     `json:"url"`
+	Title     string     `json:"title,omitempty"`
+	Read      bool       `json:"read"`
+	AddedBy   string     `json:"added_by,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// exportJSON writes the pages as an indented JSON array.
+func exportJSON(w io.Writer, pages []*storage.Page) error {
+	res := make([]jsonPage, 0, len(pages))
+	for _, p := range pages {
+		res = append(res, jsonPage{
+			URL:       p.URL,
+			Title:     p.Title,
+			Read:      p.Read,
+			AddedBy:   p.AddedBy,
+			Tags:      p.Tags,
+			CreatedAt: optionalTime(p.CreatedAt),
+			ReadAt:    optionalTime(p.ReadAt),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(res)
+	if err != nil {
+		return fmt.Errorf("failed to encode json: %v", err)
+	}
+
+	return nil
+}
+
+// exportCSV writes the pages as CSV with a header row.
+func exportCSV(w io.Writer, pages []*storage.Page) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"url", "title", "read", "added_by", "tags", "created_at", "read_at"})
+	if err != nil {
+		return fmt.Errorf("failed to write csv: %v", err)
+	}
+
+	for _, p := range pages {
+		err = cw.Write([]string{
+			p.URL,
+			p.Title,
+			strconv.FormatBool(p.Read),
+			p.AddedBy,
+			strings.Join(p.Tags, "|"),
+			formatTime(p.CreatedAt),
+			formatTime(p.ReadAt),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write csv: %v", err)
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// exportHTML writes the pages in the Netscape bookmark format understood by browsers.
+func exportHTML(w io.Writer, pages []*storage.Page) error {
+	var builder strings.Builder
+
+	builder.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	builder.WriteString(`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">` + "\n")
+	builder.WriteString("<TITLE>NAMNADA LINK</TITLE>\n<H1>NAMNADA LINK</H1>\n<DL><p>\n")
+
+	for _, p := range pages {
+		fmt.Fprintf(&builder, `    <DT><A HREF="%s"`, html.EscapeString(p.URL))
+		if !p.CreatedAt.IsZero() {
+			fmt.Fprintf(&builder, ` ADD_DATE="%d"`, p.CreatedAt.Unix())
+		}
+		if len(p.Tags) > 0 {
+			fmt.Fprintf(&builder, ` TAGS="%s"`, html.EscapeString(strings.Join(p.Tags, ",")))
+		}
+		fmt.Fprintf(&builder, ` READ="%t">%s</A>`+"\n", p.Read, html.EscapeString(p.DisplayTitle()))
+	}
+
+	builder.WriteString("</DL><p>\n")
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// exportMarkdown writes the pages as a Markdown task list.
+func exportMarkdown(w io.Writer, pages []*storage.Page) error {
+	var builder strings.Builder
+
+	builder.WriteString("# NAMNADA LINK\n\n")
+
+	for _, p := range pages {
+		check := " "
+		if p.Read {
+			check = "x"
+		}
+
+		if p.Title != "" {
+			fmt.Fprintf(&builder, "- [%s] [%s](<%s>)", check, escapeMarkdown(p.Title), p.URL)
+		} else {
+			fmt.Fprintf(&builder, "- [%s] <%s>", check, p.URL)
+		}
+		if !p.CreatedAt.IsZero() {
+			fmt.Fprintf(&builder, " — saved %s", p.CreatedAt.Format("2006-01-02"))
+		}
+		if len(p.Tags) > 0 {
+			fmt.Fprintf(&builder, " — #%s", strings.Join(p.Tags, " #"))
+		}
+		builder.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// markdownEscaper escapes the characters that would break a Markdown link title.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`")
+
+// escapeMarkdown escapes text for use inside a Markdown link title.
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// optionalTime returns nil for the zero time, so that it is omitted from JSON.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// formatTime formats a timestamp as RFC 3339, or returns an empty string for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,116 @@
+package exporter_test
+
+import (
+	"URLbot/pkg/exporter"
+	"URLbot/pkg/storage"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExport(t *testing.T) {
+	saved := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	pages := []*storage.Page{
+		{
+			URL:       "https://example.com/?a=1&b=2",
+			Title:     "Go [tips] & tricks",
+			Read:      true,
+			Tags:      []string{"go", "web"},
+			CreatedAt: saved,
+			ReadAt:    saved.Add(time.Hour),
+		},
+		{
+			URL: "https://example.org",
+		},
+	}
+
+	tests := []struct {
+		format exporter.Format
+		want   string
+	}{
+		{
+			format: exporter.JSON,
+			want: `[
+  {
+    "url": "https://example.com/?a=1&b=2",
+    "title": "Go [tips] & tricks",
+    "read": true,
+    "tags": [
+      "go",
+      "web"
+    ],
+    "created_at": "2026-01-02T03:04:05Z",
+    "read_at": "2026-01-02T04:04:05Z"
+  },
+  {
+    "url": "https://example.org",
+    "read": false
+  }
+]
+`,
+		},
+		{
+			format: exporter.CSV,
+			want: "url,title,read,added_by,tags,created_at,read_at\n" +
+				"https://example.com/?a=1&b=2,Go [tips] & tricks,true,,go|web,2026-01-02T03:04:05Z,2026-01-02T04:04:05Z\n" +
+				"https://example.org,,false,,,,\n",
+		},
+		{
+			format: exporter.HTML,
+			want: "<!DOCTYPE NETSCAPE-Bookmark-file-1>\n" +
+				`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">` + "\n" +
+				"<TITLE>NAMNADA LINK</TITLE>\n<H1>NAMNADA LINK</H1>\n<DL><p>\n" +
+				`    <DT><A HREF="https://example.com/?a=1&amp;b=2" ADD_DATE="1767323045" TAGS="go,web" READ="true">Go [tips] &amp; tricks</A>` + "\n" +
+				`    <DT><A HREF="https://example.org" READ="false">https://example.org</A>` + "\n" +
+				"</DL><p>\n",
+		},
+		{
+			format: exporter.Markdown,
+			want: "# NAMNADA LINK\n\n" +
+				"- [x] [Go \\[tips\\] & tricks](<https://example.com/?a=1&b=2>) — saved 2026-01-02 — #go #web\n" +
+				"- [ ] <https://example.org>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			err := exporter.Export(&buf, tt.format, pages)
+			if err != nil {
+				t.Fatalf("Export() failed: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Export() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    exporter.Format
+		wantErr error
+	}{
+		{name: "json", want: exporter.JSON},
+		{name: "CSV", want: exporter.CSV},
+		{name: "markdown", want: exporter.Markdown},
+		{name: ".html", want: exporter.HTML},
+		{name: "xml", wantErr: exporter.ErrUnknownFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := exporter.ParseFormat(tt.name)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseFormat() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
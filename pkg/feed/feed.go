@@ -0,0 +1,321 @@
+// Package feed publishes reading lists as Atom, RSS and JSON feeds over HTTP.
+package feed
+
+import (
+	"URLbot/pkg/storage"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownFormat = errors.New("unknown feed format")
+
+// Format is a feed format supported by Write.
+type Format string
+
+// Supported feed formats.
+const (
+	Atom Format = "atom"
+	RSS  Format = "rss"
+	JSON Format = "json"
+)
+
+// Formats lists all supported formats in the order they are offered to users.
+var Formats = []Format{Atom, RSS, JSON}
+
+// ParseFormat converts a format name from a feed URL into a Format.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+
+	return "", ErrUnknownFormat
+}
+
+// ContentType returns the MIME type the feed is served with.
+func (f Format) ContentType() string {
+	switch f {
+	case Atom:
+		return "application/atom+xml; charset=utf-8"
+	case RSS:
+		return "application/rss+xml; charset=utf-8"
+	default:
+		return "application/feed+json; charset=utf-8"
+	}
+}
+
+// URL returns the address of a feed under baseURL. An empty collection
+// means the feed of the whole list.
+func URL(baseURL, token string, f Format, collection string) string {
+	u := strings.TrimSuffix(baseURL, "/") + "/feeds/" + url.PathEscape(token) + "/" + string(f)
+	if collection != "" {
+		u += "?collection=" + url.QueryEscape(collection)
+	}
+
+	return u
+}
+
+// Feed is a list of pages ready to be written in one of the formats.
+// Pages are written in the given order.
+type Feed struct {
+	ID      string // Stable identifier of the feed, such as a URN.
+	Title   string
+	Link    string // Address the feed is served at.
+	Updated time.Time
+	Pages   []*storage.Page
+}
+
+// Write writes the feed to w in the given format.
+func Write(w io.Writer, f Format, feed *Feed) error {
+	switch f {
+	case Atom:
+		return writeAtom(w, feed)
+	case RSS:
+		return writeRSS(w, feed)
+	case JSON:
+		return writeJSON(w, feed)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// pageID returns the identifier of a page in feeds.
+func pageID(p *storage.Page) string {
+	return "urn:namnada-link:page:" + strconv.Itoa(p.ID)
+}
+
+// pageTitle returns the title of a page, falling back to its URL.
+func pageTitle(p *storage.Page) string {
+	if p.Title != "" {
+		return p.Title
+	}
+
+	return p.URL
+}
+
+// pageUpdated returns when the page last changed: when it was read,
+// or when it was saved if it is unread.
+func pageUpdated(p *storage.Page) time.Time {
+	if p.ReadAt.After(p.CreatedAt) {
+		return p.ReadAt
+	}
+
+	return p.CreatedAt
+}
+
+// atomFeed is the Atom (RFC 4287) representation of a feed.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// writeAtom writes the feed as an Atom document.
+func writeAtom(w io.Writer, feed *Feed) error {
+	res := atomFeed{
+		ID:      feed.ID,
+		Title:   feed.Title,
+		Updated: feed.Updated.UTC().Format(time.RFC3339),
+		Link:    atomLink{Rel: "self", Href: feed.Link},
+		Author:  atomAuthor{Name: "NAMNADA LINK"},
+	}
+
+	for _, p := range feed.Pages {
+		entry := atomEntry{
+			ID:        pageID(p),
+			Title:     pageTitle(p),
+			Link:      atomLink{Rel: "alternate", Href: p.URL},
+			Published: p.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   pageUpdated(p).UTC().Format(time.RFC3339),
+			Summary:   p.Description,
+		}
+		if p.AddedBy != "" {
+			entry.Author = &atomAuthor{Name: p.AddedBy}
+		}
+		for _, tag := range p.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+
+		res.Entries = append(res.Entries, entry)
+	}
+
+	return writeXML(w, res)
+}
+
+// rssFeed is the RSS 2.0 representation of a feed.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description,omitempty"`
+	Categories  []string `xml:"category"`
+}
+
+// writeRSS writes the feed as an RSS 2.0 document.
+func writeRSS(w io.Writer, feed *Feed) error {
+	res := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          feed.Link,
+			Description:   feed.Title,
+			LastBuildDate: feed.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, p := range feed.Pages {
+		res.Channel.Items = append(res.Channel.Items, rssItem{
+			Title:       pageTitle(p),
+			Link:        p.URL,
+			GUID:        rssGUID{Value: pageID(p)},
+			PubDate:     p.CreatedAt.UTC().Format(time.RFC1123Z),
+			Description: p.Description,
+			Categories:  p.Tags,
+		})
+	}
+
+	return writeXML(w, res)
+}
+
+// writeXML writes v as an indented XML document with a declaration.
+func writeXML(w io.Writer, v any) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return fmt.Errorf("failed to write xml: %v", err)
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	err = enc.Encode(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode xml: %v", err)
+	}
+
+	_, err = io.WriteString(w, "\n")
+	if err != nil {
+		return fmt.Errorf("failed to write xml: %v", err)
+	}
+
+	return nil
+}
+
+// jsonFeed is the JSON Feed 1.1 representation of a feed.
+type jsonFeed struct {
+	Version string     `json:"version"`
+	Title   string     `json:"title"`
+	FeedURL string     `json:"feed_url"`
+	Items   []jsonItem `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentText   string       `json:"content_text"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+// writeJSON writes the feed as a JSON Feed document.
+func writeJSON(w io.Writer, feed *Feed) error {
+	res := jsonFeed{
+		Version: "https://jsonfeed.org/version/1.1",
+		Title:   feed.Title,
+		FeedURL: feed.Link,
+		Items:   make([]jsonItem, 0, len(feed.Pages)),
+	}
+
+	for _, p := range feed.Pages {
+		item := jsonItem{
+			ID:            pageID(p),
+			URL:           p.URL,
+			Title:         pageTitle(p),
+			ContentText:   p.Description,
+			DatePublished: p.CreatedAt.UTC().Format(time.RFC3339),
+			DateModified:  pageUpdated(p).UTC().Format(time.RFC3339),
+			Tags:          p.Tags,
+		}
+		if item.ContentText == "" {
+			item.ContentText = p.URL
+		}
+		if p.AddedBy != "" {
+			item.Authors = []jsonAuthor{{Name: p.AddedBy}}
+		}
+
+		res.Items = append(res.Items, item)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(res)
+	if err != nil {
+		return fmt.Errorf("failed to encode json: %v", err)
+	}
+
+	return nil
+}
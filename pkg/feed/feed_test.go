@@ -0,0 +1,179 @@
+package feed_test
+
+import (
+	"URLbot/pkg/feed"
+	"URLbot/pkg/storage"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	saved := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	f := &feed.Feed{
+		ID:      "urn:namnada-link:feed:alex",
+		Title:   "NAMNADA LINK: alex",
+		Link:    "https://bot.example/feeds/t",
+		Updated: saved.Add(time.Hour),
+		Pages: []*storage.Page{
+			{
+				ID:          1,
+				URL:         "https://example.com/?a=1&b=2",
+				Title:       "Go [tips] & tricks",
+				Description: "All about <Go>",
+				AddedBy:     "bob",
+				Tags:        []string{"go"},
+				Read:        true,
+				CreatedAt:   saved,
+				ReadAt:      saved.Add(time.Hour),
+			},
+			{
+				ID:        2,
+				URL:       "https://example.org",
+				CreatedAt: saved,
+			},
+		},
+	}
+
+	tests := []struct {
+		format feed.Format
+		want   string
+	}{
+		{
+			format: feed.Atom,
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>urn:namnada-link:feed:alex</id>
+  <title>NAMNADA LINK: alex</title>
+  <updated>2026-01-02T04:04:05Z</updated>
+  <link rel="self" href="https://bot.example/feeds/t"></link>
+  <author>
+    <name>NAMNADA LINK</name>
+  </author>
+  <entry>
+    <id>urn:namnada-link:page:1</id>
+    <title>Go [tips] &amp; tricks</title>
+    <link rel="alternate" href="https://example.com/?a=1&amp;b=2"></link>
+    <published>2026-01-02T03:04:05Z</published>
+    <updated>2026-01-02T04:04:05Z</updated>
+    <author>
+      <name>bob</name>
+    </author>
+    <summary>All about &lt;Go&gt;</summary>
+    <category term="go"></category>
+  </entry>
+  <entry>
+    <id>urn:namnada-link:page:2</id>
+    <title>https://example.org</title>
+    <link rel="alternate" href="https://example.org"></link>
+    <published>2026-01-02T03:04:05Z</published>
+    <updated>2026-01-02T03:04:05Z</updated>
+  </entry>
+</feed>
+`,
+		},
+		{
+			format: feed.RSS,
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>NAMNADA LINK: alex</title>
+    <link>https://bot.example/feeds/t</link>
+    <description>NAMNADA LINK: alex</description>
+    <lastBuildDate>Fri, 02 Jan 2026 04:04:05 +0000</lastBuildDate>
+    <item>
+      <title>Go [tips] &amp; tricks</title>
+      <link>https://example.com/?a=1&amp;b=2</link>
+      <guid isPermaLink="false">urn:namnada-link:page:1</guid>
+      <pubDate>Fri, 02 Jan 2026 03:04:05 +0000</pubDate>
+      <description>All about &lt;Go&gt;</description>
+      <category>go</category>
+    </item>
+    <item>
+      <title>https://example.org</title>
+      <link>https://example.org</link>
+      <guid isPermaLink="false">urn:namnada-link:page:2</guid>
+      <pubDate>Fri, 02 Jan 2026 03:04:05 +0000</pubDate>
+    </item>
+  </channel>
+</rss>
+`,
+		},
+		{
+			format: feed.JSON,
+			want: `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "NAMNADA LINK: alex",
+  "feed_url": "https://bot.example/feeds/t",
+  "items": [
+    {
+      "id": "urn:namnada-link:page:1",
+      "url": "https://example.com/?a=1&b=2",
+      "title": "Go [tips] & tricks",
+      "content_text": "All about <Go>",
+      "date_published": "2026-01-02T03:04:05Z",
+      "date_modified": "2026-01-02T04:04:05Z",
+      "authors": [
+        {
+          "name": "bob"
+        }
+      ],
+      "tags": [
+        "go"
+      ]
+    },
+    {
+      "id": "urn:namnada-link:page:2",
+      "url": "https://example.org",
+      "title": "https://example.org",
+      "content_text": "https://example.org",
+      "date_published": "2026-01-02T03:04:05Z",
+      "date_modified": "2026-01-02T03:04:05Z"
+    }
+  ]
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			err := feed.Write(&buf, tt.format, f)
+			if err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Write() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	err := feed.Write(&bytes.Buffer{}, "opml", f)
+	if !errors.Is(err, feed.ErrUnknownFormat) {
+		t.Errorf("Write() of an unknown format error = %v, want %v", err, feed.ErrUnknownFormat)
+	}
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		base       string
+		format     feed.Format
+		collection string
+		want       string
+	}{
+		{base: "https://bot.example", format: feed.Atom, want: "https://bot.example/feeds/abc/atom"},
+		{base: "https://bot.example/links/", format: feed.RSS, want: "https://bot.example/links/feeds/abc/rss"},
+		{base: "https://bot.example", format: feed.JSON, collection: "Deep Work", want: "https://bot.example/feeds/abc/json?collection=Deep+Work"},
+	}
+
+	for _, tt := range tests {
+		if got := feed.URL(tt.base, "abc", tt.format, tt.collection); got != tt.want {
+			t.Errorf("URL(%q, %q, %q) = %q, want %q", tt.base, tt.format, tt.collection, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package feed
+
+import (
+	"URLbot/pkg/storage"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// maxItems is the largest number of pages in a feed. Feeds of a whole list
+// contain the newest pages.
+const maxItems = 100
+
+// shutdownTimeout is how long Run waits for open requests when it stops.
+const shutdownTimeout = 5 * time.Second
+
+// Server serves the feeds of reading lists. Every user has a single secret
+// token, so feed URLs work in feed readers without any other authentication:
+//
+//	GET /feeds/{token}/{atom|rss|json}[?collection=<name>]
+//
+// Unknown tokens, formats and collections all get 404 Not Found.
+type Server struct {
+	storage storage.Storage
+	baseURL string
+}
+
+// NewServer creates a feed server. baseURL is the public address of the
+// server, used for the self links in the feeds.
+func NewServer(s storage.Storage, baseURL string) *Server {
+	return &Server{storage: s, baseURL: baseURL}
+}
+
+// Handler returns the HTTP handler of the server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /feeds/{token}/{format}", s.serveFeed)
+
+	return mux
+}
+
+// Run listens on addr until ctx is cancelled, then shuts the server down gracefully.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(shutdownCtx)
+		if err != nil {
+			slog.Warn("Feed server shutdown failed", "err", err)
+		}
+	}()
+
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// serveFeed writes the feed of a user's list or of one of their collections.
+func (s *Server) serveFeed(w http.ResponseWriter, r *http.Request) {
+	format, err := ParseFormat(r.PathValue("format"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	token := r.PathValue("token")
+
+	owner, err := s.storage.FeedOwner(storage.HashToken(token))
+	if err != nil {
+		if !errors.Is(err, storage.ErrNoFeedToken) {
+			s.fail(w, "Failed to look up feed token", err)
+			return
+		}
+
+		http.NotFound(w, r)
+		return
+	}
+
+	collection := r.URL.Query().Get("collection")
+
+	var feed *Feed
+	if collection == "" {
+		feed, err = s.listFeed(owner)
+	} else {
+		feed, err = s.collectionFeed(owner, collection)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrNoCollection) {
+			http.NotFound(w, r)
+			return
+		}
+
+		s.fail(w, "Failed to build feed", err)
+		return
+	}
+
+	feed.Link = URL(s.baseURL, token, format, collection)
+
+	var buf bytes.Buffer
+
+	err = Write(&buf, format, feed)
+	if err != nil {
+		s.fail(w, "Failed to write feed", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	_, _ = w.Write(buf.Bytes())
+}
+
+// listFeed builds the feed of the newest pages of an owner.
+func (s *Server) listFeed(owner string) (*Feed, error) {
+	pages, err := s.storage.List(owner)
+	if err != nil && !errors.Is(err, storage.ErrNoPagesFound) {
+		return nil, err
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].CreatedAt.After(pages[j].CreatedAt) })
+	if len(pages) > maxItems {
+		pages = pages[:maxItems]
+	}
+
+	return &Feed{
+		ID:      "urn:namnada-link:feed:" + url.PathEscape(owner),
+		Title:   "NAMNADA LINK: " + owner,
+		Updated: lastUpdated(pages),
+		Pages:   pages,
+	}, nil
+}
+
+// collectionFeed builds the feed of one of the owner's collections,
+// with pages in reading order.
+func (s *Server) collectionFeed(owner, name string) (*Feed, error) {
+	c, err := s.storage.Collection(owner, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []*storage.Page
+	for _, id := range c.PageIDs {
+		if len(pages) == maxItems {
+			break
+		}
+
+		page, err := s.storage.Get(owner, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoPagesFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		pages = append(pages, page)
+	}
+
+	return &Feed{
+		ID:      "urn:namnada-link:feed:" + url.PathEscape(owner) + ":" + url.PathEscape(c.Name),
+		Title:   "NAMNADA LINK: " + c.Name,
+		Updated: lastUpdated(pages),
+		Pages:   pages,
+	}, nil
+}
+
+// fail logs an internal error and answers with 500 Internal Server Error.
+func (s *Server) fail(w http.ResponseWriter, msg string, err error) {
+	slog.Error(msg, "err", err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// lastUpdated returns when any of the pages last changed,
+// or the Unix epoch for an empty feed.
+func lastUpdated(pages []*storage.Page) time.Time {
+	res := time.Unix(0, 0)
+	for _, p := range pages {
+		if updated := pageUpdated(p); updated.After(res) {
+			res = updated
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,106 @@
+package feed_test
+
+import (
+	"URLbot/pkg/feed"
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	s := memory.New()
+
+	saved := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i, u := range []string{"https://a.com", "https://b.com", "https://c.com"} {
+		page := &storage.Page{URL: u, UserName: "alex", CreatedAt: saved.Add(time.Duration(i) * time.Hour)}
+		if err := s.Save(page); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+	if _, err := s.CreateCollection("alex", "Deep Work"); err != nil {
+		t.Fatalf("CreateCollection() failed: %v", err)
+	}
+	err := s.UpdateCollection("alex", "alex", "Deep Work", func(c *storage.Collection) { c.PageIDs = []int{3, 1} })
+	if err != nil {
+		t.Fatalf("UpdateCollection() failed: %v", err)
+	}
+	if err := s.SetFeedToken("alex", storage.HashToken("secret")); err != nil {
+		t.Fatalf("SetFeedToken() failed: %v", err)
+	}
+
+	srv := httptest.NewServer(feed.NewServer(s, "https://bot.example").Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantType string
+		wantURLs []string
+	}{
+		{
+			name:     "whole list, newest first",
+			path:     "/feeds/secret/json",
+			wantCode: http.StatusOK,
+			wantType: "application/feed+json",
+			wantURLs: []string{"https://c.com", "https://b.com", "https://a.com"},
+		},
+		{
+			name:     "collection in reading order",
+			path:     "/feeds/secret/json?collection=deep+work",
+			wantCode: http.StatusOK,
+			wantType: "application/feed+json",
+			wantURLs: []string{"https://c.com", "https://a.com"},
+		},
+		{name: "atom", path: "/feeds/secret/atom", wantCode: http.StatusOK, wantType: "application/atom+xml"},
+		{name: "rss", path: "/feeds/secret/rss", wantCode: http.StatusOK, wantType: "application/rss+xml"},
+		{name: "wrong token", path: "/feeds/guess/atom", wantCode: http.StatusNotFound},
+		{name: "unknown format", path: "/feeds/secret/opml", wantCode: http.StatusNotFound},
+		{name: "unknown collection", path: "/feeds/secret/rss?collection=Nope", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatalf("GET %s failed: %v", tt.path, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("GET %s status = %d, want %d", tt.path, resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantType == "" {
+				return
+			}
+			if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("GET %s Content-Type = %q, want %q", tt.path, got, tt.wantType)
+			}
+			if tt.wantURLs == nil {
+				return
+			}
+
+			var body struct {
+				Items []struct {
+					URL string `json:"url"`
+				} `json:"items"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding the feed failed: %v", err)
+			}
+
+			var got []string
+			for _, item := range body.Items {
+				got = append(got, item.URL)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.wantURLs, " ") {
+				t.Errorf("GET %s items = %v, want %v", tt.path, got, tt.wantURLs)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+// Package feedpoll follows RSS and Atom feeds that users subscribe to and
+// saves their new items into the users' reading lists.
+package feedpoll
+
+import (
+	"URLbot/pkg/safehttp"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotAFeed = errors.New("not an RSS or Atom feed")
+	ErrTooLarge = errors.New("feed is too large")
+)
+
+// userAgent identifies the bot to the feed servers it polls.
+const userAgent = "NamnadaLinkBot/1.0 (+https://github.com/namnadaa/namnada-link)"
+
+// Feed is a parsed RSS or Atom feed.
+type Feed struct {
+	Title string
+	Items []Item // Oldest first.
+}
+
+// Item is a single entry of a feed.
+type Item struct {
+	ID        string // GUID or Atom ID, the link if the feed has neither.
+	URL       string
+	Title     string
+	Published time.Time // Zero if the feed does not date its items.
+}
+
+// Result is the response to a conditional feed request.
+type Result struct {
+	NotModified  bool   // The feed has not changed since ETag or LastModified.
+	ETag         string // Validators to send with the next request.
+	LastModified string
+	Feed         *Feed // Nil if NotModified is set.
+}
+
+// Fetcher downloads feeds with a timeout and a size cap. It only connects
+// to public addresses, see package safehttp.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewFetcher creates a Fetcher. Requests time out after timeout,
+// and feeds larger than maxBytes are rejected with ErrTooLarge.
+func NewFetcher(timeout time.Duration, maxBytes int64) *Fetcher {
+	return &Fetcher{
+		client:   safehttp.NewClient(timeout),
+		maxBytes: maxBytes,
+	}
+}
+
+// SetTransport replaces the guarded transport, e.g. with one that also
+// allows a local test server.
+func (f *Fetcher) SetTransport(t http.RoundTripper) {
+	f.client.Transport = t
+}
+
+// Fetch downloads and parses the feed at feedURL. The etag and lastModified
+// of a previous response make the request conditional, so an unchanged feed
+// costs the server only a 304 Not Modified. Relative item links are resolved
+// against the final feed URL.
+func (f *Fetcher) Fetch(ctx context.Context, feedURL, etag, lastModified string) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %v", err)
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.5")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request execution failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &Result{NotModified: true, ETag: etag, LastModified: lastModified}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read response failed: %v", err)
+	}
+
+	if int64(len(body)) > f.maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	feed, err := Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range feed.Items {
+		feed.Items[i].URL = resolve(resp.Request.URL, item.URL)
+	}
+
+	return &Result{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Feed:         feed,
+	}, nil
+}
+
+// resolve returns link as an absolute URL relative to base.
+func resolve(base *url.URL, link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	return base.ResolveReference(u).String()
+}
+
+// document holds the elements of both RSS 2.0 and Atom documents.
+// Which of them are filled depends on the root element.
+type document struct {
+	XMLName xml.Name
+
+	// RSS 2.0.
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+
+	// Atom.
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	GUID    string `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+// link returns the address of the entry: its alternate link,
+// or the first link if none is marked as alternate.
+func (e atomEntry) link() string {
+	for _, l := range e.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(e.Links) > 0 {
+		return e.Links[0].Href
+	}
+
+	return ""
+}
+
+// Parse parses an RSS 2.0 or Atom document. Items without a link are
+// skipped. Items are returned oldest first: by date when every item has one,
+// otherwise in reverse document order, as feeds list the newest items first.
+func Parse(data []byte) (*Feed, error) {
+	var doc document
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charsetReader
+
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotAFeed, err)
+	}
+
+	feed := &Feed{}
+
+	switch {
+	case doc.XMLName.Local == "rss":
+		feed.Title = strings.TrimSpace(doc.Channel.Title)
+		for _, it := range doc.Channel.Items {
+			feed.Items = append(feed.Items, newItem(it.GUID, it.Link, it.Title, it.PubDate))
+		}
+	case doc.XMLName.Local == "feed" && doc.XMLName.Space == "http://www.w3.org/2005/Atom":
+		feed.Title = strings.TrimSpace(doc.Title)
+		for _, e := range doc.Entries {
+			published := e.Published
+			if published == "" {
+				published = e.Updated
+			}
+			feed.Items = append(feed.Items, newItem(e.ID, e.link(), e.Title, published))
+		}
+	default:
+		return nil, ErrNotAFeed
+	}
+
+	items := feed.Items[:0]
+	dated := true
+	for _, it := range feed.Items {
+		if it.URL == "" {
+			continue
+		}
+		if it.Published.IsZero() {
+			dated = false
+		}
+		items = append(items, it)
+	}
+
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	if dated {
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Published.Before(items[j].Published) })
+	}
+
+	feed.Items = items
+
+	return feed, nil
+}
+
+// newItem builds an Item from the raw element values of a feed.
+func newItem(id, link, title, published string) Item {
+	it := Item{
+		ID:        strings.TrimSpace(id),
+		URL:       strings.TrimSpace(link),
+		Title:     strings.TrimSpace(title),
+		Published: parseDate(published),
+	}
+	if it.ID == "" {
+		it.ID = it.URL
+	}
+
+	return it
+}
+
+// dateLayouts are the date formats found in feeds: RFC 822 variants
+// in RSS and RFC 3339 in Atom.
+var dateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// parseDate parses a feed date, returning the zero time if no layout matches.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// charsetReader accepts the declared encodings that are compatible with
+// UTF-8 for the markup that matters. Other encodings are rejected.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	default:
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+}
@@ -0,0 +1,182 @@
+package feedpoll_test
+
+import (
+	"URLbot/pkg/feedpoll"
+	"URLbot/pkg/safehttp"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newFetcher creates a Fetcher that may connect to local test servers.
+func newFetcher(maxBytes int64) *feedpoll.Fetcher {
+	f := feedpoll.NewFetcher(time.Second, maxBytes)
+	f.SetTransport(safehttp.NewTransport(netip.MustParsePrefix("127.0.0.0/8")))
+
+	return f
+}
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Go Blog</title>
+    <item>
+      <title>Second post</title>
+      <link>https://go.dev/blog/second</link>
+      <guid>post-2</guid>
+      <pubDate>Tue, 02 Jan 2024 10:00:00 +0000</pubDate>
+    </item>
+    <item>
+      <title>First post</title>
+      <link>/blog/first</link>
+      <pubDate>Mon, 1 Jan 2024 10:00:00 GMT</pubDate>
+    </item>
+    <item>
+      <title>No link</title>
+    </item>
+  </channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example Atom</title>
+  <entry>
+    <id>urn:entry:1</id>
+    <title>Older</title>
+    <link rel="alternate" href="https://example.com/older"/>
+    <updated>2024-01-01T10:00:00Z</updated>
+  </entry>
+  <entry>
+    <id>urn:entry:2</id>
+    <title>Newer</title>
+    <link rel="edit" href="https://example.com/edit/2"/>
+    <link href="https://example.com/newer"/>
+    <published>2024-01-03T10:00:00Z</published>
+  </entry>
+</feed>`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantTitle string
+		wantItems []feedpoll.Item
+		wantErr   error
+	}{
+		{
+			name:      "rss",
+			data:      rssFeed,
+			wantTitle: "Go Blog",
+			wantItems: []feedpoll.Item{
+				{ID: "/blog/first", URL: "/blog/first", Title: "First post", Published: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+				{ID: "post-2", URL: "https://go.dev/blog/second", Title: "Second post", Published: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:      "atom",
+			data:      atomFeed,
+			wantTitle: "Example Atom",
+			wantItems: []feedpoll.Item{
+				{ID: "urn:entry:1", URL: "https://example.com/older", Title: "Older", Published: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+				{ID: "urn:entry:2", URL: "https://example.com/newer", Title: "Newer", Published: time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:      "undated items in reverse document order",
+			data:      `<rss><channel><item><link>https://a.example/2</link></item><item><link>https://a.example/1</link></item></channel></rss>`,
+			wantItems: []feedpoll.Item{{ID: "https://a.example/1", URL: "https://a.example/1"}, {ID: "https://a.example/2", URL: "https://a.example/2"}},
+		},
+		{name: "html page", data: `<html><body>hello</body></html>`, wantErr: feedpoll.ErrNotAFeed},
+		{name: "not xml", data: `{"version": "https://jsonfeed.org/version/1.1"}`, wantErr: feedpoll.ErrNotAFeed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := feedpoll.Parse([]byte(tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if feed.Title != tt.wantTitle {
+				t.Errorf("Parse() title = %q, want %q", feed.Title, tt.wantTitle)
+			}
+			for i := range feed.Items {
+				feed.Items[i].Published = feed.Items[i].Published.UTC()
+			}
+			if !reflect.DeepEqual(feed.Items, tt.wantItems) {
+				t.Errorf("Parse() items = %+v, want %+v", feed.Items, tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	const etag = `"v1"`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed":
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 10:00:00 GMT")
+			_, _ = w.Write([]byte(rssFeed))
+		case "/page":
+			_, _ = w.Write([]byte("<html><body>hello</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := newFetcher(1 << 20)
+
+	res, err := fetcher.Fetch(context.Background(), server.URL+"/feed", "", "")
+	if err != nil {
+		t.Fatalf("Fetch() failed: %v", err)
+	}
+	if res.NotModified || res.ETag != etag || res.LastModified == "" || len(res.Feed.Items) != 2 {
+		t.Fatalf("Fetch() = %+v, want the parsed feed with its validators", res)
+	}
+	if got, want := res.Feed.Items[0].URL, server.URL+"/blog/first"; got != want {
+		t.Errorf("relative item link = %q, want %q", got, want)
+	}
+
+	res, err = fetcher.Fetch(context.Background(), server.URL+"/feed", etag, "")
+	if err != nil {
+		t.Fatalf("conditional Fetch() failed: %v", err)
+	}
+	if !res.NotModified || res.ETag != etag || res.Feed != nil {
+		t.Errorf("conditional Fetch() = %+v, want not modified", res)
+	}
+
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/page", "", "")
+	if !errors.Is(err, feedpoll.ErrNotAFeed) {
+		t.Errorf("Fetch() of a web page error = %v, want %v", err, feedpoll.ErrNotAFeed)
+	}
+
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/missing", "", "")
+	if err == nil {
+		t.Error("Fetch() of a missing feed succeeded, want an error")
+	}
+
+	_, err = newFetcher(16).Fetch(context.Background(), server.URL+"/feed", "", "")
+	if !errors.Is(err, feedpoll.ErrTooLarge) {
+		t.Errorf("Fetch() over the size cap error = %v, want %v", err, feedpoll.ErrTooLarge)
+	}
+
+	_, err = feedpoll.NewFetcher(time.Second, 1<<20).Fetch(context.Background(), server.URL+"/feed", "", "")
+	if !errors.Is(err, safehttp.ErrForbiddenAddress) {
+		t.Errorf("Fetch() of a local feed error = %v, want %v", err, safehttp.ErrForbiddenAddress)
+	}
+}
@@ -0,0 +1,280 @@
+package feedpoll
+
+import (
+	"URLbot/pkg/storage"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// maxSeen is how many item IDs a subscription remembers beyond the items
+// currently in its feed, so items that briefly drop out are not saved again.
+const maxSeen = 500
+
+// Store is the part of storage.Storage used by the poller.
+type Store interface {
+	Save(p *storage.Page) error
+	IsExists(p *storage.Page) (bool, error)
+	Subscribe(s *storage.Subscription) error
+	Subscriptions(owner string) ([]*storage.Subscription, error)
+	AllSubscriptions() ([]*storage.Subscription, error)
+	UpdateSubscription(owner, feedURL string, fn func(s *storage.Subscription)) error
+}
+
+// Notifier tells a user about pages saved from their subscriptions.
+type Notifier interface {
+	NotifyNewItems(chatID int, owner string, pages []*storage.Page) error
+}
+
+// Enqueuer schedules newly saved pages for background processing,
+// such as fetching their metadata.
+type Enqueuer interface {
+	Enqueue(p *storage.Page)
+}
+
+// Poller checks subscribed feeds for new items. Each subscription is polled
+// at most once per interval; its state lives in the storage, next to the
+// subscription.
+type Poller struct {
+	fetcher  *Fetcher
+	store    Store
+	interval time.Duration
+	notifier Notifier
+	enricher Enqueuer
+}
+
+// NewPoller creates a Poller that polls every subscription in store once per interval.
+func NewPoller(fetcher *Fetcher, store Store, interval time.Duration) *Poller {
+	return &Poller{
+		fetcher:  fetcher,
+		store:    store,
+		interval: interval,
+	}
+}
+
+// SetNotifier sets who tells users about saved items. Without it items
+// are saved silently.
+func (p *Poller) SetNotifier(n Notifier) {
+	p.notifier = n
+}
+
+// SetEnricher sets the pipeline that fetches metadata of saved items.
+func (p *Poller) SetEnricher(e Enqueuer) {
+	p.enricher = e
+}
+
+// Subscribe fetches the feed of sub and stores the subscription, filling in
+// the feed title and validators. Items already in the feed are marked as seen,
+// so only items published from now on are saved. It returns
+// storage.ErrSubscriptionExists if the owner already follows the feed.
+func (p *Poller) Subscribe(ctx context.Context, sub *storage.Subscription) error {
+	subs, err := p.store.Subscriptions(sub.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %v", err)
+	}
+
+	for _, s := range subs {
+		if s.FeedURL == sub.FeedURL {
+			return storage.ErrSubscriptionExists
+		}
+	}
+
+	res, err := p.fetcher.Fetch(ctx, sub.FeedURL, "", "")
+	if err != nil {
+		return err
+	}
+
+	sub.Title = res.Feed.Title
+	sub.ETag, sub.LastModified = res.ETag, res.LastModified
+	sub.Seen = seenIDs(nil, res.Feed.Items)
+	sub.CheckedAt = time.Now()
+
+	return p.store.Subscribe(sub)
+}
+
+// batchKey identifies the recipient of a notification.
+type batchKey struct {
+	chatID int
+	owner  string
+}
+
+// PollDue polls the subscriptions that were last checked at least one interval
+// before now. New items of all feeds are announced to each user in a single
+// message. It matches scheduler.JobFunc.
+func (p *Poller) PollDue(ctx context.Context, now time.Time) error {
+	subs, err := p.store.AllSubscriptions()
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %v", err)
+	}
+
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].Owner != subs[j].Owner {
+			return subs[i].Owner < subs[j].Owner
+		}
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+
+	var keys []batchKey
+	batches := make(map[batchKey][]*storage.Page)
+
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if now.Before(sub.CheckedAt.Add(p.interval)) {
+			continue
+		}
+
+		pages := p.poll(ctx, sub, now)
+		if len(pages) == 0 {
+			continue
+		}
+
+		key := batchKey{chatID: sub.ChatID, owner: sub.Owner}
+		if _, ok := batches[key]; !ok {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], pages...)
+	}
+
+	if p.notifier == nil {
+		return nil
+	}
+
+	for _, key := range keys {
+		err := p.notifier.NotifyNewItems(key.chatID, key.owner, batches[key])
+		if err != nil {
+			slog.Error("feedpoll: failed to notify", "owner", key.owner, "chat_id", key.chatID, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// poll fetches the feed of a subscription, saves its unseen items and records
+// the outcome. It returns the pages saved.
+func (p *Poller) poll(ctx context.Context, sub *storage.Subscription, now time.Time) []*storage.Page {
+	res, err := p.fetcher.Fetch(ctx, sub.FeedURL, sub.ETag, sub.LastModified)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	if err != nil {
+		slog.Warn("feedpoll: fetch failed", "owner", sub.Owner, "feed", sub.FeedURL, "err", err)
+		p.record(sub, now, func(s *storage.Subscription) { s.LastError = err.Error() })
+		return nil
+	}
+
+	if res.NotModified {
+		p.record(sub, now, func(s *storage.Subscription) { s.LastError = "" })
+		return nil
+	}
+
+	seen := make(map[string]bool, len(sub.Seen))
+	for _, id := range sub.Seen {
+		seen[id] = true
+	}
+
+	var saved []*storage.Page
+	for _, item := range res.Feed.Items {
+		if seen[item.ID] {
+			continue
+		}
+
+		page, err := p.save(sub, item)
+		if err != nil {
+			// Items saved so far are skipped as duplicates on the next poll.
+			slog.Error("feedpoll: failed to save item", "owner", sub.Owner, "url", item.URL, "err", err)
+			p.record(sub, now, func(s *storage.Subscription) { s.LastError = err.Error() })
+			return saved
+		}
+
+		if page != nil {
+			saved = append(saved, page)
+		}
+	}
+
+	p.record(sub, now, func(s *storage.Subscription) {
+		if res.Feed.Title != "" {
+			s.Title = res.Feed.Title
+		}
+		s.ETag, s.LastModified = res.ETag, res.LastModified
+		s.Seen = seenIDs(s.Seen, res.Feed.Items)
+		s.LastError = ""
+	})
+
+	return saved
+}
+
+// save stores a feed item in the owner's list, tagged with the subscription
+// tag. It returns nil if the owner has already saved the URL.
+func (p *Poller) save(sub *storage.Subscription, item Item) (*storage.Page, error) {
+	page := &storage.Page{
+		URL:      item.URL,
+		UserName: sub.Owner,
+		Title:    item.Title,
+	}
+	if sub.Tag != "" {
+		page.Tags = []string{sub.Tag}
+	}
+
+	exists, err := p.store.IsExists(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if the page exists: %v", err)
+	}
+
+	if exists {
+		return nil, nil
+	}
+
+	err = p.store.Save(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save page: %v", err)
+	}
+
+	if p.enricher != nil {
+		p.enricher.Enqueue(page)
+	}
+
+	return page, nil
+}
+
+// record stores the outcome of a poll on the subscription.
+func (p *Poller) record(sub *storage.Subscription, now time.Time, fn func(s *storage.Subscription)) {
+	err := p.store.UpdateSubscription(sub.Owner, sub.FeedURL, func(s *storage.Subscription) {
+		s.CheckedAt = now
+		fn(s)
+	})
+	if err != nil {
+		slog.Error("feedpoll: failed to update subscription", "owner", sub.Owner, "feed", sub.FeedURL, "err", err)
+	}
+}
+
+// seenIDs returns the IDs to remember after a poll: the previously seen IDs
+// that are no longer in the feed, followed by the IDs of the current items,
+// trimmed from the oldest to maxSeen beyond the current items.
+func seenIDs(prev []string, items []Item) []string {
+	current := make(map[string]bool, len(items))
+	for _, it := range items {
+		current[it.ID] = true
+	}
+
+	var res []string
+	for _, id := range prev {
+		if !current[id] {
+			res = append(res, id)
+		}
+	}
+	for _, it := range items {
+		res = append(res, it.ID)
+	}
+
+	if limit := maxSeen + len(items); len(res) > limit {
+		res = res[len(res)-limit:]
+	}
+
+	return res
+}
@@ -0,0 +1,212 @@
+package feedpoll_test
+
+import (
+	"URLbot/pkg/feedpoll"
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// feedServer serves an RSS and an Atom feed whose items can change between
+// polls. Both support conditional requests with ETag.
+type feedServer struct {
+	mu          sync.Mutex
+	items       []string // Item paths, oldest first.
+	broken      bool
+	notModified int
+}
+
+func (s *feedServer) add(paths ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = append(s.items, paths...)
+}
+
+func (s *feedServer) setBroken(broken bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.broken = broken
+}
+
+func (s *feedServer) notModifiedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.notModified
+}
+
+func (s *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.broken {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%d"`, len(s.items))
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+
+	var b strings.Builder
+	switch r.URL.Path {
+	case "/rss":
+		b.WriteString(`<rss version="2.0"><channel><title>RSS</title>`)
+		for i := len(s.items) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, `<item><title>rss %s</title><link>/rss%s</link></item>`, s.items[i], s.items[i])
+		}
+		b.WriteString(`</channel></rss>`)
+	case "/atom":
+		b.WriteString(`<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title>`)
+		for i := len(s.items) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, `<entry><id>atom%s</id><title>atom %s</title><link href="/atom%s"/></entry>`, s.items[i], s.items[i], s.items[i])
+		}
+		b.WriteString(`</feed>`)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// notification is a single call of NotifyNewItems.
+type notification struct {
+	chatID int
+	owner  string
+	urls   []string
+}
+
+type mockNotifier struct {
+	sent []notification
+}
+
+func (m *mockNotifier) NotifyNewItems(chatID int, owner string, pages []*storage.Page) error {
+	n := notification{chatID: chatID, owner: owner}
+	for _, p := range pages {
+		n.urls = append(n.urls, p.URL)
+	}
+	m.sent = append(m.sent, n)
+
+	return nil
+}
+
+func TestPoller(t *testing.T) {
+	const interval = time.Hour
+
+	feeds := &feedServer{items: []string{"/a"}}
+	server := httptest.NewServer(feeds)
+	defer server.Close()
+
+	s := memory.New()
+	notifier := &mockNotifier{}
+
+	poller := feedpoll.NewPoller(newFetcher(1<<20), s, interval)
+	poller.SetNotifier(notifier)
+
+	ctx := context.Background()
+
+	for _, sub := range []*storage.Subscription{
+		{Owner: "Alex", ChatID: 1, FeedURL: server.URL + "/rss", Tag: "news"},
+		{Owner: "Alex", ChatID: 1, FeedURL: server.URL + "/atom"},
+	} {
+		err := poller.Subscribe(ctx, sub)
+		if err != nil {
+			t.Fatalf("Subscribe(%s) failed: %v", sub.FeedURL, err)
+		}
+	}
+
+	err := poller.Subscribe(ctx, &storage.Subscription{Owner: "Alex", FeedURL: server.URL + "/rss"})
+	if !errors.Is(err, storage.ErrSubscriptionExists) {
+		t.Errorf("Subscribe() twice error = %v, want %v", err, storage.ErrSubscriptionExists)
+	}
+
+	err = poller.Subscribe(ctx, &storage.Subscription{Owner: "Alex", FeedURL: server.URL + "/missing"})
+	if err == nil {
+		t.Error("Subscribe() to a missing feed succeeded, want an error")
+	}
+
+	subs, err := s.Subscriptions("Alex")
+	if err != nil || len(subs) != 2 || subs[0].Title != "RSS" || subs[1].Title != "Atom" {
+		t.Fatalf("Subscriptions() = %+v, %v; want both feeds with their titles", subs, err)
+	}
+
+	// Items published before subscribing are not saved.
+	feeds.add("/b", "/c")
+	if err := s.Save(&storage.Page{URL: server.URL + "/rss/c", UserName: "Alex"}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	now := time.Now()
+
+	if err := poller.PollDue(ctx, now); err != nil {
+		t.Fatalf("PollDue() failed: %v", err)
+	}
+	if len(notifier.sent) != 0 {
+		t.Fatalf("PollDue() before the interval sent %+v, want nothing", notifier.sent)
+	}
+
+	now = now.Add(interval)
+
+	if err := poller.PollDue(ctx, now); err != nil {
+		t.Fatalf("PollDue() failed: %v", err)
+	}
+
+	want := []string{server.URL + "/rss/b", server.URL + "/atom/b", server.URL + "/atom/c"}
+	if len(notifier.sent) != 1 || notifier.sent[0].chatID != 1 || notifier.sent[0].owner != "Alex" ||
+		strings.Join(notifier.sent[0].urls, " ") != strings.Join(want, " ") {
+		t.Fatalf("notifications = %+v, want one batch with %v", notifier.sent, want)
+	}
+
+	pages, err := s.List("Alex")
+	if err != nil || len(pages) != 4 {
+		t.Fatalf("List() = %d pages, %v; want 4", len(pages), err)
+	}
+	for _, p := range pages {
+		if p.URL == server.URL+"/rss/b" && (p.Title != "rss /b" || len(p.Tags) != 1 || p.Tags[0] != "news") {
+			t.Errorf("saved item = %+v, want its feed title and the subscription tag", p)
+		}
+	}
+
+	// An unchanged feed is answered with 304 and nothing is saved.
+	now = now.Add(interval)
+
+	if err := poller.PollDue(ctx, now); err != nil {
+		t.Fatalf("PollDue() failed: %v", err)
+	}
+	if got := feeds.notModifiedCount(); got != 2 || len(notifier.sent) != 1 {
+		t.Errorf("unchanged poll: %d not modified responses, %d notifications; want 2 and 1", got, len(notifier.sent))
+	}
+
+	// Fetch errors are recorded on the subscription.
+	feeds.setBroken(true)
+	now = now.Add(interval)
+
+	if err := poller.PollDue(ctx, now); err != nil {
+		t.Fatalf("PollDue() failed: %v", err)
+	}
+
+	subs, err = s.Subscriptions("Alex")
+	if err != nil {
+		t.Fatalf("Subscriptions() failed: %v", err)
+	}
+	for _, sub := range subs {
+		if sub.LastError == "" || !sub.CheckedAt.Equal(now) {
+			t.Errorf("subscription after a failed poll = %+v, want the error recorded", sub)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package format builds Telegram message text in the HTML and MarkdownV2
+// parse modes, escaping user-supplied text so that it never breaks the markup.
+package format
+
+import (
+	"html"
+	"strings"
+)
+
+// Formatter renders text fragments in one of Telegram's parse modes.
+// Every method escapes its arguments, so they may contain arbitrary user input.
+type Formatter interface {
+	Escape(text string) string
+	Bold(text string) string
+	Italic(text string) string
+	Code(text string) string
+	Pre(text string) string
+	Link(title, url string) string
+}
+
+// HTML formats text for the "HTML" parse mode.
+var HTML Formatter = htmlFormatter{}
+
+// MarkdownV2 formats text for the "MarkdownV2" parse mode.
+var MarkdownV2 Formatter = markdownFormatter{}
+
+type htmlFormatter struct{}
+
+// Escape replaces the characters that have a special meaning in Telegram HTML.
+func (htmlFormatter) Escape(text string) string {
+	return html.EscapeString(text)
+}
+
+// Bold returns the text in bold.
+func (f htmlFormatter) Bold(text string) string {
+	return "<b>" + f.Escape(text) + "</b>"
+}
+
+// Italic returns the text in italics.
+func (f htmlFormatter) Italic(text string) string {
+	return "<i>" + f.Escape(text) + "</i>"
+}
+
+// Code returns the text as inline monospace code.
+func (f htmlFormatter) Code(text string) string {
+	return "<code>" + f.Escape(text) + "</code>"
+}
+
+// Pre returns the text as a preformatted code block.
+func (f htmlFormatter) Pre(text string) string {
+	return "<pre>" + f.Escape(text) + "</pre>"
+}
+
+// Link returns a clickable link with the given title. An empty title shows the URL itself.
+func (f htmlFormatter) Link(title, url string) string {
+	if title == "" {
+		title = url
+	}
+
+	return `<a href="` + f.Escape(url) + `">` + f.Escape(title) + "</a>"
+}
+
+type markdownFormatter struct{}
+
+// markdownSpecial lists the characters that must be escaped anywhere in MarkdownV2 text.
+const markdownSpecial = "_*[]()~`>#+-=|{}.!\\"
+
+// Escape prefixes every MarkdownV2 special character with a backslash.
+func (markdownFormatter) Escape(text string) string {
+	return escapeChars(text, markdownSpecial)
+}
+
+// Bold returns the text in bold.
+func (f markdownFormatter) Bold(text string) string {
+	return "*" + f.Escape(text) + "*"
+}
+
+// Italic returns the text in italics.
+func (f markdownFormatter) Italic(text string) string {
+	return "_" + f.Escape(text) + "_"
+}
+
+// Code returns the text as inline monospace code. Inside code entities
+// only "`" and "\" have to be escaped.
+func (markdownFormatter) Code(text string) string {
+	return "`" + escapeChars(text, "`\\") + "`"
+}
+
+// Pre returns the text as a preformatted code block.
+func (markdownFormatter) Pre(text string) string {
+	return "```\n" + escapeChars(text, "`\\") + "\n```"
+}
+
+// Link returns a clickable link with the given title. Inside the URL part
+// only ")" and "\" have to be escaped.
+func (f markdownFormatter) Link(title, url string) string {
+	if title == "" {
+		title = url
+	}
+
+	return "[" + f.Escape(title) + "](" + escapeChars(url, `)\`) + ")"
+}
+
+// escapeChars prefixes every occurrence of the given characters with a backslash.
+func escapeChars(text, chars string) string {
+	var builder strings.Builder
+	builder.Grow(len(text))
+
+	for _, r := range text {
+		if strings.ContainsRune(chars, r) {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,69 @@
+package format_test
+
+import (
+	"URLbot/pkg/format"
+	"testing"
+)
+
+func TestFormatters(t *testing.T) {
+	const url = "https://example.com/a_b?x=1&y=(2)"
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "html escape",
+			got:  format.HTML.Escape(`<b>"Tom & Jerry"</b>`),
+			want: "&lt;b&gt;&#34;Tom &amp; Jerry&#34;&lt;/b&gt;",
+		},
+		{
+			name: "html bold",
+			got:  format.HTML.Bold("a<b"),
+			want: "<b>a&lt;b</b>",
+		},
+		{
+			name: "html link",
+			got:  format.HTML.Link("Title & more", url),
+			want: `<a href="https://example.com/a_b?x=1&amp;y=(2)">Title &amp; more</a>`,
+		},
+		{
+			name: "html link without title",
+			got:  format.HTML.Link("", "https://a.com/<x>"),
+			want: `<a href="https://a.com/&lt;x&gt;">https://a.com/&lt;x&gt;</a>`,
+		},
+		{
+			name: "html pre",
+			got:  format.HTML.Pre("if a < b {}"),
+			want: "<pre>if a &lt; b {}</pre>",
+		},
+		{
+			name: "markdown escape",
+			got:  format.MarkdownV2.Escape("1.5 * (2-1) = 1.5!"),
+			want: `1\.5 \* \(2\-1\) \= 1\.5\!`,
+		},
+		{
+			name: "markdown link",
+			got:  format.MarkdownV2.Link("my_page", url),
+			want: `[my\_page](https://example.com/a_b?x=1&y=(2\))`,
+		},
+		{
+			name: "markdown code",
+			got:  format.MarkdownV2.Code("a`b_c"),
+			want: "`a\\`b_c`",
+		},
+		{
+			name: "markdown bold",
+			got:  format.MarkdownV2.Bold("v1.2"),
+			want: `*v1\.2*`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+// Package importer reads reading lists exported from other services:
+// Pocket CSV exports, browser bookmark files, this bot's JSON/CSV exports
+// and plain text files with one link per line.
+package importer
+
+import (
+	"URLbot/pkg/storage"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoLinks = errors.New("no links found")
+
+// Format is a detected input format.
+type Format string
+
+// Supported import formats.
+const (
+	CSV       Format = "csv"
+	Bookmarks Format = "bookmarks"
+	JSON      Format = "json"
+	Text      Format = "text"
+)
+
+// Result is the outcome of parsing an import file. Pages are returned without
+// an owner; Invalid counts the entries that did not contain a valid link.
+type Result struct {
+	Format  Format
+	Pages   []*storage.Page
+	Invalid int
+}
+
+// Parse detects the format of data and extracts the links from it.
+func Parse(data []byte) (*Result, error) {
+	var res *Result
+	var err error
+
+	switch f := Detect(data); f {
+	case Bookmarks:
+		res = parseBookmarks(data)
+	case JSON:
+		res, err = parseJSON(data)
+	case CSV:
+		res, err = parseCSV(data)
+	default:
+		res = parseText(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Pages) == 0 && res.Invalid == 0 {
+		return nil, ErrNoLinks
+	}
+
+	return res, nil
+}
+
+// Detect guesses the format of an import file from its contents.
+func Detect(data []byte) Format {
+	head := strings.ToLower(string(data[:min(len(data), 1024)]))
+	trimmed := strings.TrimSpace(head)
+
+	switch {
+	case strings.Contains(head, "netscape-bookmark-file") || strings.Contains(head, "<dt><a "):
+		return Bookmarks
+	case strings.HasPrefix(trimmed, "["):
+		return JSON
+	}
+
+	firstLine, _, _ := strings.Cut(trimmed, "\n")
+	for _, col := range strings.Split(firstLine, ",") {
+		if strings.Trim(strings.TrimSpace(col), `"`) == "url" {
+			return CSV
+		}
+	}
+
+	return Text
+}
+
+// parseCSV reads a CSV file with a header row that has a "url" column, such as
+// a Pocket export (title,url,time_added,tags,status) or this bot's own export.
+func parseCSV(data []byte) (*Result, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := cols[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	res := &Result{Format: CSV}
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			res.Invalid++
+			continue
+		}
+
+		page, ok := newPage(field(record, "url"))
+		if !ok {
+			res.Invalid++
+			continue
+		}
+
+		page.Title = title(field(record, "title"), page.URL)
+		page.Tags = splitTags(field(record, "tags"), "|")
+		page.Read = field(record, "status") == "archive" || field(record, "read") == "true"
+		page.CreatedAt = parseTime(field(record, "time_added"))
+		if page.CreatedAt.IsZero() {
+			page.CreatedAt = parseTime(field(record, "created_at"))
+		}
+		if page.Read {
+			page.ReadAt = parseTime(field(record, "read_at"))
+		}
+
+		res.Pages = append(res.Pages, page)
+	}
+
+	return res, nil
+}
+
+var (
+	anchorRe = regexp.MustCompile(`(?is)<a\s([^>]*)>(.*?)</a>`)
+	attrRe   = regexp.MustCompile(`(?is)([a-z_]+)\s*=\s*"([^"]*)"`)
+)
+
+// parseBookmarks reads a Netscape bookmark file exported by a browser.
+func parseBookmarks(data []byte) *Result {
+	res := &Result{Format: Bookmarks}
+
+	for _, m := range anchorRe.FindAllSubmatch(data, -1) {
+		attrs := make(map[string]string)
+		for _, a := range attrRe.FindAllSubmatch(m[1], -1) {
+			attrs[strings.ToLower(string(a[1]))] = html.UnescapeString(string(a[2]))
+		}
+
+		page, ok := newPage(attrs["href"])
+		if !ok {
+			res.Invalid++
+			continue
+		}
+
+		page.Title = title(html.UnescapeString(string(m[2])), page.URL)
+		page.Tags = splitTags(attrs["tags"], ",")
+		page.CreatedAt = parseTime(attrs["add_date"])
+		page.Read = attrs["read"] == "true"
+
+		res.Pages = append(res.Pages, page)
+	}
+
+	return res
+}
+
+// jsonPage is an entry of this bot's JSON export.
+type jsonPage struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Read      bool      `json:"read"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+// parseJSON reads a JSON array in the format produced by the exporter package.
+func parseJSON(data []byte) (*Result, error) {
+	var items []jsonPage
+
+	err := json.Unmarshal(data, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{Format: JSON}
+
+	for _, item := range items {
+		page, ok := newPage(item.URL)
+		if !ok {
+			res.Invalid++
+			continue
+		}
+
+		page.Title = title(item.Title, page.URL)
+		page.Read = item.Read
+		page.Tags = item.Tags
+		page.CreatedAt = item.CreatedAt
+		page.ReadAt = item.ReadAt
+
+		res.Pages = append(res.Pages, page)
+	}
+
+	return res, nil
+}
+
+// parseText reads a plain text file. Every link found is imported, and every
+// non-empty line without a link is counted as invalid.
+func parseText(data []byte) *Result {
+	res := &Result{Format: Text}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		found := false
+
+		for _, field := range strings.Fields(line) {
+			if page, ok := newPage(field); ok {
+				res.Pages = append(res.Pages, page)
+				found = true
+			}
+		}
+
+		if !found && strings.TrimSpace(line) != "" {
+			res.Invalid++
+		}
+	}
+
+	return res
+}
+
+// newPage returns a page for rawURL if it is a valid http(s) link.
+func newPage(rawURL string) (*storage.Page, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, false
+	}
+
+	return &storage.Page{URL: u.String()}, true
+}
+
+// title returns the trimmed title, or an empty string if it only repeats the URL.
+func title(s, pageURL string) string {
+	s = strings.TrimSpace(s)
+	if s == pageURL {
+		return ""
+	}
+
+	return s
+}
+
+// splitTags splits a tag list and drops empty entries.
+func splitTags(s, sep string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, sep) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
+// parseTime parses a Unix timestamp or an RFC 3339 time, returning the zero time on failure.
+func parseTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC()
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
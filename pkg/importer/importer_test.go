@@ -0,0 +1,106 @@
+package importer_test
+
+import (
+	"URLbot/pkg/importer"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantFormat  importer.Format
+		wantURLs    []string
+		wantTitle   string
+		wantTags    []string
+		wantRead    bool
+		wantCreated time.Time
+		wantInvalid int
+		wantErr     error
+	}{
+		{
+			name: "pocket csv",
+			data: "title,url,time_added,cursor,tags,status\n" +
+				"Go,https://go.dev,1700000000,1,go|lang,archive\n" +
+				"Bad,ftp://files.example.com,1700000000,2,,unread\n",
+			wantFormat:  importer.CSV,
+			wantURLs:    []string{"https://go.dev"},
+			wantTitle:   "Go",
+			wantTags:    []string{"go", "lang"},
+			wantRead:    true,
+			wantCreated: time.Unix(1700000000, 0).UTC(),
+			wantInvalid: 1,
+		},
+		{
+			name: "browser bookmarks",
+			data: "<!DOCTYPE NETSCAPE-Bookmark-file-1>\n<DL><p>\n" +
+				`<DT><A HREF="https://example.com/?a=1&amp;b=2" ADD_DATE="1700000000" TAGS="news">Example</A>` + "\n" +
+				`<DT><A HREF="javascript:void(0)">Bookmarklet</A>` + "\n",
+			wantFormat:  importer.Bookmarks,
+			wantURLs:    []string{"https://example.com/?a=1&b=2"},
+			wantTitle:   "Example",
+			wantTags:    []string{"news"},
+			wantCreated: time.Unix(1700000000, 0).UTC(),
+			wantInvalid: 1,
+		},
+		{
+			name:        "bot json export",
+			data:        `[{"url": "https://a.com", "read": true, "tags": ["x"], "created_at": "2026-01-02T03:04:05Z"}]`,
+			wantFormat:  importer.JSON,
+			wantURLs:    []string{"https://a.com"},
+			wantTags:    []string{"x"},
+			wantRead:    true,
+			wantCreated: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name:        "plain text",
+			data:        "https://a.com\n\nsee https://b.com and https://c.com\njust words\n",
+			wantFormat:  importer.Text,
+			wantURLs:    []string{"https://a.com", "https://b.com", "https://c.com"},
+			wantInvalid: 1,
+		},
+		{
+			name:    "empty file",
+			data:    "\n\n",
+			wantErr: importer.ErrNoLinks,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importer.Parse([]byte(tt.data))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			if got.Format != tt.wantFormat {
+				t.Errorf("format = %q, want %q", got.Format, tt.wantFormat)
+			}
+
+			var urls []string
+			for _, p := range got.Pages {
+				urls = append(urls, p.URL)
+			}
+			if !reflect.DeepEqual(urls, tt.wantURLs) {
+				t.Errorf("urls = %v, want %v", urls, tt.wantURLs)
+			}
+
+			if got.Invalid != tt.wantInvalid {
+				t.Errorf("invalid = %d, want %d", got.Invalid, tt.wantInvalid)
+			}
+
+			first := got.Pages[0]
+			if first.Title != tt.wantTitle || !reflect.DeepEqual(first.Tags, tt.wantTags) || first.Read != tt.wantRead || !first.CreatedAt.Equal(tt.wantCreated) {
+				t.Errorf("first page = %+v, want title %q, tags %v, read %v, created %v", first, tt.wantTitle, tt.wantTags, tt.wantRead, tt.wantCreated)
+			}
+		})
+	}
+}
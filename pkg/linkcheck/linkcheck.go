@@ -0,0 +1,306 @@
+// Package linkcheck periodically checks saved links and records which of them
+// are dead or have moved to another address.
+package linkcheck
+
+import (
+	"URLbot/pkg/safehttp"
+	"URLbot/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// userAgent identifies the bot to the sites it checks.
+const userAgent = "NamnadaLinkBot/1.0 (+https://github.com/namnadaa/namnada-link)"
+
+// maxBodyBytes is how much of a GET response body is read before the
+// connection is closed. Only the status code matters for a check.
+const maxBodyBytes = 64 << 10
+
+// Result is the outcome of checking a single URL.
+type Result struct {
+	StatusCode  int    // Final status code, zero if the request failed.
+	RedirectURL string // Final URL after redirects, empty if there were none.
+	Err         error  // Network error, if the request failed.
+}
+
+// Failed reports whether the link looked dead: the site could not be reached
+// or answered with an error status. Rate limiting (429) is not a failure.
+func (r Result) Failed() bool {
+	return r.StatusCode == 0 || r.StatusCode >= 400 && r.StatusCode != http.StatusTooManyRequests
+}
+
+// Apply records the result on the page and counts failed checks in a row.
+func (r Result) Apply(p *storage.Page, checkedAt time.Time) {
+	p.StatusCode = r.StatusCode
+	p.RedirectURL = r.RedirectURL
+	p.CheckedAt = checkedAt
+
+	if r.Failed() {
+		p.CheckFailures++
+	} else {
+		p.CheckFailures = 0
+	}
+
+	p.CheckError = ""
+	if r.Err != nil {
+		p.CheckError = r.Err.Error()
+	}
+}
+
+// Checker checks URLs with HEAD requests, falling back to GET for servers
+// that do not answer HEAD properly. Requests to the same host are spaced out
+// by the politeness delay. Only public addresses are contacted, see
+// package safehttp.
+type Checker struct {
+	client *http.Client
+	hosts  *hostLimiter
+}
+
+// NewChecker creates a Checker with the given request timeout and the minimum
+// delay between two requests to the same host.
+func NewChecker(timeout, hostDelay time.Duration) *Checker {
+	return &Checker{
+		client: safehttp.NewClient(timeout),
+		hosts:  newHostLimiter(hostDelay),
+	}
+}
+
+// SetTransport replaces the guarded transport, e.g. with one that also
+// allows a local test server.
+func (c *Checker) SetTransport(t http.RoundTripper) {
+	c.client.Transport = t
+}
+
+// Check requests pageURL and reports its final status and redirect target.
+func (c *Checker) Check(ctx context.Context, pageURL string) Result {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return Result{Err: fmt.Errorf("invalid URL: %v", err)}
+	}
+
+	res := c.do(ctx, http.MethodHead, u)
+	if res.Err == nil && res.StatusCode < http.StatusBadRequest {
+		return res
+	}
+
+	// Many servers reject HEAD or answer it differently, so a failed HEAD
+	// is confirmed with GET before the link is reported as dead.
+	if ctx.Err() != nil {
+		return res
+	}
+
+	return c.do(ctx, http.MethodGet, u)
+}
+
+// do performs a single request after waiting for the host's turn.
+func (c *Checker) do(ctx context.Context, method string, u *url.URL) Result {
+	err := c.hosts.wait(ctx, u.Host)
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return Result{Err: fmt.Errorf("create request failed: %v", err)}
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Result{Err: fmt.Errorf("request execution failed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodyBytes))
+
+	res := Result{StatusCode: resp.StatusCode}
+	if final := resp.Request.URL.String(); final != u.String() {
+		res.RedirectURL = final
+	}
+
+	return res
+}
+
+// Store is the part of storage.Storage used by the link checker job.
+type Store interface {
+	ListAll() ([]*storage.Page, error)
+	Update(userName, pageURL string, fn func(p *storage.Page)) error
+}
+
+// Job checks every stored link on a schedule.
+type Job struct {
+	checker  *Checker
+	store    Store
+	workers  int
+	interval time.Duration
+}
+
+// NewJob creates a Job that checks all links in store every interval,
+// running at most workers checks at a time.
+func NewJob(checker *Checker, store Store, workers int, interval time.Duration) *Job {
+	return &Job{
+		checker:  checker,
+		store:    store,
+		workers:  workers,
+		interval: interval,
+	}
+}
+
+// Run checks all links every interval until the context is canceled.
+// The first run starts after one interval, so a restart does not trigger
+// a burst of requests.
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+
+		checked, err := j.CheckAll(ctx)
+		if err != nil {
+			slog.Error("linkcheck: run failed", "err", err)
+			continue
+		}
+
+		slog.Info("linkcheck: run finished", "links", checked, "took", time.Since(start))
+	}
+}
+
+// CheckAll checks every stored link once and records the results.
+// A URL saved by several owners is requested only once.
+// It returns the number of distinct URLs checked.
+func (j *Job) CheckAll(ctx context.Context) (int, error) {
+	pages, err := j.store.ListAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pages: %v", err)
+	}
+
+	owners := make(map[string][]string)
+	var urls []string
+	for _, p := range pages {
+		if _, ok := owners[p.URL]; !ok {
+			urls = append(urls, p.URL)
+		}
+		owners[p.URL] = append(owners[p.URL], p.UserName)
+	}
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+
+	for i := 0; i < j.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for pageURL := range queue {
+				res := j.checker.Check(ctx, pageURL)
+				if ctx.Err() != nil {
+					continue
+				}
+
+				j.record(pageURL, owners[pageURL], res)
+			}
+		}()
+	}
+
+loop:
+	for _, pageURL := range urls {
+		select {
+		case <-ctx.Done():
+			break loop
+		case queue <- pageURL:
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return len(urls), ctx.Err()
+}
+
+// record stores the result of a check on the page of every owner.
+func (j *Job) record(pageURL string, owners []string, res Result) {
+	now := time.Now()
+
+	for _, owner := range owners {
+		err := j.store.Update(owner, pageURL, func(p *storage.Page) {
+			res.Apply(p, now)
+		})
+		if err != nil && !errors.Is(err, storage.ErrNoPagesFound) {
+			slog.Error("linkcheck: failed to save result", "url", pageURL, "owner", owner, "err", err)
+		}
+	}
+}
+
+// hostPruneDelays is how many delays pass between two sweeps of the slots
+// of hosts that are no longer being checked.
+const hostPruneDelays = 100
+
+// hostLimiter spaces out requests to the same host.
+type hostLimiter struct {
+	delay time.Duration
+
+	mu     sync.Mutex
+	next   map[string]time.Time
+	pruned time.Time
+}
+
+// newHostLimiter creates a limiter that allows one request per delay to each host.
+func newHostLimiter(delay time.Duration) *hostLimiter {
+	return &hostLimiter{
+		delay:  delay,
+		next:   make(map[string]time.Time),
+		pruned: time.Now(),
+	}
+}
+
+// wait blocks until a request to the host may be sent and reserves that slot.
+// It returns early with the context's error if the context is canceled.
+// Hosts whose slots have passed are forgotten every hostPruneDelays delays.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l.delay <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if now.Sub(l.pruned) >= hostPruneDelays*l.delay {
+		for h, next := range l.next {
+			if next.Before(now) {
+				delete(l.next, h)
+			}
+		}
+		l.pruned = now
+	}
+
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.delay)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,156 @@
+package linkcheck_test
+
+import (
+	"URLbot/pkg/linkcheck"
+	"URLbot/pkg/safehttp"
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/nohead", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newChecker creates a Checker that may connect to local test servers.
+func newChecker(hostDelay time.Duration) *linkcheck.Checker {
+	c := linkcheck.NewChecker(time.Second, hostDelay)
+	c.SetTransport(safehttp.NewTransport(netip.MustParsePrefix("127.0.0.0/8")))
+
+	return c
+}
+
+func TestChecker_Check(t *testing.T) {
+	server := newServer(t)
+	checker := newChecker(0)
+
+	tests := []struct {
+		name         string
+		url          string
+		wantStatus   int
+		wantRedirect string
+		wantErr      bool
+	}{
+		{name: "ok", url: server.URL + "/ok", wantStatus: http.StatusOK},
+		{name: "not found", url: server.URL + "/gone", wantStatus: http.StatusNotFound},
+		{name: "redirect", url: server.URL + "/moved", wantStatus: http.StatusOK, wantRedirect: server.URL + "/ok"},
+		{name: "HEAD not allowed", url: server.URL + "/nohead", wantStatus: http.StatusOK},
+		{name: "unreachable", url: "http://127.0.0.1:1/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := checker.Check(context.Background(), tt.url)
+
+			if (res.Err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", res.Err, tt.wantErr)
+			}
+
+			if res.StatusCode != tt.wantStatus || res.RedirectURL != tt.wantRedirect {
+				t.Errorf("Check() = %d %q, want %d %q", res.StatusCode, res.RedirectURL, tt.wantStatus, tt.wantRedirect)
+			}
+		})
+	}
+}
+
+func TestChecker_Check_localAddress(t *testing.T) {
+	server := newServer(t)
+
+	res := linkcheck.NewChecker(time.Second, 0).Check(context.Background(), server.URL+"/ok")
+	if !errors.Is(res.Err, safehttp.ErrForbiddenAddress) {
+		t.Errorf("Check() of a local address error = %v, want %v", res.Err, safehttp.ErrForbiddenAddress)
+	}
+}
+
+func TestJob_CheckAll(t *testing.T) {
+	server := newServer(t)
+
+	s := memory.New()
+	for _, p := range []*storage.Page{
+		{URL: server.URL + "/ok", UserName: "Alex"},
+		{URL: server.URL + "/gone", UserName: "Alex"},
+		{URL: server.URL + "/gone", UserName: "Bob"},
+		{URL: server.URL + "/moved", UserName: "Bob"},
+	} {
+		err := s.Save(p)
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	job := linkcheck.NewJob(newChecker(10*time.Millisecond), s, 2, time.Hour)
+
+	// A link is only reported dead once enough checks in a row have failed.
+	for run := 1; run <= storage.BrokenAfter; run++ {
+		checked, err := job.CheckAll(context.Background())
+		if err != nil {
+			t.Fatalf("CheckAll() failed: %v", err)
+		}
+
+		if checked != 3 {
+			t.Errorf("CheckAll() checked %d URLs, want 3", checked)
+		}
+
+		pages, err := s.ListAll()
+		if err != nil {
+			t.Fatalf("ListAll() failed: %v", err)
+		}
+
+		for _, p := range pages {
+			if p.CheckedAt.IsZero() {
+				t.Errorf("%s of %s was not checked", p.URL, p.UserName)
+			}
+
+			wantBroken := p.URL == server.URL+"/gone" && run == storage.BrokenAfter
+			wantMoved := p.URL == server.URL+"/moved"
+			if p.Broken() != wantBroken || p.Moved() != wantMoved {
+				t.Errorf("run %d, %s: Broken() = %v, Moved() = %v; want %v, %v", run, p.URL, p.Broken(), p.Moved(), wantBroken, wantMoved)
+			}
+		}
+	}
+}
+
+func TestResult_Apply(t *testing.T) {
+	p := &storage.Page{URL: "https://example.com"}
+
+	results := []linkcheck.Result{
+		{StatusCode: http.StatusNotFound},
+		{Err: errors.New("connection refused")},
+		{StatusCode: http.StatusOK},
+		{StatusCode: http.StatusTooManyRequests},
+		{StatusCode: http.StatusBadGateway},
+	}
+	wantFailures := []int{1, 2, 0, 0, 1}
+
+	for i, res := range results {
+		res.Apply(p, time.Now())
+		if p.CheckFailures != wantFailures[i] || p.Broken() {
+			t.Errorf("after %+v CheckFailures = %d, Broken() = %v; want %d, false", res, p.CheckFailures, p.Broken(), wantFailures[i])
+		}
+	}
+}
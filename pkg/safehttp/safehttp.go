@@ -0,0 +1,97 @@
+// Package safehttp provides an HTTP transport for fetching user-supplied URLs.
+// It refuses to connect to loopback, private, link-local and other
+// non-public addresses, so users cannot make the bot read internal services
+// such as cloud metadata endpoints.
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("address is not publicly routable")
+
+// forbidden lists the non-public ranges not covered by the netip.Addr methods
+// checked in allowed.
+var forbidden = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "This network".
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT, also used for metadata services.
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments.
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking.
+	netip.MustParsePrefix("240.0.0.0/4"),   // Reserved, including broadcast.
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which can reach IPv4 hosts.
+}
+
+// Transport is the guarded transport shared by the bot's fetchers,
+// so they also share its connection pool.
+var Transport = NewTransport()
+
+// NewTransport creates a transport that only connects to public addresses,
+// plus the addresses in allow, such as a local test server. The address is
+// checked on every dial, after DNS resolution, so redirects and hostnames
+// resolving to internal addresses are refused too. Proxies from the
+// environment are not used, since they would bypass the check.
+func NewTransport(allow ...netip.Prefix) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddress(address, allow)
+		},
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+
+	return t
+}
+
+// NewClient creates a client with the given timeout that uses Transport.
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport}
+}
+
+// checkAddress returns ErrForbiddenAddress if the dialed host:port is not
+// a public address and is not in allow.
+func checkAddress(address string, allow []netip.Prefix) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %v", address, err)
+	}
+
+	addr := addrPort.Addr().Unmap()
+	for _, prefix := range allow {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+
+	if !allowed(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+	}
+
+	return nil
+}
+
+// allowed reports whether addr is a public unicast address.
+func allowed(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range forbidden {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,43 @@
+package safehttp_test
+
+import (
+	"URLbot/pkg/safehttp"
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestNewTransport_RefusesNonPublicAddresses(t *testing.T) {
+	dial := safehttp.NewTransport().DialContext
+
+	for _, addr := range []string{
+		"127.0.0.1:80",
+		"[::1]:80",
+		"10.0.0.1:80",
+		"172.16.5.4:443",
+		"192.168.1.1:80",
+		"169.254.169.254:80",
+		"[fe80::1]:80",
+		"[fd00::1]:80",
+		"0.0.0.0:80",
+		"[::]:80",
+		"100.100.100.200:80",
+		"[::ffff:127.0.0.1]:80",
+	} {
+		_, err := dial(context.Background(), "tcp", addr)
+		if !errors.Is(err, safehttp.ErrForbiddenAddress) {
+			t.Errorf("dial %s error = %v, want %v", addr, err, safehttp.ErrForbiddenAddress)
+		}
+	}
+}
+
+func TestNewTransport_Allow(t *testing.T) {
+	dial := safehttp.NewTransport(netip.MustParsePrefix("127.0.0.0/8")).DialContext
+
+	// Nothing listens on port 1, so the dial fails, but not because of the guard.
+	_, err := dial(context.Background(), "tcp", "127.0.0.1:1")
+	if errors.Is(err, safehttp.ErrForbiddenAddress) {
+		t.Errorf("dial of an allowed address error = %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Package scheduler runs periodic jobs, such as sending digests, inside the bot process.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// JobFunc performs the work that is due at now.
+type JobFunc func(ctx context.Context, now time.Time) error
+
+// job is a named JobFunc registered in a Scheduler.
+type job struct {
+	name string
+	fn   JobFunc
+}
+
+// Scheduler calls its jobs on every tick. Jobs decide themselves what is due,
+// usually by querying the storage, so the scheduler itself keeps no state.
+type Scheduler struct {
+	tick time.Duration
+	jobs []job
+}
+
+// New creates a Scheduler that runs its jobs every tick.
+func New(tick time.Duration) *Scheduler {
+	return &Scheduler{tick: tick}
+}
+
+// Add registers a job. Jobs run one after another in registration order.
+func (s *Scheduler) Add(name string, fn JobFunc) {
+	s.jobs = append(s.jobs, job{name: name, fn: fn})
+}
+
+// Run calls the jobs right away and then on every tick until the context is canceled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for {
+		s.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce calls every job once with the current time. Errors are logged
+// and do not stop the other jobs.
+func (s *Scheduler) RunOnce(ctx context.Context) {
+	now := time.Now()
+
+	for _, j := range s.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := j.fn(ctx, now)
+		if err != nil {
+			slog.Error("scheduler: job failed", "job", j.name, "err", err)
+		}
+	}
+}
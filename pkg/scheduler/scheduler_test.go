@@ -0,0 +1,63 @@
+package scheduler_test
+
+import (
+	"URLbot/pkg/scheduler"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler_RunOnce(t *testing.T) {
+	s := scheduler.New(time.Minute)
+
+	var order []string
+	s.Add("failing", func(ctx context.Context, now time.Time) error {
+		order = append(order, "failing")
+		return errors.New("boom")
+	})
+	s.Add("second", func(ctx context.Context, now time.Time) error {
+		if now.IsZero() {
+			t.Error("job got a zero time")
+		}
+		order = append(order, "second")
+		return nil
+	})
+
+	s.RunOnce(context.Background())
+
+	if len(order) != 2 || order[0] != "failing" || order[1] != "second" {
+		t.Errorf("jobs ran in order %v, want [failing second]", order)
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	s := scheduler.New(5 * time.Millisecond)
+
+	var calls int32
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.Add("counter", func(ctx context.Context, now time.Time) error {
+		if atomic.AddInt32(&calls, 1) == 3 {
+			cancel()
+		}
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() did not stop after the context was canceled")
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("job ran %d times, want 3", got)
+	}
+}
@@ -0,0 +1,504 @@
+// Package service implements the reading list operations shared by the
+// Telegram bot and the HTTP API, so both behave the same way: saved pages are
+// queued for enrichment, changes are recorded for /undo and random picks
+// follow the owner's selection strategy.
+package service
+
+import (
+	"URLbot/pkg/storage"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrInvalidURL    = errors.New("invalid URL")
+	ErrInvalidTag    = errors.New("invalid tag")
+	ErrUnknownLength = errors.New("unknown reading length")
+)
+
+// Reading time bounds of short and long reads.
+const (
+	ShortRead = 5 * time.Minute
+	LongRead  = 20 * time.Minute
+)
+
+// MaxRandom is the largest number of pages picked at once by Random.
+const MaxRandom = 10
+
+// Enqueuer schedules newly saved pages for background processing,
+// such as fetching their titles.
+type Enqueuer interface {
+	Enqueue(p *storage.Page)
+}
+
+// Service performs reading list operations on behalf of an owner.
+type Service struct {
+	storage  storage.Storage
+	enricher Enqueuer
+
+	// avoidRecent is how many recent random picks are not repeated.
+	avoidRecent int
+}
+
+// New creates a Service backed by the given storage.
+func New(s storage.Storage) *Service {
+	return &Service{storage: s}
+}
+
+// SetEnricher sets the pipeline that fetches metadata of newly saved pages.
+func (s *Service) SetEnricher(e Enqueuer) {
+	s.enricher = e
+}
+
+// SetAvoidRecent makes Random skip pages served in the last k picks, as long
+// as there are enough other unread pages. Zero turns this off.
+func (s *Service) SetAvoidRecent(k int) {
+	s.avoidRecent = k
+}
+
+// Enqueue passes newly saved pages to the enricher, if one is set.
+func (s *Service) Enqueue(pages ...*storage.Page) {
+	if s.enricher == nil {
+		return
+	}
+
+	for _, page := range pages {
+		s.enricher.Enqueue(page)
+	}
+}
+
+// PushUndo records a change in the owner's undo log. A failure is only logged,
+// since the change itself has already been made.
+func (s *Service) PushUndo(owner string, a storage.Action) {
+	a.At = time.Now()
+
+	err := s.storage.PushUndo(owner, a)
+	if err != nil {
+		slog.Warn("Failed to record undo", "owner", owner, "url", a.URL, "err", err)
+	}
+}
+
+// Get returns a page of the owner by its ID.
+func (s *Service) Get(owner string, id int) (*storage.Page, error) {
+	page, err := s.storage.Get(owner, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("failed to get page: %v", err)
+	}
+
+	return page, nil
+}
+
+// Add saves a page to the owner's list on behalf of addedBy. It returns
+// storage.ErrPageExists if the owner has already saved the URL.
+func (s *Service) Add(owner, addedBy, pageURL string, tags []string) (*storage.Page, error) {
+	if !IsURL(pageURL) {
+		return nil, ErrInvalidURL
+	}
+
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &storage.Page{
+		URL:      pageURL,
+		UserName: owner,
+		AddedBy:  addedBy,
+		Tags:     tags,
+	}
+
+	isExists, err := s.storage.IsExists(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if the page exists: %v", err)
+	}
+
+	if isExists {
+		return nil, storage.ErrPageExists
+	}
+
+	err = s.storage.Save(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save page: %v", err)
+	}
+
+	s.Enqueue(page)
+	s.PushUndo(owner, storage.Action{Kind: storage.ActionSave, URL: page.URL})
+
+	return page, nil
+}
+
+// Status selects pages by their read status.
+type Status int
+
+const (
+	AnyStatus Status = iota
+	Unread
+	Read
+)
+
+// ParseStatus converts "all", "unread" or "read" into a Status.
+// An empty name means any status.
+func ParseStatus(name string) (Status, error) {
+	switch strings.ToLower(name) {
+	case "", "all":
+		return AnyStatus, nil
+	case "unread":
+		return Unread, nil
+	case "read":
+		return Read, nil
+	default:
+		return AnyStatus, fmt.Errorf("unknown status %q", name)
+	}
+}
+
+// ListQuery selects a page of the owner's list. A zero Limit means no limit.
+type ListQuery struct {
+	Status Status
+	Tag    string
+	Offset int
+	Limit  int
+}
+
+// List returns the owner's pages matching the query, in the order they were
+// saved, and the number of matching pages before paging.
+func (s *Service) List(owner string, q ListQuery) ([]*storage.Page, int, error) {
+	pages, err := s.storage.List(owner)
+	if err != nil && !errors.Is(err, storage.ErrNoPagesFound) {
+		return nil, 0, fmt.Errorf("failed to fetch pages list: %v", err)
+	}
+
+	tag := strings.ToLower(strings.TrimPrefix(q.Tag, "#"))
+
+	var res []*storage.Page
+	for _, page := range pages {
+		if q.Status == Unread && page.Read || q.Status == Read && !page.Read {
+			continue
+		}
+		if tag != "" && !hasTag(page, tag) {
+			continue
+		}
+
+		res = append(res, page)
+	}
+
+	total := len(res)
+
+	res = res[min(max(q.Offset, 0), total):]
+	if q.Limit > 0 && len(res) > q.Limit {
+		res = res[:q.Limit]
+	}
+
+	return res, total, nil
+}
+
+// Random picks up to n distinct unread pages of the owner with the owner's
+// selection strategy and marks them as served. Pages among the last
+// avoidRecent picks are skipped while there are enough other pages.
+// It returns no pages if nothing matches.
+func (s *Service) Random(owner string, n int, filter storage.Filter) ([]*storage.Page, error) {
+	strategy, err := s.storage.Strategy(owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get selection strategy: %v", err)
+	}
+
+	pages, err := s.pickRandom(owner, n, strategy, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pages) == 0 {
+		return nil, nil
+	}
+
+	urls := make([]string, 0, len(pages))
+	for _, page := range pages {
+		urls = append(urls, page.URL)
+	}
+
+	err = s.storage.MarkServed(owner, urls, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark pages as served: %v", err)
+	}
+
+	return pages, nil
+}
+
+// pickRandom picks up to n distinct unread pages of the owner, preferring
+// pages outside the recent picks.
+func (s *Service) pickRandom(owner string, n int, strategy storage.Strategy, filter storage.Filter) ([]*storage.Page, error) {
+	var recent []string
+	if s.avoidRecent > 0 {
+		var err error
+		recent, err = s.storage.RecentlyServed(owner, s.avoidRecent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get recently served pages: %v", err)
+		}
+	}
+
+	pages, err := s.storage.PickUnreadN(owner, n, strategy, excludeURLs(filter, recent))
+	if err != nil && !errors.Is(err, storage.ErrNoPagesFound) {
+		return nil, fmt.Errorf("failed to get random unread pages: %v", err)
+	}
+
+	if len(pages) == n || len(recent) == 0 {
+		return pages, nil
+	}
+
+	// Not enough pages outside the recent picks: top up with recent ones.
+	picked := make([]string, 0, len(pages))
+	for _, page := range pages {
+		picked = append(picked, page.URL)
+	}
+
+	more, err := s.storage.PickUnreadN(owner, n-len(pages), strategy, excludeURLs(filter, picked))
+	if err != nil && !errors.Is(err, storage.ErrNoPagesFound) {
+		return nil, fmt.Errorf("failed to get random unread pages: %v", err)
+	}
+
+	return append(pages, more...), nil
+}
+
+// ReadingFilter returns the filter of "short" or "long" reads. Pages whose
+// reading time is not known yet match neither.
+func ReadingFilter(length string) (storage.Filter, error) {
+	switch strings.ToLower(strings.TrimSpace(length)) {
+	case "short":
+		return func(p *storage.Page) bool {
+			return p.ReadingTime > 0 && p.ReadingTime < ShortRead
+		}, nil
+	case "long":
+		return func(p *storage.Page) bool {
+			return p.ReadingTime > LongRead
+		}, nil
+	default:
+		return nil, ErrUnknownLength
+	}
+}
+
+// MarkRead marks a page of the owner as read. Marking a read page again
+// changes nothing. It returns storage.ErrNoPagesFound if there is no such page.
+func (s *Service) MarkRead(owner, pageURL string) error {
+	changed, _, err := s.SetRead(owner, pageURL, true, time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return err
+		}
+
+		return fmt.Errorf("failed to mark page as read: %v", err)
+	}
+
+	if changed {
+		s.PushUndo(owner, storage.Action{Kind: storage.ActionRead, URL: pageURL})
+	}
+
+	return nil
+}
+
+// MarkUnread marks a read page of the owner as unread again, keeping its
+// metadata. It reports whether the page was read.
+func (s *Service) MarkUnread(owner, pageURL string) (bool, error) {
+	changed, readAt, err := s.SetRead(owner, pageURL, false, time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return false, err
+		}
+
+		return false, fmt.Errorf("failed to mark page as unread: %v", err)
+	}
+
+	if changed {
+		s.PushUndo(owner, storage.Action{Kind: storage.ActionUnread, URL: pageURL, ReadAt: readAt})
+	}
+
+	return changed, nil
+}
+
+// SetRead sets the read status of a page and records the change in its history.
+// A page marked as read gets readAt as its read time. It reports whether the
+// status changed and returns the previous read time. Unlike MarkRead and
+// MarkUnread it does not record the change for undo.
+func (s *Service) SetRead(owner, pageURL string, read bool, readAt time.Time) (bool, time.Time, error) {
+	changed := false
+	var prevReadAt time.Time
+
+	err := s.storage.Update(owner, pageURL, func(stored *storage.Page) {
+		if stored.Read == read {
+			return
+		}
+
+		changed, prevReadAt = true, stored.ReadAt
+
+		kind := storage.ActionUnread
+		stored.Read, stored.ReadAt = read, time.Time{}
+		if read {
+			kind = storage.ActionRead
+			stored.ReadAt = readAt
+		}
+
+		stored.History = append(stored.History, storage.Change{Kind: kind, At: time.Now()})
+	})
+
+	return changed, prevReadAt, err
+}
+
+// Remove moves a page of the owner to the trash.
+func (s *Service) Remove(owner, pageURL string) error {
+	err := s.storage.Remove(&storage.Page{URL: pageURL, UserName: owner})
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return err
+		}
+
+		return fmt.Errorf("failed to remove page: %v", err)
+	}
+
+	s.PushUndo(owner, storage.Action{Kind: storage.ActionRemove, URL: pageURL})
+
+	return nil
+}
+
+// ChangeURL moves a page of the owner to newURL, keeping its ID, so it stays
+// in collections and shares, along with its tags, metadata and history.
+// The link check result is reset and metadata is fetched again, since both
+// described the old URL. It returns
+// storage.ErrPageExists if newURL is already saved.
+func (s *Service) ChangeURL(owner, oldURL, newURL string) error {
+	err := s.storage.ChangeURL(owner, oldURL, newURL)
+	if err != nil {
+		if errors.Is(err, storage.ErrPageExists) || errors.Is(err, storage.ErrNoPagesFound) {
+			return err
+		}
+
+		return fmt.Errorf("failed to change page URL: %v", err)
+	}
+
+	var moved *storage.Page
+	err = s.storage.Update(owner, newURL, func(stored *storage.Page) {
+		stored.StatusCode, stored.RedirectURL, stored.CheckError = 0, "", ""
+		stored.CheckedAt, stored.CheckFailures = time.Time{}, 0
+		moved = stored.Clone()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset link check: %v", err)
+	}
+
+	s.Enqueue(moved)
+
+	s.PushUndo(owner, storage.Action{Kind: storage.ActionMove, URL: newURL, OldURL: oldURL})
+
+	return nil
+}
+
+// Tag adds and removes tags of a page of the owner and returns its new tags.
+// Tags are lowercased and a leading "#" is ignored.
+func (s *Service) Tag(owner, pageURL string, add, remove []string) ([]string, error) {
+	add, err := NormalizeTags(add)
+	if err != nil {
+		return nil, err
+	}
+
+	remove, err = NormalizeTags(remove)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev, tags []string
+	err = s.storage.Update(owner, pageURL, func(stored *storage.Page) {
+		prev = stored.Tags
+		tags = applyTags(stored.Tags, add, remove)
+		stored.Tags = tags
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPagesFound) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("failed to update tags: %v", err)
+	}
+
+	// Nothing to undo if the page already had exactly these tags.
+	if !slices.Equal(prev, tags) {
+		s.PushUndo(owner, storage.Action{Kind: storage.ActionTag, URL: pageURL, Tags: prev})
+	}
+
+	return tags, nil
+}
+
+// NormalizeTags lowercases tags and strips their leading "#".
+// It returns ErrInvalidTag for empty tags and tags with spaces.
+func NormalizeTags(tags []string) ([]string, error) {
+	var res []string
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimPrefix(t, "#"))
+		if t == "" || strings.ContainsFunc(t, unicode.IsSpace) {
+			return nil, ErrInvalidTag
+		}
+
+		res = append(res, t)
+	}
+
+	return res, nil
+}
+
+// IsURL checks whether the given string is a valid URL that contains a host.
+func IsURL(text string) bool {
+	u, err := url.Parse(text)
+
+	return err == nil && u.Host != ""
+}
+
+// hasTag reports whether the page has the tag.
+func hasTag(p *storage.Page, tag string) bool {
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyTags returns tags with the added tags appended and the removed ones left out.
+func applyTags(tags, add, remove []string) []string {
+	drop := make(map[string]bool, len(remove))
+	for _, t := range remove {
+		drop[t] = true
+	}
+
+	var res []string
+	seen := make(map[string]bool)
+	for _, t := range append(append([]string(nil), tags...), add...) {
+		if !drop[t] && !seen[t] {
+			seen[t] = true
+			res = append(res, t)
+		}
+	}
+
+	return res
+}
+
+// excludeURLs narrows the filter down to pages whose URL is not in urls.
+func excludeURLs(filter storage.Filter, urls []string) storage.Filter {
+	if len(urls) == 0 {
+		return filter
+	}
+
+	skip := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		skip[u] = true
+	}
+
+	return func(p *storage.Page) bool {
+		return !skip[p.URL] && filter.Match(p)
+	}
+}
@@ -0,0 +1,246 @@
+package service_test
+
+import (
+	"URLbot/pkg/service"
+	"URLbot/pkg/storage"
+	"URLbot/pkg/storage/memory"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mockEnricher struct {
+	urls []string
+}
+
+func (m *mockEnricher) Enqueue(p *storage.Page) {
+	m.urls = append(m.urls, p.URL)
+}
+
+func TestService_Add(t *testing.T) {
+	s := memory.New()
+	enricher := &mockEnricher{}
+
+	svc := service.New(s)
+	svc.SetEnricher(enricher)
+
+	page, err := svc.Add("alex", "alex", "https://go.dev/blog", []string{"#Go", "news"})
+	if err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if page.ID == 0 || !reflect.DeepEqual(page.Tags, []string{"go", "news"}) {
+		t.Errorf("Add() = %+v, want a stored page with normalized tags", page)
+	}
+	if !reflect.DeepEqual(enricher.urls, []string{"https://go.dev/blog"}) {
+		t.Errorf("enqueued %v, want the saved page", enricher.urls)
+	}
+	if a, err := s.PopUndo("alex"); err != nil || a.Kind != storage.ActionSave {
+		t.Errorf("PopUndo() = %+v, %v; want the save recorded", a, err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		tags    []string
+		wantErr error
+	}{
+		{name: "duplicate", url: "https://go.dev/blog", wantErr: storage.ErrPageExists},
+		{name: "not a URL", url: "go.dev", wantErr: service.ErrInvalidURL},
+		{name: "empty tag", url: "https://go.dev/doc", tags: []string{"#"}, wantErr: service.ErrInvalidTag},
+		{name: "tag with spaces", url: "https://go.dev/doc", tags: []string{"two words"}, wantErr: service.ErrInvalidTag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Add("alex", "alex", tt.url, tt.tags)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Add(%q) error = %v, want %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestService_List(t *testing.T) {
+	s := memory.New()
+	svc := service.New(s)
+
+	for _, p := range []*storage.Page{
+		{URL: "https://a.example", UserName: "alex", Tags: []string{"go"}},
+		{URL: "https://b.example", UserName: "alex", Read: true, Tags: []string{"go"}},
+		{URL: "https://c.example", UserName: "alex"},
+		{URL: "https://d.example", UserName: "alex", Tags: []string{"go"}},
+	} {
+		if err := s.Save(p); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		query     service.ListQuery
+		wantURLs  []string
+		wantTotal int
+	}{
+		{name: "all", wantURLs: []string{"https://a.example", "https://b.example", "https://c.example", "https://d.example"}, wantTotal: 4},
+		{name: "unread", query: service.ListQuery{Status: service.Unread}, wantURLs: []string{"https://a.example", "https://c.example", "https://d.example"}, wantTotal: 3},
+		{name: "read", query: service.ListQuery{Status: service.Read}, wantURLs: []string{"https://b.example"}, wantTotal: 1},
+		{name: "tag", query: service.ListQuery{Tag: "#Go"}, wantURLs: []string{"https://a.example", "https://b.example", "https://d.example"}, wantTotal: 3},
+		{name: "paging", query: service.ListQuery{Offset: 1, Limit: 2}, wantURLs: []string{"https://b.example", "https://c.example"}, wantTotal: 4},
+		{name: "past the end", query: service.ListQuery{Offset: 10}, wantTotal: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, total, err := svc.List("alex", tt.query)
+			if err != nil {
+				t.Fatalf("List() failed: %v", err)
+			}
+
+			var urls []string
+			for _, p := range pages {
+				urls = append(urls, p.URL)
+			}
+			if !reflect.DeepEqual(urls, tt.wantURLs) || total != tt.wantTotal {
+				t.Errorf("List() = %v, %d; want %v, %d", urls, total, tt.wantURLs, tt.wantTotal)
+			}
+		})
+	}
+
+	if pages, total, err := svc.List("bob", service.ListQuery{}); err != nil || len(pages) != 0 || total != 0 {
+		t.Errorf("List() of an empty list = %v, %d, %v; want nothing", pages, total, err)
+	}
+}
+
+func TestService_changes(t *testing.T) {
+	s := memory.New()
+	svc := service.New(s)
+
+	page, err := svc.Add("alex", "alex", "https://go.dev/blog", nil)
+	if err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	picked, err := svc.Random("alex", 3, nil)
+	if err != nil || len(picked) != 1 || picked[0].URL != page.URL {
+		t.Fatalf("Random() = %v, %v; want the only unread page", picked, err)
+	}
+	if recent, _ := s.RecentlyServed("alex", 1); !reflect.DeepEqual(recent, []string{page.URL}) {
+		t.Errorf("RecentlyServed() = %v, want the picked page", recent)
+	}
+
+	tags, err := svc.Tag("alex", page.URL, []string{"Go", "news"}, nil)
+	if err != nil || !reflect.DeepEqual(tags, []string{"go", "news"}) {
+		t.Errorf("Tag() = %v, %v; want [go news]", tags, err)
+	}
+	if tags, err = svc.Tag("alex", page.URL, nil, []string{"#news"}); err != nil || !reflect.DeepEqual(tags, []string{"go"}) {
+		t.Errorf("Tag() removing = %v, %v; want [go]", tags, err)
+	}
+	// Tagging without a change leaves nothing to undo.
+	if tags, err = svc.Tag("alex", page.URL, []string{"go"}, []string{"rust"}); err != nil || !reflect.DeepEqual(tags, []string{"go"}) {
+		t.Errorf("Tag() without a change = %v, %v; want [go]", tags, err)
+	}
+
+	if err := svc.MarkRead("alex", page.URL); err != nil {
+		t.Fatalf("MarkRead() failed: %v", err)
+	}
+	if picked, err := svc.Random("alex", 1, nil); err != nil || len(picked) != 0 {
+		t.Errorf("Random() after reading = %v, %v; want nothing", picked, err)
+	}
+
+	changed, err := svc.MarkUnread("alex", page.URL)
+	if err != nil || !changed {
+		t.Errorf("MarkUnread() = %v, %v; want the page marked as unread", changed, err)
+	}
+
+	if err := svc.Remove("alex", page.URL); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+
+	for name, err := range map[string]error{
+		"MarkRead": svc.MarkRead("alex", page.URL),
+		"Remove":   svc.Remove("alex", page.URL),
+	} {
+		if !errors.Is(err, storage.ErrNoPagesFound) {
+			t.Errorf("%s() of a removed page error = %v, want %v", name, err, storage.ErrNoPagesFound)
+		}
+	}
+
+	var kinds []storage.ActionKind
+	for {
+		a, err := s.PopUndo("alex")
+		if err != nil {
+			break
+		}
+		kinds = append(kinds, a.Kind)
+	}
+
+	want := []storage.ActionKind{storage.ActionRemove, storage.ActionUnread, storage.ActionRead, storage.ActionTag, storage.ActionTag, storage.ActionSave}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("undo log = %v, want %v", kinds, want)
+	}
+}
+
+func TestReadingFilter(t *testing.T) {
+	short, err := service.ReadingFilter("short")
+	if err != nil {
+		t.Fatalf("ReadingFilter(short) failed: %v", err)
+	}
+	long, err := service.ReadingFilter("LONG")
+	if err != nil {
+		t.Fatalf("ReadingFilter(LONG) failed: %v", err)
+	}
+
+	tests := []struct {
+		readingTime time.Duration
+		wantShort   bool
+		wantLong    bool
+	}{
+		{readingTime: 0},
+		{readingTime: 3 * time.Minute, wantShort: true},
+		{readingTime: 10 * time.Minute},
+		{readingTime: 30 * time.Minute, wantLong: true},
+	}
+
+	for _, tt := range tests {
+		p := &storage.Page{ReadingTime: tt.readingTime}
+		if short(p) != tt.wantShort || long(p) != tt.wantLong {
+			t.Errorf("filters of %v = short %v, long %v; want %v, %v", tt.readingTime, short(p), long(p), tt.wantShort, tt.wantLong)
+		}
+	}
+
+	if _, err := service.ReadingFilter("medium"); !errors.Is(err, service.ErrUnknownLength) {
+		t.Errorf("ReadingFilter(medium) error = %v, want %v", err, service.ErrUnknownLength)
+	}
+}
+
+func TestService_tokens(t *testing.T) {
+	svc := service.New(memory.New())
+
+	first, err := svc.IssueToken("alex")
+	if err != nil {
+		t.Fatalf("IssueToken() failed: %v", err)
+	}
+
+	second, err := svc.IssueToken("alex")
+	if err != nil {
+		t.Fatalf("IssueToken() failed: %v", err)
+	}
+
+	if owner, err := svc.TokenOwner(second); err != nil || owner != "alex" {
+		t.Errorf("TokenOwner() = %q, %v; want alex", owner, err)
+	}
+
+	for name, token := range map[string]string{"replaced": first, "empty": "", "unknown": "nope"} {
+		if _, err := svc.TokenOwner(token); !errors.Is(err, storage.ErrNoAPIToken) {
+			t.Errorf("TokenOwner() of a %s token error = %v, want %v", name, err, storage.ErrNoAPIToken)
+		}
+	}
+
+	if err := svc.RevokeToken("alex"); err != nil {
+		t.Fatalf("RevokeToken() failed: %v", err)
+	}
+	if _, err := svc.TokenOwner(second); !errors.Is(err, storage.ErrNoAPIToken) {
+		t.Errorf("TokenOwner() of a revoked token error = %v, want %v", err, storage.ErrNoAPIToken)
+	}
+}
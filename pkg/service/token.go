@@ -0,0 +1,51 @@
+package service
+
+import (
+	"URLbot/pkg/storage"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenSize is the number of random bytes in an API token.
+const tokenSize = 32
+
+// IssueToken creates a new API token for the owner. The previous token stops
+// working. Only a hash of the token is stored, so it is shown to the user once.
+func (s *Service) IssueToken(owner string) (string, error) {
+	buf := make([]byte, tokenSize)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	token := hex.EncodeToString(buf)
+
+	err = s.storage.SetAPIToken(owner, storage.HashToken(token))
+	if err != nil {
+		return "", fmt.Errorf("failed to save API token: %v", err)
+	}
+
+	return token, nil
+}
+
+// RevokeToken stops the owner's API token from working.
+func (s *Service) RevokeToken(owner string) error {
+	err := s.storage.SetAPIToken(owner, "")
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %v", err)
+	}
+
+	return nil
+}
+
+// TokenOwner returns the owner of an API token, or storage.ErrNoAPIToken
+// if the token is not valid.
+func (s *Service) TokenOwner(token string) (string, error) {
+	if token == "" {
+		return "", storage.ErrNoAPIToken
+	}
+
+	return s.storage.APITokenOwner(storage.HashToken(token))
+}
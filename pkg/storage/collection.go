@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoCollection     = errors.New("collection not found")
+	ErrCollectionExists = errors.New("collection already exists")
+)
+
+// Collection is a named, ordered reading queue of an owner. A page can be
+// in several collections. Names are unique per owner regardless of case.
+// Members are the other users the collection is shared with, keyed by
+// lowercase username, since usernames are case-insensitive.
+type Collection struct {
+	Owner     string
+	Name      string
+	PageIDs   []int // Pages in reading order.
+	Members   map[string]Permission
+	CreatedAt time.Time
+}
+
+// Clone returns a deep copy of the collection.
+func (c *Collection) Clone() *Collection {
+	cp := *c
+	cp.PageIDs = append([]int(nil), c.PageIDs...)
+	if c.Members != nil {
+		cp.Members = make(map[string]Permission, len(c.Members))
+		for user, perm := range c.Members {
+			cp.Members[user] = perm
+		}
+	}
+
+	return &cp
+}
+
+// Access returns the permission the user has to the collection.
+// Usernames are compared regardless of case.
+func (c *Collection) Access(userName string) Permission {
+	if strings.EqualFold(userName, c.Owner) {
+		return FullAccess
+	}
+
+	return c.Members[strings.ToLower(userName)]
+}
+
+// Contains reports whether the page is in the collection.
+func (c *Collection) Contains(id int) bool {
+	return c.index(id) >= 0
+}
+
+// Insert puts the page at the 1-based position, moving it if it is already
+// in the collection. Positions out of range put the page at the end.
+func (c *Collection) Insert(id, pos int) {
+	c.Remove(id)
+
+	if pos < 1 || pos > len(c.PageIDs) {
+		c.PageIDs = append(c.PageIDs, id)
+		return
+	}
+
+	c.PageIDs = append(c.PageIDs[:pos-1], append([]int{id}, c.PageIDs[pos-1:]...)...)
+}
+
+// Remove takes the page out of the collection and reports whether it was there.
+func (c *Collection) Remove(id int) bool {
+	i := c.index(id)
+	if i < 0 {
+		return false
+	}
+
+	c.PageIDs = append(c.PageIDs[:i], c.PageIDs[i+1:]...)
+	return true
+}
+
+// index returns the position of the page in PageIDs, or -1.
+func (c *Collection) index(id int) int {
+	for i, pageID := range c.PageIDs {
+		if pageID == id {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,57 @@
+package storage_test
+
+import (
+	"URLbot/pkg/storage"
+	"reflect"
+	"testing"
+)
+
+func TestCollection_Insert(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []int
+		id   int
+		pos  int
+		want []int
+	}{
+		{name: "append to empty", ids: nil, id: 1, pos: 0, want: []int{1}},
+		{name: "append", ids: []int{1, 2}, id: 3, pos: 0, want: []int{1, 2, 3}},
+		{name: "insert first", ids: []int{1, 2}, id: 3, pos: 1, want: []int{3, 1, 2}},
+		{name: "insert middle", ids: []int{1, 2}, id: 3, pos: 2, want: []int{1, 3, 2}},
+		{name: "position past the end", ids: []int{1, 2}, id: 3, pos: 9, want: []int{1, 2, 3}},
+		{name: "move forward", ids: []int{1, 2, 3}, id: 3, pos: 1, want: []int{3, 1, 2}},
+		{name: "move back", ids: []int{1, 2, 3}, id: 1, pos: 3, want: []int{2, 3, 1}},
+		{name: "move to the end", ids: []int{1, 2, 3}, id: 1, pos: 0, want: []int{2, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &storage.Collection{PageIDs: append([]int(nil), tt.ids...)}
+			c.Insert(tt.id, tt.pos)
+
+			if !reflect.DeepEqual(c.PageIDs, tt.want) {
+				t.Errorf("Insert(%d, %d) = %v, want %v", tt.id, tt.pos, c.PageIDs, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollection_Remove(t *testing.T) {
+	c := &storage.Collection{PageIDs: []int{1, 2, 3}}
+
+	if !c.Remove(2) || !reflect.DeepEqual(c.PageIDs, []int{1, 3}) {
+		t.Errorf("Remove(2) left %v, want [1 3]", c.PageIDs)
+	}
+	if c.Remove(2) {
+		t.Error("Remove() of a missing page reported true")
+	}
+	if !c.Contains(3) || c.Contains(2) {
+		t.Errorf("Contains() is wrong for %v", c.PageIDs)
+	}
+
+	clone := c.Clone()
+	clone.Insert(7, 1)
+	if c.Contains(7) {
+		t.Error("changing a clone changed the original")
+	}
+}
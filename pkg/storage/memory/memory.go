@@ -3,26 +3,88 @@ package memory
 import (
 	"URLbot/pkg/storage"
 	"errors"
+	"maps"
 	"math/rand"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
-var ErrNilPage = errors.New("page is nil")
+const (
+	maxServedHistory = 100 // Served pages remembered per user.
+	maxUndo          = 20  // Undoable actions remembered per user.
+)
+
+var (
+	ErrNilPage   = errors.New("page is nil")
+	ErrNilDigest = errors.New("digest is nil")
+)
 
 // Storage is an in-memory implementation of Storage interface.
+// Pages are copied on the way in and out, so callers never share memory
+// with the stored pages.
 type Storage struct {
-	mu    sync.RWMutex
-	pages map[string][]*storage.Page
+	mu         sync.RWMutex
+	pages      map[string][]*storage.Page
+	chatModes  map[int]storage.ListMode
+	strategies map[string]storage.Strategy
+	served     map[string][]string
+	trash      map[string][]*storage.Page
+	undo       map[string][]storage.Action
+	colls      map[string][]*storage.Collection
+	shares     map[string]storage.Share
+	userChats  map[string]int
+	feeds      map[string]string // Feed token of each user.
+	feedOwners map[string]string // User of each feed token.
+	apiTokens  map[string]string // Hash of the API token of each user.
+	apiOwners  map[string]string // User of each API token hash.
+	subs       map[string][]*storage.Subscription
+	digests    map[digestKey]storage.Digest
+	lastID     int
+
+	randMu sync.Mutex
+	rnd    *rand.Rand
+}
+
+// digestKey identifies the digest of an owner in a chat.
+type digestKey struct {
+	chatID int
+	owner  string
 }
 
 // New creates a new in-memory storage.
 func New() *Storage {
 	return &Storage{
-		pages: make(map[string][]*storage.Page),
+		pages:      make(map[string][]*storage.Page),
+		chatModes:  make(map[int]storage.ListMode),
+		strategies: make(map[string]storage.Strategy),
+		served:     make(map[string][]string),
+		trash:      make(map[string][]*storage.Page),
+		undo:       make(map[string][]storage.Action),
+		colls:      make(map[string][]*storage.Collection),
+		shares:     make(map[string]storage.Share),
+		userChats:  make(map[string]int),
+		feeds:      make(map[string]string),
+		feedOwners: make(map[string]string),
+		apiTokens:  make(map[string]string),
+		apiOwners:  make(map[string]string),
+		subs:       make(map[string][]*storage.Subscription),
+		digests:    make(map[digestKey]storage.Digest),
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
-// Save stores a page for a given user.
+// SetRand replaces the source of random choices, e.g. with a seeded one in tests.
+func (s *Storage) SetRand(r *rand.Rand) {
+	s.randMu.Lock()
+	defer s.randMu.Unlock()
+
+	s.rnd = r
+}
+
+// Save stores a page for a given user and sets its ID.
 func (s *Storage) Save(p *storage.Page) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -37,28 +99,152 @@ func (s *Storage) Save(p *storage.Page) error {
 		}
 	}
 
-	s.pages[p.UserName] = append(s.pages[p.UserName], p)
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+
+	s.lastID++
+	p.ID = s.lastID
+
+	s.pages[p.UserName] = append(s.pages[p.UserName], p.Clone())
 	return nil
 }
 
-// GetRandomUnread returns a random unread page for a user.
-func (s *Storage) GetRandomUnread(userName string) (*storage.Page, error) {
+// SaveBatch stores several pages at once, skipping pages that are already saved
+// or repeated in the batch. It returns the number of pages actually added.
+// Only the added pages get an ID.
+func (s *Storage) SaveBatch(pages []*storage.Page) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]map[string]struct{})
+	added := 0
+	now := time.Now()
+
+	for _, p := range pages {
+		if p == nil {
+			return added, ErrNilPage
+		}
+
+		urls, ok := seen[p.UserName]
+		if !ok {
+			urls = make(map[string]struct{}, len(s.pages[p.UserName]))
+			for _, page := range s.pages[p.UserName] {
+				urls[page.URL] = struct{}{}
+			}
+			seen[p.UserName] = urls
+		}
+
+		if _, ok := urls[p.URL]; ok {
+			continue
+		}
+		urls[p.URL] = struct{}{}
+
+		if p.CreatedAt.IsZero() {
+			p.CreatedAt = now
+		}
+
+		s.lastID++
+		p.ID = s.lastID
+
+		s.pages[p.UserName] = append(s.pages[p.UserName], p.Clone())
+		added++
+	}
+
+	return added, nil
+}
+
+// Get returns the user's page with the given ID.
+func (s *Storage) Get(userName string, id int) (*storage.Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, page := range s.pages[userName] {
+		if page.ID == id {
+			return page.Clone(), nil
+		}
+	}
+	return nil, storage.ErrNoPagesFound
+}
+
+// PickUnread selects an unread page of a user with the given strategy among
+// the pages matching the filter. Snoozed pages are skipped.
+func (s *Storage) PickUnread(userName string, strategy storage.Strategy, filter storage.Filter) (*storage.Page, error) {
+	pages, err := s.PickUnreadN(userName, 1, strategy, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return pages[0], nil
+}
+
+// PickUnreadN selects up to n distinct unread pages of a user with the given
+// strategy among the pages matching the filter. Snoozed pages are skipped.
+func (s *Storage) PickUnreadN(userName string, n int, strategy storage.Strategy, filter storage.Filter) ([]*storage.Page, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	now := time.Now()
 	pages := s.pages[userName]
 	unread := make([]*storage.Page, 0, len(pages))
 	for _, p := range pages {
-		if !p.Read {
+		if !p.Read && !p.Snoozed(now) && filter.Match(p) {
 			unread = append(unread, p)
 		}
 	}
 
-	if len(unread) == 0 {
+	if len(unread) == 0 || n < 1 {
 		return nil, storage.ErrNoPagesFound
 	}
 
-	return unread[rand.Intn(len(unread))], nil
+	s.randMu.Lock()
+	picked := strategy.PickN(unread, n, now, s.rnd)
+	s.randMu.Unlock()
+
+	res := make([]*storage.Page, 0, len(picked))
+	for _, p := range picked {
+		res = append(res, p.Clone())
+	}
+	return res, nil
+}
+
+// MarkServed records that the pages were sent to the user at the given time:
+// their serve time and count are updated and they are appended to the user's
+// history of served pages.
+func (s *Storage) MarkServed(userName string, pageURLs []string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pageURL := range pageURLs {
+		for _, page := range s.pages[userName] {
+			if page.URL == pageURL {
+				page.ServedAt = at
+				page.ServedCount++
+				break
+			}
+		}
+	}
+
+	history := append(s.served[userName], pageURLs...)
+	if len(history) > maxServedHistory {
+		history = append([]string(nil), history[len(history)-maxServedHistory:]...)
+	}
+	s.served[userName] = history
+
+	return nil
+}
+
+// RecentlyServed returns the URLs of the last k pages served to the user, oldest first.
+// Only the last maxServedHistory serves are remembered.
+func (s *Storage) RecentlyServed(userName string, k int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.served[userName]
+	if k < len(history) {
+		history = history[len(history)-max(k, 0):]
+	}
+	return append([]string(nil), history...), nil
 }
 
 // MarkAsRead marks a page as read.
@@ -72,13 +258,61 @@ func (s *Storage) MarkAsRead(p *storage.Page) error {
 
 	for _, page := range s.pages[p.UserName] {
 		if page.URL == p.URL {
-			page.Read = true
+			if !page.Read {
+				page.Read = true
+				page.ReadAt = time.Now()
+				page.History = append(page.History, storage.Change{Kind: storage.ActionRead, At: page.ReadAt})
+			}
+			return nil
+		}
+	}
+	return storage.ErrNoPagesFound
+}
+
+// Update applies fn to the stored page with the given owner and URL.
+// The ID, owner, URL and creation time of the page cannot be changed by fn.
+func (s *Storage) Update(userName, pageURL string, fn func(p *storage.Page)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, page := range s.pages[userName] {
+		if page.URL == pageURL {
+			updated := page.Clone()
+			fn(updated)
+			updated.ID, updated.UserName, updated.URL, updated.CreatedAt = page.ID, page.UserName, page.URL, page.CreatedAt
+			s.pages[userName][i] = updated
 			return nil
 		}
 	}
 	return storage.ErrNoPagesFound
 }
 
+// ChangeURL changes the URL of a page in place, keeping its ID and everything
+// else about it. It fails with storage.ErrPageExists if newURL is already saved.
+func (s *Storage) ChangeURL(userName, oldURL, newURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := -1
+	for i, page := range s.pages[userName] {
+		switch page.URL {
+		case newURL:
+			return storage.ErrPageExists
+		case oldURL:
+			idx = i
+		}
+	}
+
+	if idx < 0 {
+		return storage.ErrNoPagesFound
+	}
+
+	updated := s.pages[userName][idx].Clone()
+	updated.URL = newURL
+	s.pages[userName][idx] = updated
+	return nil
+}
+
 // IsExists checks whether a page is already stored.
 func (s *Storage) IsExists(p *storage.Page) (bool, error) {
 	s.mu.RLock()
@@ -96,7 +330,8 @@ func (s *Storage) IsExists(p *storage.Page) (bool, error) {
 	return false, nil
 }
 
-// Remove deletes a page.
+// Remove moves a page to the trash. A page with the same URL that is
+// already in the trash is replaced.
 func (s *Storage) Remove(p *storage.Page) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -109,20 +344,733 @@ func (s *Storage) Remove(p *storage.Page) error {
 	for i, page := range pages {
 		if page.URL == p.URL {
 			s.pages[p.UserName] = append(pages[:i], pages[i+1:]...)
+
+			page.DeletedAt = time.Now()
+			trash := removeURL(s.trash[p.UserName], page.URL)
+			s.trash[p.UserName] = append(trash, page)
 			return nil
 		}
 	}
 	return storage.ErrNoPagesFound
 }
 
-// List returns all pages saved by the specified user.
-func (s *Storage) List(userName string) ([]*storage.Page, error) {
+// Trash returns the trashed pages of a user, most recently deleted first.
+func (s *Storage) Trash(userName string) ([]*storage.Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trash := s.trash[userName]
+	res := make([]*storage.Page, 0, len(trash))
+	for i := len(trash) - 1; i >= 0; i-- {
+		res = append(res, trash[i].Clone())
+	}
+	return res, nil
+}
+
+// Restore moves a page from the trash back to the user's list.
+// It fails with storage.ErrPageExists if the URL has been saved again since.
+func (s *Storage) Restore(userName, pageURL string) (*storage.Page, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, page := range s.trash[userName] {
+		if page.URL != pageURL {
+			continue
+		}
+
+		for _, active := range s.pages[userName] {
+			if active.URL == pageURL {
+				return nil, storage.ErrPageExists
+			}
+		}
+
+		s.trash[userName] = removeURL(s.trash[userName], pageURL)
+		page.DeletedAt = time.Time{}
+		s.pages[userName] = append(s.pages[userName], page)
+		return page.Clone(), nil
+	}
+	return nil, storage.ErrNoPagesFound
+}
+
+// PurgeTrash permanently deletes the pages of all owners that were moved to
+// the trash before the given time and returns them.
+func (s *Storage) PurgeTrash(before time.Time) ([]*storage.Page, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	var res []*storage.Page
+	for userName, trash := range s.trash {
+		kept := trash[:0]
+		for _, page := range trash {
+			if page.DeletedAt.Before(before) {
+				res = append(res, page.Clone())
+				for _, c := range s.colls[userName] {
+					c.Remove(page.ID)
+				}
+				continue
+			}
+			kept = append(kept, page)
+		}
+		s.trash[userName] = kept
+	}
+	return res, nil
+}
+
+// removeURL returns pages without the page with the given URL.
+func removeURL(pages []*storage.Page, pageURL string) []*storage.Page {
+	for i, page := range pages {
+		if page.URL == pageURL {
+			return append(pages[:i], pages[i+1:]...)
+		}
+	}
+	return pages
+}
+
+// PushUndo appends an action to the user's undo log. Only the last
+// maxUndo actions are kept.
+func (s *Storage) PushUndo(userName string, a storage.Action) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a.Tags = append([]string(nil), a.Tags...)
+
+	log := append(s.undo[userName], a)
+	if len(log) > maxUndo {
+		log = append([]storage.Action(nil), log[len(log)-maxUndo:]...)
+	}
+	s.undo[userName] = log
+
+	return nil
+}
+
+// PopUndo removes and returns the last action of the user's undo log.
+func (s *Storage) PopUndo(userName string) (*storage.Action, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log := s.undo[userName]
+	if len(log) == 0 {
+		return nil, storage.ErrNoUndo
+	}
+
+	a := log[len(log)-1]
+	s.undo[userName] = log[:len(log)-1]
+	return &a, nil
+}
+
+// List returns all pages saved by the specified user.
+func (s *Storage) List(userName string) ([]*storage.Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	pages := s.pages[userName]
 	if len(pages) == 0 {
 		return nil, storage.ErrNoPagesFound
 	}
-	return pages, nil
+
+	res := make([]*storage.Page, 0, len(pages))
+	for _, page := range pages {
+		res = append(res, page.Clone())
+	}
+	return res, nil
+}
+
+// ListAll returns the pages of all owners.
+func (s *Storage) ListAll() ([]*storage.Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []*storage.Page
+	for _, pages := range s.pages {
+		for _, page := range pages {
+			res = append(res, page.Clone())
+		}
+	}
+	return res, nil
+}
+
+// Strategy returns the selection strategy chosen by a user, Uniform by default.
+func (s *Storage) Strategy(userName string) (storage.Strategy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.strategies[userName], nil
+}
+
+// SetStrategy stores the selection strategy of a user.
+func (s *Storage) SetStrategy(userName string, strategy storage.Strategy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.strategies[userName] = strategy
+	return nil
+}
+
+// DueSnoozes returns the snoozed pages of all owners whose snooze has ended by now.
+func (s *Storage) DueSnoozes(now time.Time) ([]*storage.Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []*storage.Page
+	for _, pages := range s.pages {
+		for _, page := range pages {
+			if !page.SnoozedUntil.IsZero() && !page.Snoozed(now) {
+				res = append(res, page.Clone())
+			}
+		}
+	}
+	return res, nil
+}
+
+// Stats aggregates the reading statistics of a user in a single pass over their pages.
+func (s *Storage) Stats(userName string, q storage.StatsQuery) (*storage.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res storage.Stats
+	var toRead time.Duration
+	timed := 0
+	domains := make(map[string]int)
+
+	for _, page := range s.pages[userName] {
+		res.Total++
+
+		if !page.CreatedAt.Before(q.Week) {
+			res.SavedThisWeek++
+		}
+		if !page.CreatedAt.Before(q.Month) {
+			res.SavedThisMonth++
+		}
+
+		if domain := storage.Domain(page.URL); domain != "" {
+			domains[domain]++
+		}
+
+		if !page.Read {
+			res.Unread++
+			continue
+		}
+
+		res.Read++
+
+		if page.ReadAt.IsZero() {
+			continue
+		}
+		if !page.ReadAt.Before(q.Week) {
+			res.ReadThisWeek++
+		}
+		if !page.ReadAt.Before(q.Month) {
+			res.ReadThisMonth++
+		}
+		if !page.ReadAt.Before(page.CreatedAt) {
+			toRead += page.ReadAt.Sub(page.CreatedAt)
+			timed++
+		}
+	}
+
+	if timed > 0 {
+		res.AvgTimeToRead = toRead / time.Duration(timed)
+	}
+
+	for domain, count := range domains {
+		res.TopDomains = append(res.TopDomains, storage.DomainCount{Domain: domain, Count: count})
+	}
+	sort.Slice(res.TopDomains, func(i, j int) bool {
+		a, b := res.TopDomains[i], res.TopDomains[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Domain < b.Domain
+	})
+	if len(res.TopDomains) > q.TopDomains {
+		res.TopDomains = res.TopDomains[:max(q.TopDomains, 0)]
+	}
+
+	return &res, nil
+}
+
+// ReadDays returns the distinct days on which the user read a page, newest first.
+// Each day is midnight in loc.
+func (s *Storage) ReadDays(userName string, loc *time.Location) ([]time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[time.Time]bool)
+	var res []time.Time
+
+	for _, page := range s.pages[userName] {
+		if !page.Read || page.ReadAt.IsZero() {
+			continue
+		}
+
+		y, m, d := page.ReadAt.In(loc).Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		if !seen[day] {
+			seen[day] = true
+			res = append(res, day)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].After(res[j]) })
+	return res, nil
+}
+
+// ChatMode returns the list mode configured for a chat. Chats without
+// a configured mode use personal lists.
+func (s *Storage) ChatMode(chatID int) (storage.ListMode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.chatModes[chatID], nil
+}
+
+// SetChatMode stores the list mode for a chat.
+func (s *Storage) SetChatMode(chatID int, mode storage.ListMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chatModes[chatID] = mode
+	return nil
+}
+
+// Digest returns the digest of an owner in a chat.
+func (s *Storage) Digest(chatID int, owner string) (*storage.Digest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.digests[digestKey{chatID: chatID, owner: owner}]
+	if !ok {
+		return nil, storage.ErrNoDigest
+	}
+	return &d, nil
+}
+
+// SaveDigest creates or replaces the digest of an owner in a chat.
+func (s *Storage) SaveDigest(d *storage.Digest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d == nil {
+		return ErrNilDigest
+	}
+
+	s.digests[digestKey{chatID: d.ChatID, owner: d.Owner}] = *d
+	return nil
+}
+
+// RemoveDigest deletes the digest of an owner in a chat.
+func (s *Storage) RemoveDigest(chatID int, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := digestKey{chatID: chatID, owner: owner}
+	if _, ok := s.digests[key]; !ok {
+		return storage.ErrNoDigest
+	}
+
+	delete(s.digests, key)
+	return nil
+}
+
+// DueDigests returns the digests whose next send time is not after now.
+func (s *Storage) DueDigests(now time.Time) ([]*storage.Digest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []*storage.Digest
+	for _, d := range s.digests {
+		if !d.NextAt.After(now) {
+			d := d
+			res = append(res, &d)
+		}
+	}
+	return res, nil
+}
+
+// AdvanceDigest moves the next send time of a digest from one time to another.
+// It reports false if the digest is gone or its next send time is no longer from,
+// so that only one caller can claim a send.
+func (s *Storage) AdvanceDigest(chatID int, owner string, from, to time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := digestKey{chatID: chatID, owner: owner}
+	d, ok := s.digests[key]
+	if !ok || !d.NextAt.Equal(from) {
+		return false, nil
+	}
+
+	d.NextAt = to
+	s.digests[key] = d
+	return true, nil
+}
+
+// Collections returns the collections a user owns or is a member of,
+// sorted by name.
+func (s *Storage) Collections(userName string) ([]*storage.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]*storage.Collection, 0, len(s.colls[userName]))
+	for _, colls := range s.colls {
+		for _, c := range colls {
+			if c.Access(userName) != storage.NoAccess {
+				res = append(res, c.Clone())
+			}
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return strings.ToLower(res[i].Name) < strings.ToLower(res[j].Name) })
+	return res, nil
+}
+
+// Collection returns the collection with the given name, regardless of case.
+// The actor must be the owner or a member, otherwise storage.ErrForbidden is returned.
+func (s *Storage) Collection(actor, owner, name string) (*storage.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, err := s.access(actor, owner, name, storage.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	return c.Clone(), nil
+}
+
+// CreateCollection creates an empty collection.
+func (s *Storage) CreateCollection(owner, name string) (*storage.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.collection(owner, name) != nil {
+		return nil, storage.ErrCollectionExists
+	}
+
+	c := &storage.Collection{Owner: owner, Name: name, CreatedAt: time.Now()}
+	s.colls[owner] = append(s.colls[owner], c)
+	return c.Clone(), nil
+}
+
+// UpdateCollection applies fn to the collection with the given name on behalf
+// of actor. The owner may change anything but the owner; collaborators may
+// only change the pages. Read-only members and other users get storage.ErrForbidden.
+// Renaming to the name of another collection fails with storage.ErrCollectionExists.
+func (s *Storage) UpdateCollection(actor, owner, name string, fn func(c *storage.Collection)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.access(actor, owner, name, storage.Collaborator)
+	if err != nil {
+		return err
+	}
+
+	updated := c.Clone()
+	fn(updated)
+	updated.Owner, updated.CreatedAt = c.Owner, c.CreatedAt
+	updated.Members = lowerKeys(updated.Members)
+
+	if c.Access(actor) != storage.FullAccess &&
+		(updated.Name != c.Name || !maps.Equal(updated.Members, c.Members)) {
+		return storage.ErrForbidden
+	}
+
+	if other := s.collection(owner, updated.Name); other != nil && other != c {
+		return storage.ErrCollectionExists
+	}
+
+	*c = *updated
+	return nil
+}
+
+// RemoveCollection deletes a collection on behalf of actor. Its pages are kept.
+// Only the owner may delete it; members get storage.ErrForbidden.
+func (s *Storage) RemoveCollection(actor, owner, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.access(actor, owner, name, storage.FullAccess)
+	if err != nil {
+		return err
+	}
+
+	s.colls[owner] = slices.DeleteFunc(s.colls[owner], func(other *storage.Collection) bool {
+		return other == c
+	})
+	return nil
+}
+
+// NextInCollection returns the first page of the collection that is unread
+// and not snoozed. Pages in the trash are skipped. The actor must be the
+// owner or a member, otherwise storage.ErrForbidden is returned.
+func (s *Storage) NextInCollection(actor, owner, name string) (*storage.Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, err := s.access(actor, owner, name, storage.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, id := range c.PageIDs {
+		for _, page := range s.pages[owner] {
+			if page.ID == id && !page.Read && !page.Snoozed(now) {
+				return page.Clone(), nil
+			}
+		}
+	}
+	return nil, storage.ErrNoPagesFound
+}
+
+// access returns the stored collection if the actor has at least the given
+// permission to it. The caller must hold the lock.
+func (s *Storage) access(actor, owner, name string, need storage.Permission) (*storage.Collection, error) {
+	c := s.collection(owner, name)
+	if c == nil {
+		return nil, storage.ErrNoCollection
+	}
+
+	perm := c.Access(actor)
+	if perm == storage.NoAccess {
+		// Collections shared with nobody else look the same as missing ones.
+		return nil, storage.ErrNoCollection
+	}
+	if perm < need {
+		return nil, storage.ErrForbidden
+	}
+	return c, nil
+}
+
+// lowerKeys returns the members keyed by lowercase username.
+func lowerKeys(members map[string]storage.Permission) map[string]storage.Permission {
+	if members == nil {
+		return nil
+	}
+
+	res := make(map[string]storage.Permission, len(members))
+	for user, perm := range members {
+		res[strings.ToLower(user)] = perm
+	}
+	return res
+}
+
+// collection returns the stored collection with the given name regardless
+// of case, or nil. The caller must hold the lock.
+func (s *Storage) collection(owner, name string) *storage.Collection {
+	for _, c := range s.colls[owner] {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// SaveShare stores a share under its token, replacing any share with the same token.
+func (s *Storage) SaveShare(share *storage.Share) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shares[share.Token] = *share
+	return nil
+}
+
+// Share returns the share with the given token.
+func (s *Storage) Share(token string) (*storage.Share, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	share, ok := s.shares[token]
+	if !ok {
+		return nil, storage.ErrNoShare
+	}
+	return &share, nil
+}
+
+// RemoveShare deletes the share with the given token.
+func (s *Storage) RemoveShare(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.shares[token]; !ok {
+		return storage.ErrNoShare
+	}
+	delete(s.shares, token)
+	return nil
+}
+
+// SetUserChat records the private chat of a user, so the bot can message them later.
+func (s *Storage) SetUserChat(userName string, chatID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.userChats[strings.ToLower(userName)] = chatID
+	return nil
+}
+
+// UserChat returns the private chat of a user, regardless of the case of the name.
+func (s *Storage) UserChat(userName string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chatID, ok := s.userChats[strings.ToLower(userName)]
+	if !ok {
+		return 0, storage.ErrUnknownUser
+	}
+	return chatID, nil
+}
+
+// FeedToken returns the hash of the feed token of a user.
+func (s *Storage) FeedToken(userName string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.feeds[userName]
+	if !ok {
+		return "", storage.ErrNoFeedToken
+	}
+	return token, nil
+}
+
+// SetFeedToken sets the hash of the feed token of a user. The previous token
+// stops working.
+func (s *Storage) SetFeedToken(userName, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.feeds[userName]; ok {
+		delete(s.feedOwners, old)
+	}
+
+	s.feeds[userName] = tokenHash
+	s.feedOwners[tokenHash] = userName
+	return nil
+}
+
+// FeedOwner returns the user whose feed token has the given hash.
+func (s *Storage) FeedOwner(tokenHash string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userName, ok := s.feedOwners[tokenHash]
+	if !ok {
+		return "", storage.ErrNoFeedToken
+	}
+	return userName, nil
+}
+
+// SetAPIToken sets the hash of the API token of a user. The previous token
+// stops working; an empty hash only revokes it.
+func (s *Storage) SetAPIToken(userName, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.apiTokens[userName]; ok {
+		delete(s.apiOwners, old)
+		delete(s.apiTokens, userName)
+	}
+
+	if tokenHash != "" {
+		s.apiTokens[userName] = tokenHash
+		s.apiOwners[tokenHash] = userName
+	}
+	return nil
+}
+
+// APITokenOwner returns the user an API token hash belongs to.
+func (s *Storage) APITokenOwner(tokenHash string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userName, ok := s.apiOwners[tokenHash]
+	if !ok {
+		return "", storage.ErrNoAPIToken
+	}
+	return userName, nil
+}
+
+// Subscribe stores a new subscription. An owner can subscribe to a feed
+// only once, otherwise storage.ErrSubscriptionExists is returned.
+func (s *Storage) Subscribe(sub *storage.Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subscription(sub.Owner, sub.FeedURL) != nil {
+		return storage.ErrSubscriptionExists
+	}
+
+	cp := sub.Clone()
+	if cp.CreatedAt.IsZero() {
+		cp.CreatedAt = time.Now()
+	}
+
+	s.subs[sub.Owner] = append(s.subs[sub.Owner], cp)
+	return nil
+}
+
+// Unsubscribe deletes the owner's subscription to a feed.
+func (s *Storage) Unsubscribe(owner, feedURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := s.subs[owner]
+	for i, sub := range subs {
+		if sub.FeedURL == feedURL {
+			s.subs[owner] = append(subs[:i], subs[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrNoSubscription
+}
+
+// Subscriptions returns the subscriptions of an owner, oldest first.
+func (s *Storage) Subscriptions(owner string) ([]*storage.Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]*storage.Subscription, 0, len(s.subs[owner]))
+	for _, sub := range s.subs[owner] {
+		res = append(res, sub.Clone())
+	}
+	return res, nil
+}
+
+// AllSubscriptions returns the subscriptions of all owners.
+func (s *Storage) AllSubscriptions() ([]*storage.Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []*storage.Subscription
+	for _, subs := range s.subs {
+		for _, sub := range subs {
+			res = append(res, sub.Clone())
+		}
+	}
+	return res, nil
+}
+
+// UpdateSubscription applies fn to the owner's subscription to a feed.
+// The owner, the feed URL and the creation time cannot be changed.
+func (s *Storage) UpdateSubscription(owner, feedURL string, fn func(sub *storage.Subscription)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := s.subscription(owner, feedURL)
+	if sub == nil {
+		return storage.ErrNoSubscription
+	}
+
+	updated := sub.Clone()
+	fn(updated)
+	updated.Owner, updated.FeedURL, updated.CreatedAt = sub.Owner, sub.FeedURL, sub.CreatedAt
+
+	*sub = *updated
+	return nil
+}
+
+// subscription returns the stored subscription of the owner to a feed, or nil.
+// The caller must hold the lock.
+func (s *Storage) subscription(owner, feedURL string) *storage.Subscription {
+	for _, sub := range s.subs[owner] {
+		if sub.FeedURL == feedURL {
+			return sub
+		}
+	}
+	return nil
 }
@@ -3,7 +3,13 @@ package memory_test
 import (
 	"URLbot/pkg/storage"
 	"URLbot/pkg/storage/memory"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestStorage_Save(t *testing.T) {
@@ -99,11 +105,12 @@ func TestStorage_Save(t *testing.T) {
 	}
 }
 
-func TestStorage_GetRandomUnread(t *testing.T) {
+func TestStorage_PickUnread(t *testing.T) {
 	tests := []struct {
 		name     string
 		userName string
 		page     *storage.Page
+		filter   storage.Filter
 		wantErr  bool
 	}{
 		{
@@ -136,6 +143,48 @@ func TestStorage_GetRandomUnread(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name:     "snoozed page",
+			userName: "Alex",
+			page: &storage.Page{
+				URL:          "https://example.com",
+				UserName:     "Alex",
+				SnoozedUntil: time.Now().Add(time.Hour),
+			},
+			wantErr: true,
+		},
+		{
+			name:     "snooze ended",
+			userName: "Alex",
+			page: &storage.Page{
+				URL:          "https://example.com",
+				UserName:     "Alex",
+				SnoozedUntil: time.Now().Add(-time.Hour),
+			},
+			wantErr: false,
+		},
+		{
+			name:     "filter matches",
+			userName: "Alex",
+			page: &storage.Page{
+				URL:         "https://example.com",
+				UserName:    "Alex",
+				ReadingTime: 3 * time.Minute,
+			},
+			filter:  func(p *storage.Page) bool { return p.ReadingTime < 5*time.Minute },
+			wantErr: false,
+		},
+		{
+			name:     "filter does not match",
+			userName: "Alex",
+			page: &storage.Page{
+				URL:         "https://example.com",
+				UserName:    "Alex",
+				ReadingTime: 30 * time.Minute,
+			},
+			filter:  func(p *storage.Page) bool { return p.ReadingTime < 5*time.Minute },
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -146,16 +195,16 @@ func TestStorage_GetRandomUnread(t *testing.T) {
 				t.Fatalf("failed to save page: %v", err)
 			}
 
-			got, gotErr := s.GetRandomUnread(tt.userName)
+			got, gotErr := s.PickUnread(tt.userName, storage.Uniform, tt.filter)
 			if gotErr != nil {
 				if !tt.wantErr {
-					t.Errorf("GetRandomUnread() failed: %v", gotErr)
+					t.Errorf("PickUnread() failed: %v", gotErr)
 				}
 				return
 			}
 
 			if tt.wantErr {
-				t.Fatal("GetRandomUnread() succeeded unexpectedly")
+				t.Fatal("PickUnread() succeeded unexpectedly")
 			}
 
 			if got.UserName != tt.userName {
@@ -302,3 +351,961 @@ func TestStorage_Remove(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_ChatMode(t *testing.T) {
+	s := memory.New()
+
+	mode, err := s.ChatMode(-100)
+	if err != nil {
+		t.Fatalf("ChatMode() failed: %v", err)
+	}
+	if mode != storage.Personal {
+		t.Errorf("default mode = %v, want %v", mode, storage.Personal)
+	}
+
+	err = s.SetChatMode(-100, storage.Shared)
+	if err != nil {
+		t.Fatalf("SetChatMode() failed: %v", err)
+	}
+
+	mode, err = s.ChatMode(-100)
+	if err != nil {
+		t.Fatalf("ChatMode() failed: %v", err)
+	}
+	if mode != storage.Shared {
+		t.Errorf("mode = %v, want %v", mode, storage.Shared)
+	}
+}
+
+func TestStorage_SaveBatch(t *testing.T) {
+	s := memory.New()
+
+	err := s.Save(&storage.Page{URL: "https://a.com", UserName: "Alex"})
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	added, err := s.SaveBatch([]*storage.Page{
+		{URL: "https://a.com", UserName: "Alex"},
+		{URL: "https://b.com", UserName: "Alex"},
+		{URL: "https://b.com", UserName: "Alex"},
+		{URL: "https://a.com", UserName: "Bob"},
+	})
+	if err != nil {
+		t.Fatalf("SaveBatch() failed: %v", err)
+	}
+
+	if added != 2 {
+		t.Errorf("SaveBatch() added %d pages, want 2", added)
+	}
+
+	pages, err := s.List("Alex")
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+
+	if len(pages) != 2 {
+		t.Errorf("Alex has %d pages, want 2", len(pages))
+	}
+}
+
+func TestStorage_Update(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		url     string
+		wantErr bool
+	}{
+		{
+			name: "existing page",
+			user: "Alex",
+			url:  "https://example.com",
+		},
+		{
+			name:    "unknown page",
+			user:    "Alex",
+			url:     "https://example.org",
+			wantErr: true,
+		},
+		{
+			name:    "another user",
+			user:    "Bob",
+			url:     "https://example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := memory.New()
+
+			err := s.Save(&storage.Page{URL: "https://example.com", UserName: "Alex"})
+			if err != nil {
+				t.Fatalf("failed to save page: %v", err)
+			}
+
+			err = s.Update(tt.user, tt.url, func(p *storage.Page) {
+				p.Title = "Example"
+				p.UserName = "Mallory"
+			})
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("Update() failed: %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Update() succeeded unexpectedly")
+			}
+
+			pages, err := s.List("Alex")
+			if err != nil {
+				t.Fatalf("List() failed: %v", err)
+			}
+
+			if pages[0].Title != "Example" || pages[0].UserName != "Alex" || pages[0].CreatedAt.IsZero() {
+				t.Errorf("page after update = %+v", pages[0])
+			}
+		})
+	}
+}
+
+func TestStorage_ChangeURL(t *testing.T) {
+	s := memory.New()
+
+	for _, u := range []string{"http://old.com", "https://taken.com"} {
+		err := s.Save(&storage.Page{URL: u, UserName: "Alex", Tags: []string{"go"}})
+		if err != nil {
+			t.Fatalf("failed to save page: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		oldURL  string
+		newURL  string
+		wantErr error
+	}{
+		{name: "target already saved", oldURL: "http://old.com", newURL: "https://taken.com", wantErr: storage.ErrPageExists},
+		{name: "unknown page", oldURL: "http://unknown.com", newURL: "https://new.com", wantErr: storage.ErrNoPagesFound},
+		{name: "moved", oldURL: "http://old.com", newURL: "https://new.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ChangeURL("Alex", tt.oldURL, tt.newURL)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangeURL() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	page, err := s.Get("Alex", 1)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if page.URL != "https://new.com" || len(page.Tags) != 1 {
+		t.Errorf("page after ChangeURL() = %+v, want the same page at the new URL", page)
+	}
+}
+
+func TestStorage_Get(t *testing.T) {
+	s := memory.New()
+
+	first := &storage.Page{URL: "https://a.com", UserName: "Alex"}
+	second := &storage.Page{URL: "https://b.com", UserName: "Alex"}
+
+	for _, p := range []*storage.Page{first, second} {
+		err := s.Save(p)
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	if first.ID == 0 || second.ID == first.ID {
+		t.Fatalf("Save() assigned IDs %d and %d, want distinct non-zero IDs", first.ID, second.ID)
+	}
+
+	tests := []struct {
+		name    string
+		user    string
+		id      int
+		wantURL string
+		wantErr bool
+	}{
+		{name: "first page", user: "Alex", id: first.ID, wantURL: "https://a.com"},
+		{name: "second page", user: "Alex", id: second.ID, wantURL: "https://b.com"},
+		{name: "another user", user: "Bob", id: first.ID, wantErr: true},
+		{name: "unknown id", user: "Alex", id: 100, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Get(tt.user, tt.id)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("Get() failed: %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("Get() succeeded unexpectedly")
+			}
+
+			if got.URL != tt.wantURL {
+				t.Errorf("Get() = %s, want %s", got.URL, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestStorage_ListAll(t *testing.T) {
+	s := memory.New()
+
+	pages, err := s.ListAll()
+	if err != nil || len(pages) != 0 {
+		t.Fatalf("ListAll() on empty storage = %v, %v; want no pages", pages, err)
+	}
+
+	for _, p := range []*storage.Page{
+		{URL: "https://a.com", UserName: "Alex"},
+		{URL: "https://a.com", UserName: "Bob"},
+		{URL: "https://b.com", UserName: "Bob"},
+	} {
+		err := s.Save(p)
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	pages, err = s.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() failed: %v", err)
+	}
+
+	if len(pages) != 3 {
+		t.Errorf("ListAll() returned %d pages, want 3", len(pages))
+	}
+}
+
+func TestStorage_Digests(t *testing.T) {
+	s := memory.New()
+	now := time.Now()
+
+	_, err := s.Digest(1, "Alex")
+	if !errors.Is(err, storage.ErrNoDigest) {
+		t.Fatalf("Digest() error = %v, want %v", err, storage.ErrNoDigest)
+	}
+
+	for _, d := range []*storage.Digest{
+		{ChatID: 1, Owner: "Alex", Count: 3, NextAt: now.Add(-time.Minute)},
+		{ChatID: 1, Owner: "Bob", Count: 3, NextAt: now.Add(time.Hour)},
+	} {
+		err := s.SaveDigest(d)
+		if err != nil {
+			t.Fatalf("SaveDigest() failed: %v", err)
+		}
+	}
+
+	due, err := s.DueDigests(now)
+	if err != nil {
+		t.Fatalf("DueDigests() failed: %v", err)
+	}
+	if len(due) != 1 || due[0].Owner != "Alex" {
+		t.Fatalf("DueDigests() = %v, want Alex's digest", due)
+	}
+
+	next := now.Add(24 * time.Hour)
+
+	ok, err := s.AdvanceDigest(1, "Alex", due[0].NextAt, next)
+	if err != nil || !ok {
+		t.Fatalf("AdvanceDigest() = %v, %v; want true", ok, err)
+	}
+
+	ok, err = s.AdvanceDigest(1, "Alex", due[0].NextAt, next)
+	if err != nil || ok {
+		t.Fatalf("second AdvanceDigest() = %v, %v; want false", ok, err)
+	}
+
+	due, err = s.DueDigests(now)
+	if err != nil || len(due) != 0 {
+		t.Fatalf("DueDigests() after advance = %v, %v; want none", due, err)
+	}
+
+	err = s.RemoveDigest(1, "Alex")
+	if err != nil {
+		t.Fatalf("RemoveDigest() failed: %v", err)
+	}
+
+	_, err = s.Digest(1, "Alex")
+	if !errors.Is(err, storage.ErrNoDigest) {
+		t.Errorf("Digest() after remove error = %v, want %v", err, storage.ErrNoDigest)
+	}
+}
+
+func TestStorage_DueSnoozes(t *testing.T) {
+	s := memory.New()
+	now := time.Now()
+
+	for _, p := range []*storage.Page{
+		{URL: "https://a.com", UserName: "Alex"},
+		{URL: "https://b.com", UserName: "Alex", SnoozedUntil: now.Add(-time.Minute)},
+		{URL: "https://c.com", UserName: "Bob", SnoozedUntil: now.Add(time.Minute)},
+		{URL: "https://d.com", UserName: "Bob", SnoozedUntil: now},
+	} {
+		err := s.Save(p)
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	due, err := s.DueSnoozes(now)
+	if err != nil {
+		t.Fatalf("DueSnoozes() failed: %v", err)
+	}
+
+	got := make(map[string]bool)
+	for _, p := range due {
+		got[p.URL] = true
+	}
+
+	if len(got) != 2 || !got["https://b.com"] || !got["https://d.com"] {
+		t.Errorf("DueSnoozes() = %v, want b.com and d.com", got)
+	}
+}
+
+func TestStorage_SetRand(t *testing.T) {
+	picks := func() []string {
+		s := memory.New()
+		s.SetRand(rand.New(rand.NewSource(7)))
+
+		for _, u := range []string{"https://a.com", "https://b.com", "https://c.com", "https://d.com"} {
+			err := s.Save(&storage.Page{URL: u, UserName: "Alex"})
+			if err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+		}
+
+		var res []string
+		for i := 0; i < 10; i++ {
+			p, err := s.PickUnread("Alex", storage.Uniform, nil)
+			if err != nil {
+				t.Fatalf("PickUnread() failed: %v", err)
+			}
+			res = append(res, p.URL)
+		}
+		return res
+	}
+
+	first, second := picks(), picks()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("picks with the same seed differ: %v and %v", first, second)
+		}
+	}
+}
+
+func TestStorage_Strategy(t *testing.T) {
+	s := memory.New()
+
+	got, err := s.Strategy("Alex")
+	if err != nil || got != storage.Uniform {
+		t.Fatalf("Strategy() = %v, %v; want %v", got, err, storage.Uniform)
+	}
+
+	err = s.SetStrategy("Alex", storage.Spaced)
+	if err != nil {
+		t.Fatalf("SetStrategy() failed: %v", err)
+	}
+
+	got, err = s.Strategy("Alex")
+	if err != nil || got != storage.Spaced {
+		t.Errorf("Strategy() = %v, %v; want %v", got, err, storage.Spaced)
+	}
+
+	got, err = s.Strategy("Bob")
+	if err != nil || got != storage.Uniform {
+		t.Errorf("Strategy() of another user = %v, %v; want %v", got, err, storage.Uniform)
+	}
+}
+
+func TestStorage_PickUnreadN(t *testing.T) {
+	s := memory.New()
+
+	for _, u := range []string{"https://a.com", "https://b.com", "https://c.com"} {
+		err := s.Save(&storage.Page{URL: u, UserName: "Alex"})
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	err := s.MarkAsRead(&storage.Page{URL: "https://c.com", UserName: "Alex"})
+	if err != nil {
+		t.Fatalf("MarkAsRead() failed: %v", err)
+	}
+
+	pages, err := s.PickUnreadN("Alex", 5, storage.Uniform, nil)
+	if err != nil {
+		t.Fatalf("PickUnreadN() failed: %v", err)
+	}
+	if len(pages) != 2 || pages[0].URL == pages[1].URL {
+		t.Errorf("PickUnreadN() = %d pages, want the 2 distinct unread pages", len(pages))
+	}
+
+	pages, err = s.PickUnreadN("Alex", 1, storage.OldestFirst, nil)
+	if err != nil || len(pages) != 1 || pages[0].URL != "https://a.com" {
+		t.Errorf("PickUnreadN(1, oldest) = %v, %v; want https://a.com", pages, err)
+	}
+
+	_, err = s.PickUnreadN("Bob", 3, storage.Uniform, nil)
+	if !errors.Is(err, storage.ErrNoPagesFound) {
+		t.Errorf("PickUnreadN() for an empty list error = %v, want %v", err, storage.ErrNoPagesFound)
+	}
+}
+
+func TestStorage_MarkServed(t *testing.T) {
+	s := memory.New()
+
+	err := s.Save(&storage.Page{URL: "https://a.com", UserName: "Alex"})
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	for _, urls := range [][]string{{"https://a.com"}, {"https://b.com", "https://a.com"}} {
+		err = s.MarkServed("Alex", urls, at)
+		if err != nil {
+			t.Fatalf("MarkServed() failed: %v", err)
+		}
+	}
+
+	page, err := s.Get("Alex", 1)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if page.ServedCount != 2 || !page.ServedAt.Equal(at) {
+		t.Errorf("served page has ServedCount %d, ServedAt %v; want 2, %v", page.ServedCount, page.ServedAt, at)
+	}
+
+	tests := []struct {
+		k    int
+		want []string
+	}{
+		{k: 0, want: nil},
+		{k: 2, want: []string{"https://b.com", "https://a.com"}},
+		{k: 10, want: []string{"https://a.com", "https://b.com", "https://a.com"}},
+	}
+
+	for _, tt := range tests {
+		got, err := s.RecentlyServed("Alex", tt.k)
+		if err != nil {
+			t.Fatalf("RecentlyServed() failed: %v", err)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("RecentlyServed(%d) = %v, want %v", tt.k, got, tt.want)
+		}
+	}
+
+	for i := 0; i < 150; i++ {
+		_ = s.MarkServed("Bob", []string{"https://a.com"}, at)
+	}
+	got, _ := s.RecentlyServed("Bob", 1000)
+	if len(got) != 100 {
+		t.Errorf("RecentlyServed() kept %d entries, want 100", len(got))
+	}
+}
+
+func TestStorage_Stats(t *testing.T) {
+	s := memory.New()
+	week := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	month := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	pages := []*storage.Page{
+		{URL: "https://www.a.com/1", CreatedAt: week.Add(time.Hour), Read: true, ReadAt: week.Add(3 * time.Hour)},
+		{URL: "https://a.com/2", CreatedAt: month.Add(time.Hour), Read: true, ReadAt: month.Add(5 * time.Hour)},
+		{URL: "https://b.com/1", CreatedAt: month.Add(-time.Hour)},
+		{URL: "https://c.com/1", CreatedAt: week, Read: true},
+		{URL: "https://b.com/2", CreatedAt: week.Add(2 * time.Hour)},
+	}
+	for _, p := range pages {
+		p.UserName = "Alex"
+		err := s.Save(p)
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	got, err := s.Stats("Alex", storage.StatsQuery{Week: week, Month: month, TopDomains: 2})
+	if err != nil {
+		t.Fatalf("Stats() failed: %v", err)
+	}
+
+	want := storage.Stats{
+		Total: 5, Read: 3, Unread: 2,
+		SavedThisWeek: 3, ReadThisWeek: 1,
+		SavedThisMonth: 4, ReadThisMonth: 2,
+		AvgTimeToRead: 3 * time.Hour,
+	}
+	want.TopDomains = []storage.DomainCount{{Domain: "a.com", Count: 2}, {Domain: "b.com", Count: 2}}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("Stats() = %+v, want %+v", *got, want)
+	}
+
+	empty, err := s.Stats("Bob", storage.StatsQuery{Week: week, Month: month, TopDomains: 2})
+	if err != nil || empty.Total != 0 || len(empty.TopDomains) != 0 {
+		t.Errorf("Stats() for an empty list = %+v, %v; want zero stats", empty, err)
+	}
+}
+
+func TestStorage_ReadDays(t *testing.T) {
+	s := memory.New()
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	base := time.Date(2026, 3, 10, 22, 0, 0, 0, time.UTC) // 11 March 01:00 in UTC+3.
+
+	readAt := []time.Time{base, base.Add(time.Hour), base.Add(-48 * time.Hour), {}}
+	for i, at := range readAt {
+		err := s.Save(&storage.Page{URL: fmt.Sprintf("https://%d.com", i), UserName: "Alex", Read: true, ReadAt: at})
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	got, err := s.ReadDays("Alex", loc)
+	if err != nil {
+		t.Fatalf("ReadDays() failed: %v", err)
+	}
+
+	want := []time.Time{
+		time.Date(2026, 3, 11, 0, 0, 0, 0, loc),
+		time.Date(2026, 3, 9, 0, 0, 0, 0, loc),
+	}
+	if len(got) != len(want) || !got[0].Equal(want[0]) || !got[1].Equal(want[1]) {
+		t.Errorf("ReadDays() = %v, want %v", got, want)
+	}
+}
+
+func TestStorage_Trash(t *testing.T) {
+	s := memory.New()
+
+	for _, u := range []string{"https://a.com", "https://b.com"} {
+		err := s.Save(&storage.Page{URL: u, UserName: "Alex"})
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	err := s.Remove(&storage.Page{URL: "https://a.com", UserName: "Alex"})
+	if err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+
+	if _, err := s.Get("Alex", 1); !errors.Is(err, storage.ErrNoPagesFound) {
+		t.Errorf("Get() of a trashed page error = %v, want %v", err, storage.ErrNoPagesFound)
+	}
+	if all, _ := s.ListAll(); len(all) != 1 {
+		t.Errorf("ListAll() returned %d pages, want the trashed page left out", len(all))
+	}
+
+	trash, err := s.Trash("Alex")
+	if err != nil {
+		t.Fatalf("Trash() failed: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != 1 || trash[0].DeletedAt.IsZero() {
+		t.Fatalf("Trash() = %+v, want page 1 with its deletion time", trash)
+	}
+
+	page, err := s.Restore("Alex", "https://a.com")
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if page.ID != 1 || !page.DeletedAt.IsZero() {
+		t.Errorf("Restore() = %+v, want page 1 without a deletion time", page)
+	}
+
+	if _, err := s.Restore("Alex", "https://a.com"); !errors.Is(err, storage.ErrNoPagesFound) {
+		t.Errorf("Restore() of a page not in the trash error = %v, want %v", err, storage.ErrNoPagesFound)
+	}
+
+	_ = s.Remove(&storage.Page{URL: "https://a.com", UserName: "Alex"})
+	_ = s.Save(&storage.Page{URL: "https://a.com", UserName: "Alex"})
+	if _, err := s.Restore("Alex", "https://a.com"); !errors.Is(err, storage.ErrPageExists) {
+		t.Errorf("Restore() of a saved again page error = %v, want %v", err, storage.ErrPageExists)
+	}
+
+	purged, err := s.PurgeTrash(time.Now().Add(-time.Hour))
+	if err != nil || len(purged) != 0 {
+		t.Errorf("PurgeTrash() of recent pages = %v, %v; want nothing purged", purged, err)
+	}
+
+	purged, err = s.PurgeTrash(time.Now().Add(time.Hour))
+	if err != nil || len(purged) != 1 || purged[0].URL != "https://a.com" {
+		t.Errorf("PurgeTrash() = %v, %v; want the trashed page", purged, err)
+	}
+	if trash, _ := s.Trash("Alex"); len(trash) != 0 {
+		t.Errorf("Trash() after purge = %v, want empty", trash)
+	}
+}
+
+func TestStorage_Undo(t *testing.T) {
+	s := memory.New()
+
+	if _, err := s.PopUndo("Alex"); !errors.Is(err, storage.ErrNoUndo) {
+		t.Fatalf("PopUndo() of an empty log error = %v, want %v", err, storage.ErrNoUndo)
+	}
+
+	for i := 0; i < 25; i++ {
+		err := s.PushUndo("Alex", storage.Action{Kind: storage.ActionSave, URL: fmt.Sprintf("https://%d.com", i)})
+		if err != nil {
+			t.Fatalf("PushUndo() failed: %v", err)
+		}
+	}
+
+	var popped []string
+	for {
+		a, err := s.PopUndo("Alex")
+		if errors.Is(err, storage.ErrNoUndo) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("PopUndo() failed: %v", err)
+		}
+		popped = append(popped, a.URL)
+	}
+
+	if len(popped) != 20 || popped[0] != "https://24.com" || popped[19] != "https://5.com" {
+		t.Errorf("PopUndo() returned %v, want the last 20 actions, newest first", popped)
+	}
+}
+
+func TestStorage_History(t *testing.T) {
+	s := memory.New()
+	page := &storage.Page{URL: "https://a.com", UserName: "Alex"}
+
+	err := s.Save(page)
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = s.MarkAsRead(page)
+		if err != nil {
+			t.Fatalf("MarkAsRead() failed: %v", err)
+		}
+	}
+
+	got, err := s.Get("Alex", 1)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if len(got.History) != 1 || got.History[0].Kind != storage.ActionRead || !got.History[0].At.Equal(got.ReadAt) {
+		t.Fatalf("History = %+v, want a single read at %v", got.History, got.ReadAt)
+	}
+
+	got.History[0].Kind = storage.ActionUnread
+	again, _ := s.Get("Alex", 1)
+	if again.History[0].Kind != storage.ActionRead {
+		t.Error("changing a returned page changed the stored history")
+	}
+}
+
+func TestStorage_Collections(t *testing.T) {
+	s := memory.New()
+
+	for i := 1; i <= 3; i++ {
+		err := s.Save(&storage.Page{URL: fmt.Sprintf("https://%d.com", i), UserName: "Alex"})
+		if err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	for _, name := range []string{"Weekend", "Deep Work"} {
+		if _, err := s.CreateCollection("Alex", name); err != nil {
+			t.Fatalf("CreateCollection(%q) failed: %v", name, err)
+		}
+	}
+
+	if _, err := s.CreateCollection("Alex", "deep work"); !errors.Is(err, storage.ErrCollectionExists) {
+		t.Errorf("CreateCollection() of a taken name error = %v, want %v", err, storage.ErrCollectionExists)
+	}
+
+	err := s.UpdateCollection("Alex", "Alex", "DEEP WORK", func(c *storage.Collection) {
+		c.Owner = "Bob"
+		c.PageIDs = []int{2, 1, 3}
+	})
+	if err != nil {
+		t.Fatalf("UpdateCollection() failed: %v", err)
+	}
+
+	err = s.UpdateCollection("Alex", "Alex", "Weekend", func(c *storage.Collection) { c.Name = "Deep work" })
+	if !errors.Is(err, storage.ErrCollectionExists) {
+		t.Errorf("renaming to a taken name error = %v, want %v", err, storage.ErrCollectionExists)
+	}
+
+	err = s.UpdateCollection("Alex", "Alex", "Nope", func(c *storage.Collection) {})
+	if !errors.Is(err, storage.ErrNoCollection) {
+		t.Errorf("UpdateCollection() of a missing collection error = %v, want %v", err, storage.ErrNoCollection)
+	}
+
+	colls, err := s.Collections("Alex")
+	if err != nil {
+		t.Fatalf("Collections() failed: %v", err)
+	}
+	if len(colls) != 2 || colls[0].Name != "Deep Work" || colls[0].Owner != "Alex" || colls[1].Name != "Weekend" {
+		t.Fatalf("Collections() = %+v, want Deep Work owned by Alex and Weekend", colls)
+	}
+
+	next, err := s.NextInCollection("Alex", "Alex", "Deep Work")
+	if err != nil || next.ID != 2 {
+		t.Fatalf("NextInCollection() = %v, %v; want page 2", next, err)
+	}
+
+	_ = s.MarkAsRead(&storage.Page{URL: "https://2.com", UserName: "Alex"})
+	_ = s.Remove(&storage.Page{URL: "https://1.com", UserName: "Alex"})
+
+	next, err = s.NextInCollection("Alex", "Alex", "Deep Work")
+	if err != nil || next.ID != 3 {
+		t.Errorf("NextInCollection() = %v, %v; want page 3 after the read and removed ones", next, err)
+	}
+
+	_, err = s.PurgeTrash(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeTrash() failed: %v", err)
+	}
+
+	c, err := s.Collection("Alex", "Alex", "deep work")
+	if err != nil || !reflect.DeepEqual(c.PageIDs, []int{2, 3}) {
+		t.Errorf("Collection() after purge = %+v, %v; want the purged page dropped", c, err)
+	}
+
+	err = s.RemoveCollection("Alex", "Alex", "Deep Work")
+	if err != nil {
+		t.Fatalf("RemoveCollection() failed: %v", err)
+	}
+	if _, err := s.NextInCollection("Alex", "Alex", "Deep Work"); !errors.Is(err, storage.ErrNoCollection) {
+		t.Errorf("NextInCollection() of a removed collection error = %v, want %v", err, storage.ErrNoCollection)
+	}
+	if err := s.RemoveCollection("Alex", "Alex", "Deep Work"); !errors.Is(err, storage.ErrNoCollection) {
+		t.Errorf("RemoveCollection() twice error = %v, want %v", err, storage.ErrNoCollection)
+	}
+}
+
+func TestStorage_CollectionMembers(t *testing.T) {
+	s := memory.New()
+
+	if _, err := s.CreateCollection("Alex", "Team"); err != nil {
+		t.Fatalf("CreateCollection() failed: %v", err)
+	}
+
+	err := s.UpdateCollection("Alex", "Alex", "Team", func(c *storage.Collection) {
+		c.Members = map[string]storage.Permission{"Bob": storage.ReadOnly, "Kate": storage.Collaborator}
+	})
+	if err != nil {
+		t.Fatalf("sharing the collection failed: %v", err)
+	}
+
+	// Usernames are case-insensitive, so the member and owner are recognized
+	// whatever case they are written in.
+	for actor, want := range map[string]storage.Permission{"bob": storage.ReadOnly, "KATE": storage.Collaborator, "alex": storage.FullAccess} {
+		c, err := s.Collection(actor, "Alex", "Team")
+		if err != nil || c.Access(actor) != want {
+			t.Errorf("Collection() for %s = %+v, %v; want access %v", actor, c, err, want)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		actor string
+		fn    func(c *storage.Collection)
+		want  error
+	}{
+		{
+			name:  "collaborator adds a page",
+			actor: "Kate",
+			fn:    func(c *storage.Collection) { c.Insert(7, 0) },
+		},
+		{
+			name:  "collaborator renames",
+			actor: "Kate",
+			fn:    func(c *storage.Collection) { c.Name = "Mine" },
+			want:  storage.ErrForbidden,
+		},
+		{
+			name:  "collaborator shares further",
+			actor: "Kate",
+			fn:    func(c *storage.Collection) { c.Members["Eve"] = storage.ReadOnly },
+			want:  storage.ErrForbidden,
+		},
+		{
+			name:  "read-only member adds a page",
+			actor: "Bob",
+			fn:    func(c *storage.Collection) { c.Insert(8, 0) },
+			want:  storage.ErrForbidden,
+		},
+		{
+			name:  "stranger adds a page",
+			actor: "Eve",
+			fn:    func(c *storage.Collection) { c.Insert(9, 0) },
+			want:  storage.ErrNoCollection,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.UpdateCollection(tt.actor, "Alex", "Team", tt.fn)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("UpdateCollection() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	c, err := s.Collection("Bob", "Alex", "team")
+	if err != nil || c.Name != "Team" || !reflect.DeepEqual(c.PageIDs, []int{7}) {
+		t.Errorf("Collection() for a member = %+v, %v; want Team with only the collaborator's page", c, err)
+	}
+
+	if _, err := s.Collection("Eve", "Alex", "Team"); !errors.Is(err, storage.ErrNoCollection) {
+		t.Errorf("Collection() for a stranger error = %v, want %v", err, storage.ErrNoCollection)
+	}
+
+	colls, err := s.Collections("Bob")
+	if err != nil || len(colls) != 1 || colls[0].Owner != "Alex" {
+		t.Errorf("Collections() for a member = %+v, %v; want the shared collection", colls, err)
+	}
+
+	for _, actor := range []string{"Bob", "Kate"} {
+		if err := s.RemoveCollection(actor, "Alex", "Team"); !errors.Is(err, storage.ErrForbidden) {
+			t.Errorf("RemoveCollection() by member %s error = %v, want %v", actor, err, storage.ErrForbidden)
+		}
+	}
+	if err := s.RemoveCollection("Eve", "Alex", "Team"); !errors.Is(err, storage.ErrNoCollection) {
+		t.Errorf("RemoveCollection() by a stranger error = %v, want %v", err, storage.ErrNoCollection)
+	}
+	if err := s.RemoveCollection("Alex", "Alex", "Team"); err != nil {
+		t.Errorf("RemoveCollection() by the owner failed: %v", err)
+	}
+}
+
+func TestStorage_Shares(t *testing.T) {
+	s := memory.New()
+
+	share := &storage.Share{Token: "abc", From: "Alex", To: "Bob", URL: "https://a.com"}
+	if err := s.SaveShare(share); err != nil {
+		t.Fatalf("SaveShare() failed: %v", err)
+	}
+
+	got, err := s.Share("abc")
+	if err != nil || *got != *share {
+		t.Fatalf("Share() = %+v, %v; want %+v", got, err, share)
+	}
+
+	if err := s.RemoveShare("abc"); err != nil {
+		t.Fatalf("RemoveShare() failed: %v", err)
+	}
+	if _, err := s.Share("abc"); !errors.Is(err, storage.ErrNoShare) {
+		t.Errorf("Share() after removal error = %v, want %v", err, storage.ErrNoShare)
+	}
+
+	if err := s.SetUserChat("Bob", 42); err != nil {
+		t.Fatalf("SetUserChat() failed: %v", err)
+	}
+	if chatID, err := s.UserChat("bob"); err != nil || chatID != 42 {
+		t.Errorf("UserChat() = %d, %v; want 42", chatID, err)
+	}
+	if _, err := s.UserChat("Eve"); !errors.Is(err, storage.ErrUnknownUser) {
+		t.Errorf("UserChat() of an unknown user error = %v, want %v", err, storage.ErrUnknownUser)
+	}
+}
+
+func TestStorage_FeedToken(t *testing.T) {
+	s := memory.New()
+
+	if _, err := s.FeedToken("Alex"); !errors.Is(err, storage.ErrNoFeedToken) {
+		t.Errorf("FeedToken() before setting error = %v, want %v", err, storage.ErrNoFeedToken)
+	}
+
+	for _, token := range []string{"first", "second"} {
+		if err := s.SetFeedToken("Alex", token); err != nil {
+			t.Fatalf("SetFeedToken(%q) failed: %v", token, err)
+		}
+	}
+
+	if token, err := s.FeedToken("Alex"); err != nil || token != "second" {
+		t.Errorf("FeedToken() = %q, %v; want the rotated token", token, err)
+	}
+	if owner, err := s.FeedOwner("second"); err != nil || owner != "Alex" {
+		t.Errorf("FeedOwner() = %q, %v; want Alex", owner, err)
+	}
+	if _, err := s.FeedOwner("first"); !errors.Is(err, storage.ErrNoFeedToken) {
+		t.Errorf("FeedOwner() of a rotated token error = %v, want %v", err, storage.ErrNoFeedToken)
+	}
+}
+
+func TestStorage_APIToken(t *testing.T) {
+	s := memory.New()
+
+	for _, hash := range []string{"first", "second"} {
+		if err := s.SetAPIToken("Alex", hash); err != nil {
+			t.Fatalf("SetAPIToken(%q) failed: %v", hash, err)
+		}
+	}
+
+	if owner, err := s.APITokenOwner("second"); err != nil || owner != "Alex" {
+		t.Errorf("APITokenOwner() = %q, %v; want Alex", owner, err)
+	}
+	if _, err := s.APITokenOwner("first"); !errors.Is(err, storage.ErrNoAPIToken) {
+		t.Errorf("APITokenOwner() of a replaced token error = %v, want %v", err, storage.ErrNoAPIToken)
+	}
+
+	if err := s.SetAPIToken("Alex", ""); err != nil {
+		t.Fatalf("SetAPIToken() to revoke failed: %v", err)
+	}
+	if _, err := s.APITokenOwner("second"); !errors.Is(err, storage.ErrNoAPIToken) {
+		t.Errorf("APITokenOwner() of a revoked token error = %v, want %v", err, storage.ErrNoAPIToken)
+	}
+	if _, err := s.APITokenOwner(""); !errors.Is(err, storage.ErrNoAPIToken) {
+		t.Errorf("APITokenOwner(\"\") error = %v, want %v", err, storage.ErrNoAPIToken)
+	}
+}
+
+func TestStorage_Subscriptions(t *testing.T) {
+	s := memory.New()
+
+	sub := &storage.Subscription{Owner: "Alex", FeedURL: "https://blog.example/feed", Seen: []string{"a"}}
+	if err := s.Subscribe(sub); err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+	if err := s.Subscribe(sub); !errors.Is(err, storage.ErrSubscriptionExists) {
+		t.Errorf("Subscribe() twice error = %v, want %v", err, storage.ErrSubscriptionExists)
+	}
+
+	sub.Seen[0] = "changed"
+
+	err := s.UpdateSubscription("Alex", sub.FeedURL, func(sub *storage.Subscription) {
+		sub.Owner = "Bob"
+		sub.Seen = append(sub.Seen, "b")
+		sub.ETag = `"v2"`
+	})
+	if err != nil {
+		t.Fatalf("UpdateSubscription() failed: %v", err)
+	}
+
+	subs, err := s.Subscriptions("Alex")
+	if err != nil || len(subs) != 1 {
+		t.Fatalf("Subscriptions() = %+v, %v; want one subscription", subs, err)
+	}
+	if got := subs[0]; got.Owner != "Alex" || got.ETag != `"v2"` || !reflect.DeepEqual(got.Seen, []string{"a", "b"}) || got.CreatedAt.IsZero() {
+		t.Errorf("stored subscription = %+v, want the update applied to a copy of the original", got)
+	}
+
+	all, err := s.AllSubscriptions()
+	if err != nil || len(all) != 1 {
+		t.Errorf("AllSubscriptions() = %+v, %v; want one subscription", all, err)
+	}
+
+	if err := s.Unsubscribe("Alex", sub.FeedURL); err != nil {
+		t.Fatalf("Unsubscribe() failed: %v", err)
+	}
+	if err := s.Unsubscribe("Alex", sub.FeedURL); !errors.Is(err, storage.ErrNoSubscription) {
+		t.Errorf("Unsubscribe() twice error = %v, want %v", err, storage.ErrNoSubscription)
+	}
+	if err := s.UpdateSubscription("Alex", sub.FeedURL, func(*storage.Subscription) {}); !errors.Is(err, storage.ErrNoSubscription) {
+		t.Errorf("UpdateSubscription() after unsubscribing error = %v, want %v", err, storage.ErrNoSubscription)
+	}
+}
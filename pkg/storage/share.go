@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNoShare     = errors.New("share not found")
+	ErrForbidden   = errors.New("not allowed")
+	ErrUnknownUser = errors.New("user not found")
+)
+
+// Permission is the access a user has to a collection.
+type Permission int
+
+const (
+	NoAccess     Permission = iota
+	ReadOnly                // Can view the collection and read it with /next.
+	Collaborator            // Can also add, remove and reorder pages.
+	FullAccess              // The owner: can also rename, delete and share.
+)
+
+// Share is a page or a collection offered to another user. It is accepted
+// by its token, either through a prompt sent directly to To or through a
+// link that anyone can open when To is empty.
+type Share struct {
+	Token     string
+	From      string // Owner of the shared page or collection.
+	SharedBy  string // Username of whoever shared it.
+	To        string
+	URL       string // Shared page, for page shares.
+	Title     string
+	CreatedAt time.Time
+
+	// Shared collection and the access it grants, for collection shares.
+	Collection string
+	Permission Permission
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// StatsQuery defines the periods and limits of a Stats query.
+// Week and Month are the start of the current week and month in the user's time zone.
+type StatsQuery struct {
+	Week       time.Time
+	Month      time.Time
+	TopDomains int // Number of most saved domains to return.
+}
+
+// Stats are aggregated reading statistics of an owner.
+type Stats struct {
+	Total  int
+	Read   int
+	Unread int
+
+	SavedThisWeek  int
+	ReadThisWeek   int
+	SavedThisMonth int
+	ReadThisMonth  int
+
+	// AvgTimeToRead is the average time between saving and reading a page,
+	// zero if no page has been read yet.
+	AvgTimeToRead time.Duration
+
+	// TopDomains are the most saved domains, most saved first.
+	TopDomains []DomainCount
+}
+
+// DomainCount is the number of pages saved from a domain.
+type DomainCount struct {
+	Domain string
+	Count  int
+}
+
+// Domain returns the host of the page URL without a "www." prefix, in lower case.
+func Domain(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// Streak returns the number of consecutive days with at least one read page
+// that end today, or yesterday if nothing has been read today yet.
+// days are the distinct days with reads, newest first, in the same time zone as today.
+func Streak(days []time.Time, today time.Time) int {
+	if len(days) == 0 {
+		return 0
+	}
+
+	expect := today
+	if !sameDay(days[0], expect) {
+		expect = expect.AddDate(0, 0, -1)
+	}
+
+	streak := 0
+	for _, day := range days {
+		if !sameDay(day, expect) {
+			break
+		}
+
+		streak++
+		expect = expect.AddDate(0, 0, -1)
+	}
+
+	return streak
+}
+
+// sameDay reports whether a and b fall on the same calendar date.
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
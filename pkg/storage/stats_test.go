@@ -0,0 +1,65 @@
+package storage_test
+
+import (
+	"URLbot/pkg/storage"
+	"testing"
+	"time"
+)
+
+func TestDomain(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://example.com/a", want: "example.com"},
+		{url: "https://WWW.Example.com:8080/a", want: "example.com"},
+		{url: "http://blog.example.com", want: "blog.example.com"},
+		{url: "not a url", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := storage.Domain(tt.url); got != tt.want {
+			t.Errorf("Domain(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestStreak(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	today := day(10)
+
+	tests := []struct {
+		name string
+		days []time.Time
+		want int
+	}{
+		{name: "no reads", days: nil, want: 0},
+		{name: "today only", days: []time.Time{day(10)}, want: 1},
+		{name: "ends today", days: []time.Time{day(10), day(9), day(8), day(6)}, want: 3},
+		{name: "ends yesterday", days: []time.Time{day(9), day(8)}, want: 2},
+		{name: "broken", days: []time.Time{day(8), day(7)}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := storage.Streak(tt.days, today); got != tt.want {
+				t.Errorf("Streak() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreak_dst(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	// Clocks moved forward on 29 March 2026, so that day is only 23 hours long.
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, loc) }
+
+	got := storage.Streak([]time.Time{day(30), day(29), day(28)}, day(30))
+	if got != 3 {
+		t.Errorf("Streak() across a DST change = %d, want 3", got)
+	}
+}
@@ -1,22 +1,243 @@
 package storage
 
-import "errors"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
 
-var ErrNoPagesFound = errors.New("page not found")
+var (
+	ErrNoPagesFound = errors.New("page not found")
+	ErrNoDigest     = errors.New("digest not found")
+	ErrPageExists   = errors.New("page already exists")
+	ErrNoUndo       = errors.New("nothing to undo")
+	ErrNoFeedToken  = errors.New("feed token not found")
+	ErrNoAPIToken   = errors.New("API token not found")
+)
 
 // Storage is an interface for saving, retrieving, and managing user pages.
 type Storage interface {
 	Save(p *Page) error
-	GetRandomUnread(userName string) (*Page, error)
+	SaveBatch(pages []*Page) (int, error)
+	Get(userName string, id int) (*Page, error)
+	PickUnread(userName string, strategy Strategy, filter Filter) (*Page, error)
+	PickUnreadN(userName string, n int, strategy Strategy, filter Filter) ([]*Page, error)
+	MarkServed(userName string, pageURLs []string, at time.Time) error
+	RecentlyServed(userName string, k int) ([]string, error)
 	MarkAsRead(p *Page) error
+	Update(userName, pageURL string, fn func(p *Page)) error
+	ChangeURL(userName, oldURL, newURL string) error
 	IsExists(p *Page) (bool, error)
 	Remove(p *Page) error
+	Trash(userName string) ([]*Page, error)
+	Restore(userName, pageURL string) (*Page, error)
+	PurgeTrash(before time.Time) ([]*Page, error)
+	PushUndo(userName string, a Action) error
+	PopUndo(userName string) (*Action, error)
+	Collections(userName string) ([]*Collection, error)
+	Collection(actor, owner, name string) (*Collection, error)
+	CreateCollection(owner, name string) (*Collection, error)
+	UpdateCollection(actor, owner, name string, fn func(c *Collection)) error
+	RemoveCollection(actor, owner, name string) error
+	NextInCollection(actor, owner, name string) (*Page, error)
+	SaveShare(s *Share) error
+	Share(token string) (*Share, error)
+	RemoveShare(token string) error
+	SetUserChat(userName string, chatID int) error
+	UserChat(userName string) (int, error)
+	FeedToken(userName string) (string, error)
+	SetFeedToken(userName, tokenHash string) error
+	FeedOwner(tokenHash string) (string, error)
+	SetAPIToken(userName, tokenHash string) error
+	APITokenOwner(tokenHash string) (string, error)
+	Subscribe(s *Subscription) error
+	Unsubscribe(owner, feedURL string) error
+	Subscriptions(owner string) ([]*Subscription, error)
+	AllSubscriptions() ([]*Subscription, error)
+	UpdateSubscription(owner, feedURL string, fn func(s *Subscription)) error
 	List(userName string) ([]*Page, error)
+	ListAll() ([]*Page, error)
+	DueSnoozes(now time.Time) ([]*Page, error)
+	Stats(userName string, q StatsQuery) (*Stats, error)
+	ReadDays(userName string, loc *time.Location) ([]time.Time, error)
+	ChatMode(chatID int) (ListMode, error)
+	SetChatMode(chatID int, mode ListMode) error
+	Strategy(userName string) (Strategy, error)
+	SetStrategy(userName string, strategy Strategy) error
+	Digest(chatID int, owner string) (*Digest, error)
+	SaveDigest(d *Digest) error
+	RemoveDigest(chatID int, owner string) error
+	DueDigests(now time.Time) ([]*Digest, error)
+	AdvanceDigest(chatID int, owner string, from, to time.Time) (bool, error)
 }
 
 // Page represents a user-saved link with its read status.
+// UserName is the owner of the page: a user or, for shared lists, a chat.
+// AddedBy keeps the username of whoever saved the link.
+// ID is assigned by the storage when the page is saved.
 type Page struct {
-	URL      string
-	UserName string
-	Read     bool
+	ID        int
+	URL       string
+	UserName  string
+	AddedBy   string
+	Read      bool
+	Tags      []string
+	CreatedAt time.Time
+	ReadAt    time.Time
+
+	// Metadata fetched from the page itself. EnrichedAt is zero until
+	// the page has been fetched successfully.
+	Title        string
+	Description  string
+	SiteName     string
+	CanonicalURL string
+	Language     string
+	WordCount    int
+	ReadingTime  time.Duration
+	EnrichedAt   time.Time
+
+	// Key of the archived copy of the page in the blob store, if there is one.
+	SnapshotKey string
+	SnapshotAt  time.Time
+
+	// When the page was last served by /random and how many times in total.
+	ServedAt    time.Time
+	ServedCount int
+
+	// A snoozed page is hidden from random picks and digests until SnoozedUntil,
+	// and then a reminder is sent to SnoozeChatID.
+	SnoozedUntil time.Time
+	SnoozeChatID int
+
+	// Result of the last link check. CheckedAt is zero until the link has been
+	// checked. CheckFailures counts the failed checks in a row.
+	StatusCode    int
+	RedirectURL   string
+	CheckError    string
+	CheckedAt     time.Time
+	CheckFailures int
+
+	// Changes of the read status, oldest first.
+	History []Change
+
+	// When the page was moved to the trash. Trashed pages are left out of every
+	// query except Trash until they are restored or purged.
+	DeletedAt time.Time
+}
+
+// Snoozed reports whether the page is snoozed at the given time.
+func (p *Page) Snoozed(now time.Time) bool {
+	return p.SnoozedUntil.After(now)
+}
+
+// BrokenAfter is how many checks in a row have to fail before a link is
+// considered dead, so a site that is down for a while is not reported.
+const BrokenAfter = 3
+
+// Broken reports whether the last BrokenAfter checks found the link dead.
+func (p *Page) Broken() bool {
+	return p.CheckFailures >= BrokenAfter
+}
+
+// Moved reports whether the link works but redirects to another address.
+func (p *Page) Moved() bool {
+	return p.RedirectURL != "" && !p.CheckedAt.IsZero() && p.CheckFailures == 0
+}
+
+// Filter reports whether a page matches a query. A nil Filter matches every page.
+type Filter func(p *Page) bool
+
+// Match reports whether the page matches the filter.
+func (f Filter) Match(p *Page) bool {
+	return f == nil || f(p)
+}
+
+// DisplayTitle returns the page title, or its URL if the title is not known yet.
+func (p *Page) DisplayTitle() string {
+	if p.Title != "" {
+		return p.Title
+	}
+
+	return p.URL
+}
+
+// Clone returns a copy of the page that shares no memory with the original.
+func (p *Page) Clone() *Page {
+	c := *p
+	c.Tags = append([]string(nil), p.Tags...)
+	c.History = append([]Change(nil), p.History...)
+
+	return &c
+}
+
+// HashToken returns the hex-encoded SHA-256 hash of a feed or API token.
+// Only hashes are stored, so a leaked storage does not leak working tokens.
+// Tokens are random and long, so a plain hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// ListMode defines whose reading list is used for commands sent in a group chat.
+type ListMode int
+
+const (
+	// Personal mode keeps a separate list for every member of the chat.
+	Personal ListMode = iota
+	// Shared mode keeps one list owned by the chat for all its members.
+	Shared
+)
+
+// String returns the human-readable name of the mode.
+func (m ListMode) String() string {
+	if m == Shared {
+		return "shared"
+	}
+
+	return "personal"
+}
+
+// Digest is a scheduled message with unread picks from the owner's list,
+// sent to a chat every day or every week at a local time.
+// A chat has at most one digest per owner.
+type Digest struct {
+	ChatID   int
+	Owner    string
+	Weekly   bool
+	Weekday  time.Weekday // Day of the week for weekly digests.
+	Hour     int
+	Minute   int
+	TimeZone string // IANA time zone name, e.g. "Europe/Berlin".
+	Count    int    // Number of pages per digest.
+	NextAt   time.Time
+}
+
+// Location returns the digest's time zone, or UTC if it is unknown.
+func (d *Digest) Location() *time.Location {
+	loc, err := time.LoadLocation(d.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// Next returns the first send time of the digest strictly after the given time.
+func (d *Digest) Next(after time.Time) time.Time {
+	t := after.In(d.Location())
+	next := time.Date(t.Year(), t.Month(), t.Day(), d.Hour, d.Minute, 0, 0, t.Location())
+
+	step := 1
+	if d.Weekly {
+		step = 7
+		next = next.AddDate(0, 0, (int(d.Weekday)-int(next.Weekday())+7)%7)
+	}
+
+	for !next.After(after) {
+		next = next.AddDate(0, 0, step)
+	}
+
+	return next
 }
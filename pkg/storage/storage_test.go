@@ -0,0 +1,76 @@
+package storage_test
+
+import (
+	"URLbot/pkg/storage"
+	"testing"
+	"time"
+)
+
+func TestDigest_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation() failed: %v", err)
+	}
+
+	// Wednesday, 2026-03-04 10:00 in Berlin.
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name   string
+		digest storage.Digest
+		after  time.Time
+		want   time.Time
+	}{
+		{
+			name:   "later today",
+			digest: storage.Digest{Hour: 18, TimeZone: "Europe/Berlin"},
+			after:  now,
+			want:   time.Date(2026, 3, 4, 18, 0, 0, 0, berlin),
+		},
+		{
+			name:   "tomorrow",
+			digest: storage.Digest{Hour: 9, Minute: 30, TimeZone: "Europe/Berlin"},
+			after:  now,
+			want:   time.Date(2026, 3, 5, 9, 30, 0, 0, berlin),
+		},
+		{
+			name:   "exactly at send time",
+			digest: storage.Digest{Hour: 10, TimeZone: "Europe/Berlin"},
+			after:  now,
+			want:   time.Date(2026, 3, 5, 10, 0, 0, 0, berlin),
+		},
+		{
+			name:   "weekly later this week",
+			digest: storage.Digest{Weekly: true, Weekday: time.Friday, Hour: 8, TimeZone: "Europe/Berlin"},
+			after:  now,
+			want:   time.Date(2026, 3, 6, 8, 0, 0, 0, berlin),
+		},
+		{
+			name:   "weekly next week",
+			digest: storage.Digest{Weekly: true, Weekday: time.Wednesday, Hour: 8, TimeZone: "Europe/Berlin"},
+			after:  now,
+			want:   time.Date(2026, 3, 11, 8, 0, 0, 0, berlin),
+		},
+		{
+			name:   "other time zone",
+			digest: storage.Digest{Hour: 9, TimeZone: "UTC"},
+			after:  now,
+			want:   time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "across a DST change",
+			digest: storage.Digest{Hour: 9, TimeZone: "Europe/Berlin"},
+			after:  time.Date(2026, 3, 28, 12, 0, 0, 0, berlin),
+			want:   time.Date(2026, 3, 29, 9, 0, 0, 0, berlin),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.digest.Next(tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
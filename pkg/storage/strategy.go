@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+var ErrUnknownStrategy = errors.New("unknown selection strategy")
+
+// Strategy defines which unread page is picked for /random.
+type Strategy int
+
+const (
+	// Uniform picks any unread page with equal probability.
+	Uniform Strategy = iota
+	// OldestFirst picks the page that was saved first.
+	OldestFirst
+	// NewestFirst picks the page that was saved last.
+	NewestFirst
+	// AgeWeighted picks at random, but older pages are proportionally more likely.
+	AgeWeighted
+	// ShortFirst picks the page with the shortest known reading time.
+	ShortFirst
+	// Spaced resurfaces pages at growing intervals: a page rests for a day after
+	// it is served for the first time, two days after the second time, and so on.
+	Spaced
+)
+
+// Strategies lists all strategies in the order they are shown to users.
+var Strategies = []Strategy{Uniform, OldestFirst, NewestFirst, AgeWeighted, ShortFirst, Spaced}
+
+var strategyNames = map[Strategy]string{
+	Uniform:     "uniform",
+	OldestFirst: "oldest",
+	NewestFirst: "newest",
+	AgeWeighted: "weighted",
+	ShortFirst:  "short",
+	Spaced:      "spaced",
+}
+
+// String returns the name of the strategy as used in the /mode command.
+func (s Strategy) String() string {
+	if name, ok := strategyNames[s]; ok {
+		return name
+	}
+
+	return strategyNames[Uniform]
+}
+
+// ParseStrategy returns the strategy with the given name.
+func ParseStrategy(name string) (Strategy, error) {
+	for s, n := range strategyNames {
+		if strings.EqualFold(name, n) {
+			return s, nil
+		}
+	}
+
+	return Uniform, ErrUnknownStrategy
+}
+
+// maxSpacedInterval caps the rest period of the Spaced strategy.
+const maxSpacedInterval = 64 * 24 * time.Hour
+
+// Pick selects one of the candidate pages. Candidates must not be empty.
+// Storage backends that cannot express a strategy in their query language
+// can load the candidates and use Pick; rnd is the source for random choices.
+func (s Strategy) Pick(pages []*Page, now time.Time, rnd *rand.Rand) *Page {
+	switch s {
+	case OldestFirst:
+		return best(pages, func(a, b *Page) bool { return a.CreatedAt.Before(b.CreatedAt) })
+	case NewestFirst:
+		return best(pages, func(a, b *Page) bool { return a.CreatedAt.After(b.CreatedAt) })
+	case ShortFirst:
+		return best(pages, func(a, b *Page) bool {
+			// Pages with an unknown reading time go last.
+			return b.ReadingTime == 0 && a.ReadingTime > 0 || a.ReadingTime > 0 && a.ReadingTime < b.ReadingTime
+		})
+	case AgeWeighted:
+		return pickWeighted(pages, now, rnd)
+	case Spaced:
+		return pickSpaced(pages, rnd)
+	default:
+		return pages[rnd.Intn(len(pages))]
+	}
+}
+
+// PickN selects up to n distinct candidate pages by picking them one after another.
+func (s Strategy) PickN(pages []*Page, n int, now time.Time, rnd *rand.Rand) []*Page {
+	rest := append([]*Page(nil), pages...)
+	res := make([]*Page, 0, n)
+
+	for len(res) < n && len(rest) > 0 {
+		picked := s.Pick(rest, now, rnd)
+		res = append(res, picked)
+
+		for i, p := range rest {
+			if p == picked {
+				rest = append(rest[:i], rest[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// best returns the first page for which no other page is less.
+func best(pages []*Page, less func(a, b *Page) bool) *Page {
+	res := pages[0]
+	for _, p := range pages[1:] {
+		if less(p, res) {
+			res = p
+		}
+	}
+
+	return res
+}
+
+// pickWeighted picks a random page with a probability proportional to its age.
+// A day is added to every age, so that pages saved just now can still be picked.
+func pickWeighted(pages []*Page, now time.Time, rnd *rand.Rand) *Page {
+	weights := make([]float64, len(pages))
+	total := 0.0
+
+	for i, p := range pages {
+		age := now.Sub(p.CreatedAt)
+		if age < 0 {
+			age = 0
+		}
+
+		weights[i] = (age + 24*time.Hour).Hours()
+		total += weights[i]
+	}
+
+	x := rnd.Float64() * total
+	for i, w := range weights {
+		if x < w {
+			return pages[i]
+		}
+		x -= w
+	}
+
+	return pages[len(pages)-1]
+}
+
+// pickSpaced picks the page that has been due for the longest time.
+// Pages that were never served are due first and are picked at random.
+func pickSpaced(pages []*Page, rnd *rand.Rand) *Page {
+	var due []*Page
+	var earliest time.Time
+
+	for _, p := range pages {
+		at := spacedDue(p)
+		switch {
+		case len(due) == 0 || at.Before(earliest):
+			due, earliest = []*Page{p}, at
+		case at.Equal(earliest):
+			due = append(due, p)
+		}
+	}
+
+	return due[rnd.Intn(len(due))]
+}
+
+// spacedDue returns when a page is due to be served again by the Spaced strategy.
+func spacedDue(p *Page) time.Time {
+	if p.ServedCount == 0 {
+		return time.Time{}
+	}
+
+	interval := maxSpacedInterval
+	if p.ServedCount <= 7 {
+		interval = 24 * time.Hour << (p.ServedCount - 1)
+	}
+
+	return p.ServedAt.Add(interval)
+}
@@ -0,0 +1,120 @@
+package storage_test
+
+import (
+	"URLbot/pkg/storage"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParseStrategy(t *testing.T) {
+	for _, s := range storage.Strategies {
+		got, err := storage.ParseStrategy(s.String())
+		if err != nil || got != s {
+			t.Errorf("ParseStrategy(%q) = %v, %v; want %v", s.String(), got, err, s)
+		}
+	}
+
+	_, err := storage.ParseStrategy("fastest")
+	if err != storage.ErrUnknownStrategy {
+		t.Errorf("ParseStrategy() error = %v, want %v", err, storage.ErrUnknownStrategy)
+	}
+}
+
+func TestStrategy_Pick(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	old := &storage.Page{URL: "old", CreatedAt: now.Add(-300 * day), ReadingTime: 30 * time.Minute}
+	mid := &storage.Page{URL: "mid", CreatedAt: now.Add(-10 * day), ServedCount: 1, ServedAt: now.Add(-2 * day)}
+	recent := &storage.Page{URL: "recent", CreatedAt: now.Add(-time.Hour), ReadingTime: 4 * time.Minute, ServedCount: 3, ServedAt: now.Add(-time.Hour)}
+	pages := []*storage.Page{mid, old, recent}
+
+	tests := []struct {
+		strategy storage.Strategy
+		pages    []*storage.Page
+		want     string
+	}{
+		{strategy: storage.OldestFirst, pages: pages, want: "old"},
+		{strategy: storage.NewestFirst, pages: pages, want: "recent"},
+		{strategy: storage.ShortFirst, pages: pages, want: "recent"},
+		{strategy: storage.ShortFirst, pages: []*storage.Page{mid, old}, want: "old"},
+		{strategy: storage.Spaced, pages: pages, want: "old"},
+		{strategy: storage.Spaced, pages: []*storage.Page{recent, mid}, want: "mid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy.String()+"/"+tt.want, func(t *testing.T) {
+			got := tt.strategy.Pick(tt.pages, now, rand.New(rand.NewSource(1)))
+			if got.URL != tt.want {
+				t.Errorf("Pick() = %s, want %s", got.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrategy_Pick_distribution(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	old := &storage.Page{URL: "old", CreatedAt: now.Add(-99 * 24 * time.Hour)}
+	fresh := &storage.Page{URL: "fresh", CreatedAt: now}
+	pages := []*storage.Page{old, fresh}
+
+	tests := []struct {
+		strategy storage.Strategy
+		minOld   int
+		maxOld   int
+	}{
+		// Equal chances.
+		{strategy: storage.Uniform, minOld: 400, maxOld: 600},
+		// The old page weighs 100 days against 1 day of the fresh one.
+		{strategy: storage.AgeWeighted, minOld: 960, maxOld: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(42))
+
+			picks := 0
+			for i := 0; i < 1000; i++ {
+				if tt.strategy.Pick(pages, now, rnd) == old {
+					picks++
+				}
+			}
+
+			if picks < tt.minOld || picks > tt.maxOld {
+				t.Errorf("old page picked %d times out of 1000, want %d..%d", picks, tt.minOld, tt.maxOld)
+			}
+		})
+	}
+}
+
+func TestStrategy_PickN(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pages := []*storage.Page{
+		{URL: "https://b.com", CreatedAt: base.Add(time.Hour)},
+		{URL: "https://a.com", CreatedAt: base},
+		{URL: "https://c.com", CreatedAt: base.Add(2 * time.Hour)},
+	}
+	rnd := rand.New(rand.NewSource(1))
+
+	got := storage.OldestFirst.PickN(pages, 2, base, rnd)
+	if len(got) != 2 || got[0].URL != "https://a.com" || got[1].URL != "https://b.com" {
+		t.Errorf("OldestFirst.PickN(2) = %v, want a.com then b.com", got)
+	}
+
+	for _, s := range storage.Strategies {
+		got := s.PickN(pages, 5, base, rnd)
+		seen := make(map[string]bool)
+		for _, p := range got {
+			seen[p.URL] = true
+		}
+		if len(got) != 3 || len(seen) != 3 {
+			t.Errorf("%v.PickN(5) returned %d pages, %d distinct; want all 3", s, len(got), len(seen))
+		}
+	}
+
+	if len(pages) != 3 || pages[0].URL != "https://b.com" {
+		t.Errorf("PickN() modified the candidates: %v", pages)
+	}
+}
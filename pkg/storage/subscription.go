@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNoSubscription     = errors.New("subscription not found")
+	ErrSubscriptionExists = errors.New("subscription already exists")
+)
+
+// Subscription is a feed whose new items are saved to the owner's list.
+// Notifications about new items go to ChatID. ETag and LastModified come from
+// the last response of the feed server and make repeated polls cheap.
+type Subscription struct {
+	Owner        string
+	ChatID       int
+	FeedURL      string
+	Title        string
+	Tag          string // Tag added to saved items, if any.
+	ETag         string
+	LastModified string
+	Seen         []string // IDs of items already seen, oldest first.
+	CheckedAt    time.Time
+	LastError    string // Error of the last poll, empty if it succeeded.
+	CreatedAt    time.Time
+}
+
+// Clone returns a deep copy of the subscription.
+func (s *Subscription) Clone() *Subscription {
+	cp := *s
+	cp.Seen = append([]string(nil), s.Seen...)
+
+	return &cp
+}
@@ -0,0 +1,38 @@
+package storage
+
+import "time"
+
+// ActionKind is the kind of a change that can be undone.
+type ActionKind int
+
+const (
+	// ActionSave is saving a new page.
+	ActionSave ActionKind = iota + 1
+	// ActionRead is marking a page as read.
+	ActionRead
+	// ActionRemove is moving a page to the trash.
+	ActionRemove
+	// ActionTag is changing the tags of a page.
+	ActionTag
+	// ActionUnread is marking a page as unread.
+	ActionUnread
+	// ActionMove is changing the URL of a page, e.g. to its redirect target.
+	ActionMove
+)
+
+// Action is an entry of the undo log: a change made to one page and
+// what is needed to reverse it.
+type Action struct {
+	Kind   ActionKind
+	URL    string
+	Tags   []string  // Tags before an ActionTag.
+	ReadAt time.Time // Read time before an ActionUnread.
+	OldURL string    // URL before an ActionMove, whose URL is the new one.
+	At     time.Time
+}
+
+// Change is an entry of a page's history.
+type Change struct {
+	Kind ActionKind
+	At   time.Time
+}